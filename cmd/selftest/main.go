@@ -0,0 +1,44 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"ocr-classifier/internal/service"
+)
+
+// sample is a small English text image used to verify the OCR setup.
+//
+//go:embed sample.png
+var sample []byte
+
+func main() {
+	// 1. Run OCR on the embedded sample
+	classifier := service.NewClassifier()
+	rule := service.GetDefaultDecisionRule()
+	rule.Language = "eng"
+
+	result, err := classifier.DetectText(sample, rule)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Self-test failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 2. Print the result
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Self-test failed to encode result: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+
+	// 3. Check that recognition produced plausible output
+	if result.TokenCount == 0 || result.WeightedConfidence == 0 {
+		fmt.Fprintln(os.Stderr, "Self-test failed: no text recognized on the sample image, check Tesseract installation and tessdata")
+		os.Exit(1)
+	}
+
+	fmt.Println("Self-test passed")
+}
@@ -10,24 +10,123 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"ocr-classifier/internal/config"
 	"ocr-classifier/internal/handler"
+	"ocr-classifier/internal/service"
 )
 
 func main() {
 	// 1. Load configuration
 	cfg := config.Load()
+	if err := service.ValidateLanguage(cfg.DefaultLanguage); err != nil {
+		log.Fatalf("Invalid OCR_DEFAULT_LANG: %v", err)
+	}
+	if err := service.ValidateOrientationStrategy(cfg.Orientation); err != nil {
+		log.Fatalf("Invalid OCR_ORIENTATION: %v", err)
+	}
+	if err := service.ValidateOCREngine(cfg.Engine); err != nil {
+		log.Fatalf("Invalid OCR_ENGINE: %v", err)
+	}
+	if _, err := service.ParseLanguageThresholds(cfg.LanguageThresholds); err != nil {
+		log.Fatalf("Invalid OCR_LANG_CONFIDENCE_THRESHOLDS: %v", err)
+	}
+	if err := service.ValidateHashAlgorithm(cfg.HashAlgorithm); err != nil {
+		log.Fatalf("Invalid OCR_CONTENT_HASH_ALGORITHM: %v", err)
+	}
+	if err := service.ValidateOEM(cfg.OEM); err != nil {
+		log.Fatalf("Invalid OCR_OEM: %v", err)
+	}
+	if err := service.ValidatePSM(cfg.PSM); err != nil {
+		log.Fatalf("Invalid OCR_PSM: %v", err)
+	}
+	if err := service.ValidatePreprocessPreset(cfg.PreprocessPreset); err != nil {
+		log.Fatalf("Invalid OCR_PREPROCESS_PRESET: %v", err)
+	}
+	if _, err := service.ParsePreprocessChain(cfg.PreprocessChain); err != nil {
+		log.Fatalf("Invalid OCR_PREPROCESS_CHAIN: %v", err)
+	}
+	if _, err := service.ParseAngles(cfg.Angles); err != nil {
+		log.Fatalf("Invalid OCR_ANGLES: %v", err)
+	}
+	if _, err := service.ParseScaleTiers(cfg.ScaleTiers, cfg.ScaleMaxMegapixels); err != nil {
+		log.Fatalf("Invalid OCR_SCALE_TIERS: %v", err)
+	}
+	if err := service.ValidateRotationFill(cfg.RotationFill); err != nil {
+		log.Fatalf("Invalid OCR_ROTATION_FILL: %v", err)
+	}
+	if err := service.ValidateColorModels(cfg.ColorModels); err != nil {
+		log.Fatalf("Invalid OCR_COLOR_MODELS: %v", err)
+	}
+	if err := service.ValidatePadSmallImages(cfg.PadSmallImages); err != nil {
+		log.Fatalf("Invalid OCR_PAD_SMALL_IMAGES: %v", err)
+	}
+	if cfg.MaxImageBytes < 0 {
+		log.Fatalf("Invalid OCR_MAX_IMAGE_BYTES: %d, must not be negative", cfg.MaxImageBytes)
+	}
+	if cfg.MaxImagePixels < 0 {
+		log.Fatalf("Invalid OCR_MAX_IMAGE_PIXELS: %d, must not be negative", cfg.MaxImagePixels)
+	}
+	if cfg.MaxBatchSize <= 0 {
+		log.Fatalf("Invalid OCR_MAX_BATCH_SIZE: %d, must be positive", cfg.MaxBatchSize)
+	}
+	if cfg.BatchParallelism < 0 {
+		log.Fatalf("Invalid OCR_BATCH_PARALLELISM: %d, must not be negative", cfg.BatchParallelism)
+	}
+	if cfg.BatchDecodeParallelism < 0 {
+		log.Fatalf("Invalid OCR_BATCH_DECODE_PARALLELISM: %d, must not be negative", cfg.BatchDecodeParallelism)
+	}
+	if cfg.ArchiveQueueSize <= 0 {
+		log.Fatalf("Invalid OCR_ARCHIVE_QUEUE_SIZE: %d, must be positive", cfg.ArchiveQueueSize)
+	}
+	if err := service.ValidatePDFDPI(cfg.PDFDPI); err != nil {
+		log.Fatalf("Invalid OCR_PDF_DPI: %v", err)
+	}
+	if cfg.NoTextStatus != http.StatusOK && cfg.NoTextStatus != http.StatusNoContent {
+		log.Fatalf("Invalid OCR_NO_TEXT_STATUS: %d, must be 200 or 204", cfg.NoTextStatus)
+	}
+	if cfg.RotationMargin < 0 || cfg.RotationMargin > 1 {
+		log.Fatalf("Invalid OCR_ROTATION_MARGIN: %v, must be in [0, 1]", cfg.RotationMargin)
+	}
+	if cfg.SnapTolerance < 0 || cfg.SnapTolerance >= 45 {
+		log.Fatalf("Invalid OCR_ANGLE_SNAP_TOLERANCE: %d, must be in [0, 45)", cfg.SnapTolerance)
+	}
+	if cfg.DedupIoU < 0 || cfg.DedupIoU > 1 {
+		log.Fatalf("Invalid OCR_DEDUP_IOU: %v, must be in [0, 1]", cfg.DedupIoU)
+	}
+	if cfg.CoarseScale < 0 || cfg.CoarseScale >= 1 {
+		log.Fatalf("Invalid OCR_COARSE_SCALE: %v, must be in [0, 1)", cfg.CoarseScale)
+	}
+	if cfg.OSDMinConfidence < 0 {
+		log.Fatalf("Invalid OCR_OSD_MIN_CONFIDENCE: %v, must not be negative", cfg.OSDMinConfidence)
+	}
+	if cfg.ScriptDominance <= 0 || cfg.ScriptDominance > 1 {
+		log.Fatalf("Invalid OCR_SCRIPT_DOMINANCE: %v, must be in (0, 1]", cfg.ScriptDominance)
+	}
 
 	// 2. Initialize router
 	mux := http.NewServeMux()
 
 	// 3. Initialize handlers
-	classifyHandler := handler.NewClassifyHandler()
+	classifyHandler := handler.NewClassifyHandler(cfg)
+	healthHandler := handler.NewHealthHandler(cfg)
 
 	// 4. Register handlers
 	// Root API prefix: /ocr-classifier/api
-	mux.HandleFunc("/ocr-classifier/api/health", handler.HealthCheck)
+	mux.HandleFunc("/ocr-classifier/api/health", healthHandler.HealthCheck)
 	mux.HandleFunc("/ocr-classifier/api/v1/classify", classifyHandler.Classify)
+	mux.HandleFunc("/ocr-classifier/api/v1/classify/batch", classifyHandler.Batch)
+	mux.HandleFunc("/ocr-classifier/api/v1/analyze", classifyHandler.Analyze)
+	mux.HandleFunc("/ocr-classifier/api/v1/languages", classifyHandler.Languages)
+	if cfg.Metrics {
+		mux.Handle("/metrics", promhttp.Handler())
+	}
+	if cfg.AngleCurve {
+		mux.HandleFunc("/ocr-classifier/api/v1/classify/angles", classifyHandler.AngleCurve)
+	}
+	if cfg.DebugStages {
+		mux.HandleFunc("/ocr-classifier/api/v1/classify/stages", classifyHandler.Stages)
+	}
 
 	// 5. Create HTTP server
 	addr := ":" + cfg.Port
@@ -59,6 +158,9 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if err := classifyHandler.Close(); err != nil {
+		log.Printf("Failed to close classifier: %v", err)
+	}
 
 	fmt.Println("Server exited gracefully")
 }
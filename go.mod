@@ -5,7 +5,20 @@ go 1.21
 require (
 	github.com/anthonynsimon/bild v0.14.0
 	github.com/disintegration/imaging v1.6.2
+	github.com/gen2brain/go-fitz v1.23.7
 	github.com/otiai10/gosseract/v2 v2.4.1
+	github.com/prometheus/client_golang v1.19.1
+	github.com/strukturag/libheif v1.17.6
+	golang.org/x/image v0.18.0
+	golang.org/x/text v0.16.0
 )
 
-require golang.org/x/image v0.18.0 // indirect
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
@@ -2,21 +2,292 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 // Config holds application configuration.
 type Config struct {
 	Port string
+	// FlipCheck enables the fast 0-vs-180 orientation check before the full rotation sweep.
+	FlipCheck bool
+	// DefaultLanguage is the Tesseract language used when a request specifies none.
+	DefaultLanguage string
+	// DedupIoU is the IoU threshold for overlapping box deduplication (0 disables it).
+	DedupIoU float64
+	// Orientation is the phase 2 rotation search strategy ("sweep" or "estimate").
+	Orientation string
+	// SoftFail makes the classify handler answer 200 with an error result instead of 5xx.
+	SoftFail bool
+	// NoTextStatus is the classify status for results without tokens: 200 (JSON result) or 204 (no body).
+	NoTextStatus int
+	// SnapTolerance is the tolerance in degrees for snapping the winning angle to a cardinal one (0 disables it).
+	SnapTolerance int
+	// RotationMargin is the minimum confidence gain a rotated result needs over the upright one.
+	RotationMargin float64
+	// ConfidenceThreshold is the default weighted confidence threshold (0 uses the built-in default).
+	ConfidenceThreshold float64
+	// LanguageThresholds holds per-language default thresholds as "lang=threshold,...".
+	LanguageThresholds string
+	// MaxConfidenceThreshold is the upper bound for per-request confidence thresholds (0 means 1).
+	MaxConfidenceThreshold float64
+	// AutoLanguageParallelism limits concurrent languages for lang=auto (0 means all at once).
+	AutoLanguageParallelism int
+	// ReportThreshold adds the preprocessing luminance thresholds to classify results.
+	ReportThreshold bool
+	// MultiScale makes classify retry the winning angle at a lower and a higher scale factor.
+	MultiScale bool
+	// ScaleTiers is a comma-separated list of megapixels:factor preprocessing scale tiers (empty uses the defaults).
+	ScaleTiers string
+	// ScaleMaxMegapixels is the image size above which images are scaled down (0 uses the default of 3 MP).
+	ScaleMaxMegapixels float64
+	// PDFDPI is the resolution PDF pages are rendered at before recognition.
+	PDFDPI float64
+	// ScriptDominance is the letter share a script needs to select its language for lang=script.
+	ScriptDominance float64
+	// Equalize enables global histogram equalization during preprocessing.
+	Equalize bool
+	// BackgroundBand is the luminance band around the background whitened during preprocessing (0 disables it).
+	BackgroundBand int
+	// PreprocessPreset is the name of the default preprocessing preset.
+	PreprocessPreset string
+	// PreprocessChain is a comma-separated list of presets tried in order until one yields enough tokens.
+	PreprocessChain string
+	// ChainMinTokens is the token count that ends the preprocessing chain (0 means 1).
+	ChainMinTokens int
+	// ChainMaxAttempts caps the number of preprocessing chain profiles tried (0 means all).
+	ChainMaxAttempts int
+	// ChainOnRecognitionFail runs the preprocessing chain when a request preset yields only zero-confidence words.
+	ChainOnRecognitionFail bool
+	// MaxSweepPasses caps the number of phase 2 rotation attempts (0 means no cap).
+	MaxSweepPasses int
+	// ConfidencePrecision is the number of decimals confidences are rounded to in responses (negative disables rounding).
+	ConfidencePrecision int
+	// Engine is the name of the OCR engine backend.
+	Engine string
+	// OEM is the default Tesseract engine mode: default, legacy, lstm or combined.
+	OEM string
+	// PSM is the default Tesseract page segmentation mode, e.g. single_line (empty means Tesseract's default).
+	PSM string
+	// TextLines enables per-line text output in classify responses.
+	TextLines bool
+	// RepeatThreshold discounts low-variation words repeated at least this many times as noise (0 disables it).
+	RepeatThreshold int
+	// Blocks enables the block/paragraph/line structure of recognized words in results.
+	Blocks bool
+	// OriginalBoxes adds the boxes in original image coordinates to classify results.
+	OriginalBoxes bool
+	// CompressIntermediate compresses in-memory images passed to the OCR engine.
+	CompressIntermediate bool
+	// QualityWarnings enables image quality warnings in classify responses.
+	QualityWarnings bool
+	// MixedOrientation enables re-recognition of differently oriented page regions.
+	MixedOrientation bool
+	// TextColor enables per-box and overall text color sampling in classify responses.
+	TextColor bool
+	// PadSmallImages is the size small images are padded to instead of being rejected (0 disables).
+	PadSmallImages int
+	// ColorModels is the handling of CMYK and 16-bit images: normalize or reject.
+	ColorModels string
+	// Metrics exposes Prometheus metrics at /metrics.
+	Metrics bool
+	// LanguageFallback makes classify use the default language when the requested one is not installed.
+	LanguageFallback bool
+	// ReportDPI adds the input and effective DPI and the scale factors to classify responses.
+	ReportDPI bool
+	// DPIHint is the input DPI assumed when the image metadata stores none (0 means unknown).
+	DPIHint int
+	// SkipSweepConfidence is the upright confidence needed to skip phase 2 (0 uses the confidence threshold).
+	SkipSweepConfidence float64
+	// SkipSweepMinTokens is the upright token count needed to skip phase 2 (0 uses the minimum token count).
+	SkipSweepMinTokens int
+	// SkewClamp is the estimated skew in degrees below which the phase 2 sweep is pruned (0 disables pruning).
+	SkewClamp int
+	// SkipRotation disables the phase 2 rotation search for documents known to be upright.
+	SkipRotation bool
+	// Angles is a comma-separated list of phase 2 rotation angles replacing the estimated candidates.
+	Angles string
+	// Deskew straightens text lines skewed by up to 15 degrees before the first OCR pass.
+	Deskew bool
+	// RotationFill is the fill of corners uncovered by rotation: white or border (median border color).
+	RotationFill string
+	// OSD takes the phase 2 angle from Tesseract orientation and script detection when it is confident.
+	OSD bool
+	// OSDMinConfidence is the OSD confidence needed to skip the phase 2 search (0 uses the default).
+	OSDMinConfidence float64
+	// EngineRetries is the number of retries of transient OCR engine failures.
+	EngineRetries int
+	// CoarseScale is the downscale factor for the phase 2 coarse angle search (0 disables it).
+	CoarseScale float64
+	// NormalizeWords enables whitespace, control character and NFC normalization of recognized words.
+	NormalizeWords bool
+	// ContentHash adds a hash of the uploaded bytes to classify results.
+	ContentHash bool
+	// HashAlgorithm is the content hash algorithm: md5, sha1, sha256 or sha512.
+	HashAlgorithm string
+	// AngleCurve registers the confidence-by-angle tuning endpoint.
+	AngleCurve bool
+	// DebugStages registers the endpoint returning the intermediate preprocessing images.
+	DebugStages bool
+	// HeaderParams makes classify also read request parameters from X-OCR-* headers, query parameters taking precedence.
+	HeaderParams bool
+	// RequireContentLength makes classify reject uploads without a Content-Length with 411.
+	RequireContentLength bool
+	// MaxImageBytes rejects uploads larger than this many bytes with 413 (0 disables the limit).
+	// It is read from OCR_MAX_IMAGE_BYTES, or MAX_IMAGE_BYTES if that is unset.
+	MaxImageBytes int
+	// MaxImagePixels rejects images with more pixels than this with 413 before decoding (0 disables the limit).
+	// It defaults to 16 times the scale ceiling (ScaleMaxMegapixels).
+	MaxImagePixels int
+	// MaxBatchSize is the maximum number of images in one batch classify request.
+	MaxBatchSize int
+	// BatchParallelism limits concurrently recognized batch images (0 means GOMAXPROCS).
+	BatchParallelism int
+	// BatchDecodeParallelism limits batch images decoded and preprocessed concurrently ahead of recognition (0 means GOMAXPROCS).
+	BatchDecodeParallelism int
+	// SniffContentType makes classify accept any declared content type and detect the format from the bytes.
+	SniffContentType bool
+	// RejectMultiFrame makes classify reject multi-frame images instead of processing the first frame.
+	RejectMultiFrame bool
+	// ArchiveDir is the directory classified images and results are archived to (empty disables archiving).
+	ArchiveDir string
+	// ArchiveQueueSize is the number of classifications waiting to be archived, beyond which new ones are dropped.
+	ArchiveQueueSize int
+	// MinTempSpaceMB is the free temp space the health check requires (0 disables the check).
+	MinTempSpaceMB int
 }
 
 // Load loads configuration from environment variables.
-// Defaults to port 8080 if PORT is not set.
+// Defaults to port 8080 if PORT is not set and to "eng+rus" if OCR_DEFAULT_LANG is not set.
 func Load() *Config {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
+	defaultLang := os.Getenv("OCR_DEFAULT_LANG")
+	if defaultLang == "" {
+		defaultLang = "eng+rus"
+	}
+	scaleMaxMegapixels := getEnvFloat("OCR_SCALE_MAX_MEGAPIXELS", 0)
 	return &Config{
-		Port: port,
+		Port:                    port,
+		FlipCheck:               getEnvBool("OCR_FLIP_CHECK", false),
+		DefaultLanguage:         defaultLang,
+		DedupIoU:                getEnvFloat("OCR_DEDUP_IOU", 0),
+		Orientation:             getEnv("OCR_ORIENTATION", "sweep"),
+		SoftFail:                getEnvBool("OCR_SOFT_FAIL", false),
+		NoTextStatus:            getEnvInt("OCR_NO_TEXT_STATUS", 200),
+		SnapTolerance:           getEnvInt("OCR_ANGLE_SNAP_TOLERANCE", 0),
+		RotationMargin:          getEnvFloat("OCR_ROTATION_MARGIN", 0),
+		ConfidenceThreshold:     getEnvFloat("OCR_CONFIDENCE_THRESHOLD", 0),
+		MaxConfidenceThreshold:  getEnvFloat("OCR_MAX_CONFIDENCE_THRESHOLD", 0),
+		LanguageThresholds:      getEnv("OCR_LANG_CONFIDENCE_THRESHOLDS", ""),
+		AutoLanguageParallelism: getEnvInt("OCR_AUTO_LANG_PARALLELISM", 0),
+		ReportThreshold:         getEnvBool("OCR_REPORT_THRESHOLD", false),
+		MultiScale:              getEnvBool("OCR_MULTI_SCALE", false),
+		ScaleTiers:              getEnv("OCR_SCALE_TIERS", ""),
+		ScaleMaxMegapixels:      scaleMaxMegapixels,
+		PDFDPI:                  getEnvFloat("OCR_PDF_DPI", 300),
+		ScriptDominance:         getEnvFloat("OCR_SCRIPT_DOMINANCE", 0.6),
+		Equalize:                getEnvBool("OCR_EQUALIZE", false),
+		BackgroundBand:          getEnvInt("OCR_BACKGROUND_BAND", 0),
+		PreprocessPreset:        getEnv("OCR_PREPROCESS_PRESET", "scan"),
+		PreprocessChain:         getEnv("OCR_PREPROCESS_CHAIN", ""),
+		ChainMinTokens:          getEnvInt("OCR_PREPROCESS_CHAIN_MIN_TOKENS", 0),
+		ChainMaxAttempts:        getEnvInt("OCR_PREPROCESS_CHAIN_MAX_ATTEMPTS", 0),
+		ChainOnRecognitionFail:  getEnvBool("OCR_PREPROCESS_CHAIN_ON_RECOGNITION_FAILED", false),
+		MaxSweepPasses:          getEnvInt("OCR_MAX_SWEEP_PASSES", 0),
+		ConfidencePrecision:     getEnvInt("OCR_CONFIDENCE_PRECISION", 4),
+		Engine:                  getEnv("OCR_ENGINE", "tesseract"),
+		OEM:                     getEnv("OCR_OEM", "default"),
+		PSM:                     getEnv("OCR_PSM", ""),
+		TextLines:               getEnvBool("OCR_TEXT_LINES", false),
+		RepeatThreshold:         getEnvInt("OCR_REPEAT_THRESHOLD", 0),
+		Blocks:                  getEnvBool("OCR_BLOCKS", false),
+		OriginalBoxes:           getEnvBool("OCR_ORIGINAL_BOXES", false),
+		CompressIntermediate:    getEnvBool("OCR_COMPRESS_INTERMEDIATE", false),
+		QualityWarnings:         getEnvBool("OCR_QUALITY_WARNINGS", false),
+		MixedOrientation:        getEnvBool("OCR_MIXED_ORIENTATION", false),
+		TextColor:               getEnvBool("OCR_TEXT_COLOR", false),
+		PadSmallImages:          getEnvInt("OCR_PAD_SMALL_IMAGES", 0),
+		ColorModels:             getEnv("OCR_COLOR_MODELS", "normalize"),
+		Metrics:                 getEnvBool("OCR_METRICS", false),
+		LanguageFallback:        getEnvBool("OCR_LANG_FALLBACK", false),
+		ReportDPI:               getEnvBool("OCR_REPORT_DPI", false),
+		DPIHint:                 getEnvInt("OCR_DPI_HINT", 0),
+		SkipSweepConfidence:     getEnvFloat("OCR_SKIP_SWEEP_CONFIDENCE", 0),
+		SkipSweepMinTokens:      getEnvInt("OCR_SKIP_SWEEP_MIN_TOKENS", 0),
+		SkewClamp:               getEnvInt("OCR_SKEW_CLAMP", 0),
+		SkipRotation:            getEnvBool("OCR_SKIP_ROTATION", false),
+		Angles:                  getEnv("OCR_ANGLES", ""),
+		Deskew:                  getEnvBool("OCR_DESKEW", false),
+		RotationFill:            getEnv("OCR_ROTATION_FILL", "white"),
+		OSD:                     getEnvBool("OCR_OSD", false),
+		OSDMinConfidence:        getEnvFloat("OCR_OSD_MIN_CONFIDENCE", 0),
+		EngineRetries:           getEnvInt("OCR_ENGINE_RETRIES", 1),
+		CoarseScale:             getEnvFloat("OCR_COARSE_SCALE", 0),
+		NormalizeWords:          getEnvBool("OCR_NORMALIZE_WORDS", false),
+		ContentHash:             getEnvBool("OCR_CONTENT_HASH", false),
+		HashAlgorithm:           getEnv("OCR_CONTENT_HASH_ALGORITHM", "sha256"),
+		AngleCurve:              getEnvBool("OCR_ANGLE_CURVE", false),
+		DebugStages:             getEnvBool("OCR_DEBUG_STAGES", false),
+		HeaderParams:            getEnvBool("OCR_HEADER_PARAMS", false),
+		RequireContentLength:    getEnvBool("OCR_REQUIRE_CONTENT_LENGTH", false),
+		MaxImageBytes:           getEnvInt("OCR_MAX_IMAGE_BYTES", getEnvInt("MAX_IMAGE_BYTES", 20<<20)),
+		MaxImagePixels:          getEnvInt("OCR_MAX_IMAGE_PIXELS", defaultMaxImagePixels(scaleMaxMegapixels)),
+		MaxBatchSize:            getEnvInt("OCR_MAX_BATCH_SIZE", 32),
+		BatchParallelism:        getEnvInt("OCR_BATCH_PARALLELISM", 0),
+		BatchDecodeParallelism:  getEnvInt("OCR_BATCH_DECODE_PARALLELISM", 0),
+		SniffContentType:        getEnvBool("OCR_SNIFF_CONTENT_TYPE", false),
+		RejectMultiFrame:        getEnvBool("OCR_REJECT_MULTIFRAME", false),
+		ArchiveDir:              getEnv("OCR_ARCHIVE_DIR", ""),
+		ArchiveQueueSize:        getEnvInt("OCR_ARCHIVE_QUEUE_SIZE", 100),
+		MinTempSpaceMB:          getEnvInt("OCR_MIN_TEMP_SPACE_MB", 0),
+	}
+}
+
+// maxImagePixelsPerScaleCeiling is the default OCR_MAX_IMAGE_PIXELS as a multiple of the
+// scale ceiling: larger images are scaled down to the ceiling before OCR, so beyond that
+// they only cost decoding memory. 16 times the 3 MP default still admits 48 MP photos.
+const maxImagePixelsPerScaleCeiling = 16
+
+// defaultMaxImagePixels returns the default pixel cap for the scale ceiling of
+// OCR_SCALE_MAX_MEGAPIXELS (0 means the default of 3 MP), with megapixels of 2^20
+// pixels as in the scale curve.
+func defaultMaxImagePixels(scaleMaxMegapixels float64) int {
+	if scaleMaxMegapixels <= 0 {
+		scaleMaxMegapixels = 3
+	}
+	return int(maxImagePixelsPerScaleCeiling * scaleMaxMegapixels * (1 << 20))
+}
+
+// getEnv reads a string environment variable, returning def if it is unset or empty.
+func getEnv(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
+
+// getEnvBool reads a boolean environment variable, returning def if it is unset or invalid.
+func getEnvBool(key string, def bool) bool {
+	if val, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return val
+	}
+	return def
+}
+
+// getEnvInt reads an integer environment variable, returning def if it is unset or invalid.
+func getEnvInt(key string, def int) int {
+	if val, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return val
+	}
+	return def
+}
+
+// getEnvFloat reads a float environment variable, returning def if it is unset or invalid.
+func getEnvFloat(key string, def float64) float64 {
+	if val, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return val
 	}
+	return def
 }
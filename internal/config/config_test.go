@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+func TestLoadImageLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantBytes  int
+		wantPixels int
+	}{
+		{name: "defaults", wantBytes: 20 << 20, wantPixels: 16 * 3 << 20},
+		{name: "unprefixed bytes", env: map[string]string{"MAX_IMAGE_BYTES": "1024"}, wantBytes: 1024, wantPixels: 16 * 3 << 20},
+		{
+			name:      "prefixed bytes take precedence",
+			env:       map[string]string{"MAX_IMAGE_BYTES": "1024", "OCR_MAX_IMAGE_BYTES": "2048"},
+			wantBytes: 2048, wantPixels: 16 * 3 << 20,
+		},
+		{name: "pixels follow the scale ceiling", env: map[string]string{"OCR_SCALE_MAX_MEGAPIXELS": "2"}, wantBytes: 20 << 20, wantPixels: 16 * 2 << 20},
+		{name: "explicit pixels", env: map[string]string{"OCR_MAX_IMAGE_PIXELS": "0"}, wantBytes: 20 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"MAX_IMAGE_BYTES", "OCR_MAX_IMAGE_BYTES", "OCR_MAX_IMAGE_PIXELS", "OCR_SCALE_MAX_MEGAPIXELS"} {
+				t.Setenv(key, tt.env[key])
+			}
+			cfg := Load()
+			if cfg.MaxImageBytes != tt.wantBytes || cfg.MaxImagePixels != tt.wantPixels {
+				t.Errorf("limits = %d bytes, %d pixels; want %d, %d", cfg.MaxImageBytes, cfg.MaxImagePixels, tt.wantBytes, tt.wantPixels)
+			}
+		})
+	}
+}
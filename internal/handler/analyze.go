@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ocr-classifier/internal/service"
+)
+
+// Analyze reports the scale factor and preprocessing that would be applied to an image,
+// without running OCR. It accepts the same POST bodies and content types as Classify.
+func (h *ClassifyHandler) Analyze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "method not allowed, use POST"}); err != nil {
+			fmt.Fprintf(w, `{"error":"method not allowed, use POST"}`)
+		}
+		return
+	}
+
+	// Check content type
+	contentType := r.Header.Get("Content-Type")
+	if !service.IsSupportedContentType(contentType) {
+		msg := "content-type must be one of: " + strings.Join(service.SupportedContentTypes(), ", ")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+			fmt.Fprintf(w, `{"error":%q}`, msg)
+		}
+		return
+	}
+
+	// Read image data
+	imageData, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to read image data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to read image data"}`)
+		}
+		return
+	}
+	defer r.Body.Close()
+
+	if len(imageData) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "empty image data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"empty image data"}`)
+		}
+		return
+	}
+
+	analysis, err := h.classifier.AnalyzeImage(imageData)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to decode image"}); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to decode image"}`)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(analysis); err != nil {
+		fmt.Fprintf(w, `{"error":"failed to encode response"}`)
+	}
+}
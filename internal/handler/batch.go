@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"ocr-classifier/internal/service"
+)
+
+// errBatchTooLarge is returned while reading a batch with more than OCR_MAX_BATCH_SIZE images.
+var errBatchTooLarge = errors.New("batch too large")
+
+// BatchItem is the outcome of one image of a batch classify request.
+// Exactly one of Result, Pages and Error is set.
+type BatchItem struct {
+	// Filename is the file name of the form part the image was sent in.
+	Filename string `json:"filename"`
+	// Result is the classification result of a single-page image.
+	Result *service.ClassifierResult `json:"result,omitempty"`
+	// Pages holds one result per page of a multi-page TIFF or PDF document.
+	Pages []*service.ClassifierResult `json:"pages,omitempty"`
+	// Error describes why the image could not be classified.
+	Error string `json:"error,omitempty"`
+	// Code is a machine-readable error code, as in ErrorResponse.
+	Code string `json:"code,omitempty"`
+}
+
+// Batch handles batch classification requests. Every file part of a multipart/form-data
+// body is classified with the request parameters and the response is a JSON array with
+// one item per file part in request order. The images go through two worker pools: up
+// to OCR_BATCH_DECODE_PARALLELISM images are decoded and preprocessed while up to
+// OCR_BATCH_PARALLELISM are recognized, so the OCR clients do not wait for the CPU-bound
+// preparation of the next images. An image that fails is reported in its own item and
+// does not fail the batch.
+func (h *ClassifyHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "method not allowed, use POST"}); err != nil {
+			fmt.Fprintf(w, `{"error":"method not allowed, use POST"}`)
+		}
+		return
+	}
+
+	if !isMultipart(r.Header.Get("Content-Type")) {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "content-type must be multipart/form-data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"content-type must be multipart/form-data"}`)
+		}
+		return
+	}
+
+	// Every image is bounded by OCR_MAX_IMAGE_BYTES, so the whole batch is bounded too
+	var limit int64
+	if h.maxBytes > 0 {
+		limit = h.maxBytes * int64(h.batchMax)
+	}
+	if !limitBody(w, r, limit) {
+		return
+	}
+
+	params, ok := h.parseParams(w, r)
+	if !ok {
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to read image data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to read image data"}`)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Prepared images are handed from the decode pool to the OCR pool
+	decodeJobs := make(chan batchJob)
+	ocrJobs := make(chan batchJob)
+	var decodeWG, ocrWG sync.WaitGroup
+	for i := poolSize(h.decodePar); i > 0; i-- {
+		decodeWG.Add(1)
+		go func() {
+			defer decodeWG.Done()
+			for job := range decodeJobs {
+				if ctx.Err() != nil {
+					job.item.Error = "classification canceled"
+					continue
+				}
+				if !h.prepareBatchItem(&job, params) {
+					continue
+				}
+				select {
+				case ocrJobs <- job:
+				case <-ctx.Done():
+					job.item.Error = "classification canceled"
+				}
+			}
+		}()
+	}
+	for i := poolSize(h.batchPar); i > 0; i-- {
+		ocrWG.Add(1)
+		go func() {
+			defer ocrWG.Done()
+			for job := range ocrJobs {
+				h.classifyBatchItem(ctx, job, params)
+			}
+		}()
+	}
+
+	// Parts are read one at a time while earlier images are being classified
+	var items []*BatchItem
+	readErr := func() error {
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if part.FileName() == "" {
+				part.Close()
+				continue
+			}
+			if len(items) == h.batchMax {
+				part.Close()
+				return errBatchTooLarge
+			}
+			item := &BatchItem{Filename: part.FileName()}
+			items = append(items, item)
+			imageData, err := readBatchPart(part, h.maxBytes)
+			part.Close()
+			if err != nil {
+				return err
+			}
+			if h.maxBytes > 0 && int64(len(imageData)) > h.maxBytes {
+				item.Error = fmt.Sprintf("image data exceeds %d bytes", h.maxBytes)
+				continue
+			}
+
+			select {
+			case decodeJobs <- batchJob{item: item, imageData: imageData}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}()
+	if readErr != nil {
+		// The batch is rejected as a whole: stop the images still being classified
+		cancel()
+	}
+	close(decodeJobs)
+	decodeWG.Wait()
+	close(ocrJobs)
+	ocrWG.Wait()
+
+	if r.Context().Err() != nil {
+		// The client went away: recognition was stopped and nobody reads the response
+		return
+	}
+	if isBodyTooLarge(readErr) {
+		writeBodyTooLarge(w, limit)
+		return
+	}
+	if errors.Is(readErr, errBatchTooLarge) {
+		msg := fmt.Sprintf("batch must not contain more than %d images", h.batchMax)
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+			fmt.Fprintf(w, `{"error":%q}`, msg)
+		}
+		return
+	}
+	if readErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to read image data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to read image data"}`)
+		}
+		return
+	}
+	if len(items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "multipart form has no file parts"}); err != nil {
+			fmt.Fprintf(w, `{"error":"multipart form has no file parts"}`)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		fmt.Fprintf(w, `{"error":"failed to encode response"}`)
+	}
+}
+
+// readBatchPart reads a form part, stopping one byte past limit (0 means no limit) so
+// that an oversized image is detected without buffering all of it.
+func readBatchPart(part io.Reader, limit int64) ([]byte, error) {
+	if limit > 0 {
+		part = io.LimitReader(part, limit+1)
+	}
+	return io.ReadAll(part)
+}
+
+// batchJob is a batch image passed through the worker pools.
+type batchJob struct {
+	item      *BatchItem
+	imageData []byte
+	// rule is the decision rule of the image, carrying the prepared image.
+	rule service.DecisionRule
+}
+
+// poolSize returns the number of workers of a batch pool, GOMAXPROCS if size is 0.
+func poolSize(size int) int {
+	if size <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return size
+}
+
+// prepareBatchItem validates a batch image and decodes and preprocesses it for the OCR
+// pool. It reports false if the image failed, with the error stored in the item.
+func (h *ClassifyHandler) prepareBatchItem(job *batchJob, params *classifyParams) bool {
+	item, imageData := job.item, job.imageData
+	if len(imageData) == 0 {
+		item.Error = "empty image data"
+		return false
+	}
+	// Form parts carry no reliable content type, the format is sniffed from the data
+	if service.SniffContentType(imageData) == "" {
+		item.Error = "image data must be in one of: " + strings.Join(service.SupportedContentTypes(), ", ")
+		return false
+	}
+	if h.oneFrame {
+		if err := service.CheckSingleFrame(imageData); errors.Is(err, service.ErrMultiFrame) {
+			item.Error, item.Code = err.Error(), CodeMultiFrame
+			return false
+		}
+	}
+
+	rule := params.rule
+	if rule.Timing != nil {
+		// Each image records its own stages
+		rule.Timing = service.NewTiming()
+	}
+	job.rule = h.classifier.PrepareImage(imageData, rule)
+	return true
+}
+
+// classifyBatchItem recognizes one prepared batch image and stores the outcome in its item.
+// Errors are reported as in Classify, but in the item instead of the response status.
+func (h *ClassifyHandler) classifyBatchItem(ctx context.Context, job batchJob, params *classifyParams) {
+	item, imageData, rule := job.item, job.imageData, job.rule
+	start := time.Now()
+	results, err := h.classifier.DetectTextPagesContext(ctx, imageData, rule)
+	if err != nil && ctx.Err() != nil {
+		item.Error = "classification canceled"
+		return
+	}
+	h.metrics.ObserveRequest(rule.Language, time.Since(start), err != nil)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnsupportedColorModel):
+			item.Error, item.Code = err.Error(), CodeUnsupportedColorModel
+			return
+		case errors.Is(err, service.ErrEncryptedPDF):
+			item.Error, item.Code = err.Error(), CodeEncryptedPDF
+			return
+		case errors.Is(err, service.ErrInvalidROI),
+			errors.Is(err, service.ErrImageTooLarge),
+			errors.Is(err, service.ErrInvalidPDF):
+			item.Error = err.Error()
+			return
+		case !h.softFail:
+			item.Error = "failed to process image"
+			return
+		}
+		// Soft fail: report the error inside a zero-confidence result
+		results = []*service.ClassifierResult{service.NewErrorResult(err)}
+	}
+	for i, result := range results {
+		results[i] = h.finishResult(imageData, result, params)
+	}
+	if len(results) > 1 {
+		item.Pages = results
+		return
+	}
+	item.Result = results[0]
+}
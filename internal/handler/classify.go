@@ -2,41 +2,180 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/otiai10/gosseract/v2"
+	"ocr-classifier/internal/config"
 	"ocr-classifier/internal/service"
 )
 
 // ClassifyHandler handles image classification requests.
 type ClassifyHandler struct {
 	classifier *service.Classifier
+	softFail   bool
+	precision  int
+	equalize   bool
+	band       uint8
+	archiver   *service.QueuedSink
+	sniff      bool
+	oneFrame   bool
+	metrics    service.Metrics
+	needLen    bool
+	noText     int
+	headers    bool
+	maxBytes   int64
+	batchMax   int
+	batchPar   int
+	decodePar  int
 }
 
-// NewClassifyHandler creates a new ClassifyHandler instance.
-func NewClassifyHandler() *ClassifyHandler {
-	return &ClassifyHandler{
-		classifier: service.NewClassifier(),
+// NewClassifyHandler creates a new ClassifyHandler instance configured from cfg.
+// cfg.PreprocessPreset, cfg.PreprocessChain, cfg.LanguageThresholds, cfg.Angles and
+// the scale tiers are expected to be validated by the caller.
+func NewClassifyHandler(cfg *config.Config) *ClassifyHandler {
+	preprocess, _ := service.PreprocessPreset(cfg.PreprocessPreset)
+	chain, _ := service.ParsePreprocessChain(cfg.PreprocessChain)
+	languageConfidence, _ := service.ParseLanguageThresholds(cfg.LanguageThresholds)
+	angles, _ := service.ParseAngles(cfg.Angles)
+	scaleTiers, _ := service.ParseScaleTiers(cfg.ScaleTiers, cfg.ScaleMaxMegapixels)
+	contentHash := ""
+	if cfg.ContentHash {
+		contentHash = cfg.HashAlgorithm
 	}
+	metrics := service.NewMetrics(cfg.Metrics)
+	preprocess.Equalize = preprocess.Equalize || cfg.Equalize
+	preprocess.BackgroundBand = uint8(min(max(cfg.BackgroundBand, 0), 255))
+	for i := range chain {
+		chain[i].Options.Equalize = chain[i].Options.Equalize || cfg.Equalize
+		chain[i].Options.BackgroundBand = preprocess.BackgroundBand
+	}
+
+	h := &ClassifyHandler{
+		classifier: service.NewClassifierWithOptions(service.Options{
+			FlipCheck:               cfg.FlipCheck,
+			DefaultLanguage:         cfg.DefaultLanguage,
+			DedupIoU:                cfg.DedupIoU,
+			Orientation:             cfg.Orientation,
+			SnapTolerance:           cfg.SnapTolerance,
+			RotationMargin:          cfg.RotationMargin,
+			MinConfidence:           cfg.ConfidenceThreshold,
+			MaxConfidence:           cfg.MaxConfidenceThreshold,
+			LanguageConfidence:      languageConfidence,
+			AutoLanguageParallelism: cfg.AutoLanguageParallelism,
+			ScriptDominance:         cfg.ScriptDominance,
+			MaxSweepPasses:          cfg.MaxSweepPasses,
+			Engine:                  cfg.Engine,
+			OEM:                     cfg.OEM,
+			PSM:                     cfg.PSM,
+			TextLines:               cfg.TextLines,
+			Blocks:                  cfg.Blocks,
+			OriginalBoxes:           cfg.OriginalBoxes,
+			RepeatThreshold:         cfg.RepeatThreshold,
+			CompressIntermediate:    cfg.CompressIntermediate,
+			QualityWarnings:         cfg.QualityWarnings,
+			MixedOrientation:        cfg.MixedOrientation,
+			MultiScale:              cfg.MultiScale,
+			ReportThreshold:         cfg.ReportThreshold,
+			TextColor:               cfg.TextColor,
+			MaxPixels:               cfg.MaxImagePixels,
+			PDFDPI:                  cfg.PDFDPI,
+			PadSmallImages:          cfg.PadSmallImages,
+			ReportDPI:               cfg.ReportDPI,
+			LanguageFallback:        cfg.LanguageFallback,
+			ColorModels:             cfg.ColorModels,
+			DPIHint:                 cfg.DPIHint,
+			SkipSweepConfidence:     cfg.SkipSweepConfidence,
+			SkipSweepMinTokens:      cfg.SkipSweepMinTokens,
+			SkewClamp:               cfg.SkewClamp,
+			SkipRotation:            cfg.SkipRotation,
+			Angles:                  angles,
+			Deskew:                  cfg.Deskew,
+			RotationFill:            cfg.RotationFill,
+			OSD:                     cfg.OSD,
+			OSDMinConfidence:        cfg.OSDMinConfidence,
+			EngineRetries:           cfg.EngineRetries,
+			CoarseScale:             cfg.CoarseScale,
+			NormalizeWords:          cfg.NormalizeWords,
+			ContentHash:             contentHash,
+			Preprocess:              preprocess,
+			ScaleTiers:              scaleTiers,
+			PreprocessChain:         chain,
+			ChainMinTokens:          cfg.ChainMinTokens,
+			ChainMaxAttempts:        cfg.ChainMaxAttempts,
+			ChainOnRecognitionFail:  cfg.ChainOnRecognitionFail,
+		}),
+		softFail:  cfg.SoftFail,
+		precision: cfg.ConfidencePrecision,
+		equalize:  cfg.Equalize,
+		band:      preprocess.BackgroundBand,
+		sniff:     cfg.SniffContentType,
+		oneFrame:  cfg.RejectMultiFrame,
+		metrics:   metrics,
+		needLen:   cfg.RequireContentLength,
+		noText:    cfg.NoTextStatus,
+		headers:   cfg.HeaderParams,
+		maxBytes:  int64(cfg.MaxImageBytes),
+		batchMax:  cfg.MaxBatchSize,
+		batchPar:  cfg.BatchParallelism,
+		decodePar: cfg.BatchDecodeParallelism,
+	}
+	if cfg.ArchiveDir != "" {
+		h.archiver = service.NewQueuedSink(service.NewResultSink(cfg.ArchiveDir), cfg.ArchiveQueueSize, metrics)
+	}
+	_, missing := h.classifier.InstalledLanguages()
+	for _, code := range missing {
+		log.Printf("Warning: language %s is supported but its traineddata is not installed", code)
+	}
+	if err := h.classifier.CheckOSD(); err != nil {
+		log.Printf("Warning: OCR_OSD is disabled: %v", err)
+	}
+	return h
+}
+
+// Close stores the queued archive jobs and releases the classifier resources.
+// It is called on server shutdown.
+func (h *ClassifyHandler) Close() error {
+	if h.archiver != nil {
+		h.archiver.Close()
+	}
+	return h.classifier.Close()
 }
 
 // ErrorResponse represents an error response in JSON format.
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Code is a machine-readable error code, set only for errors clients may act on.
+	Code string `json:"code,omitempty"`
 }
 
+// CodeMultiFrame is the error code returned for multi-frame images when OCR_REJECT_MULTIFRAME is set.
+const CodeMultiFrame = "multiframe_not_supported"
+
+// CodeUnsupportedColorModel is the error code returned for CMYK and 16-bit images when OCR_COLOR_MODELS=reject.
+const CodeUnsupportedColorModel = "unsupported_color_model"
+
+// CodeLanguageUnavailable is the error code returned when the data of a requested language is not installed.
+const CodeLanguageUnavailable = "language_unavailable"
+
+// CodeEncryptedPDF is the error code returned for password-protected PDF documents.
+const CodeEncryptedPDF = "pdf_encrypted"
+
 // parsePageIteratorLevel parses a string level name to gosseract PageIteratorLevel constant.
-// Accepts names like "RIL_BLOCK", "RIL_PARA", "RIL_TEXTLINE", "RIL_WORD", "RIL_SYMBOL".
+// Accepts names like "RIL_BLOCK", "RIL_PARA", "RIL_TEXTLINE", "RIL_WORD", "RIL_SYMBOL",
+// and the granularity names "block", "paragraph", "line", "word" and "symbol".
 func parsePageIteratorLevel(level string) (gosseract.PageIteratorLevel, error) {
 	switch level {
 	case "RIL_BLOCK", "block":
 		return gosseract.RIL_BLOCK, nil
-	case "RIL_PARA", "para":
+	case "RIL_PARA", "para", "paragraph":
 		return gosseract.RIL_PARA, nil
-	case "RIL_TEXTLINE", "textline":
+	case "RIL_TEXTLINE", "textline", "line":
 		return gosseract.RIL_TEXTLINE, nil
 	case "RIL_WORD", "word":
 		return gosseract.RIL_WORD, nil
@@ -48,9 +187,20 @@ func parsePageIteratorLevel(level string) (gosseract.PageIteratorLevel, error) {
 }
 
 // Classify processes image classification requests.
-// It accepts POST requests with image/jpeg or image/png content type.
-// Optional query parameters: confidence_threshold (0-1), min_token_count (positive integer),
-// lang (comma-separated language codes, default: "eng+rus"), level (PageIteratorLevel name).
+// It accepts POST requests with any content type registered in service.SupportedContentTypes,
+// or with any content type if OCR_SNIFF_CONTENT_TYPE is set and the bytes are in a supported format.
+// Optional query parameters: confidence_threshold (in (0, 1]), min_token_count (positive integer),
+// lang (comma-separated language codes, default: "eng+rus"), level (PageIteratorLevel name),
+// oem (Tesseract engine mode, default: OCR_OEM), psm (page segmentation mode name, default: OCR_PSM),
+// preprocess (preprocessing preset name, default: OCR_PREPROCESS_PRESET),
+// extract (set to "digits" to add numeric candidates to the result),
+// coords (set to "preprocessed" to return boxes in the coordinates of the OCR input),
+// region (normalized x,y,w,h; only boxes centered within it are returned),
+// text_format ("lines", the default, or "flat": the format of the recognized text),
+// debug (set to "timing" to add pipeline stage durations to the result).
+// lang missing from the query is read from the X-OCR-Lang header; if OCR_HEADER_PARAMS is set,
+// so are the other parameters from X-OCR-* headers.
+// Multi-page documents (TIFF) are answered with a JSON array of per-page results.
 func (h *ClassifyHandler) Classify(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -62,18 +212,44 @@ func (h *ClassifyHandler) Classify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check content type
+	// Check content type (sniffed from the body instead when enabled or for form uploads)
 	contentType := r.Header.Get("Content-Type")
-	if contentType != "image/jpeg" && contentType != "image/png" {
+	multipartUpload := isMultipart(contentType)
+	if !multipartUpload && !h.sniff && !service.IsSupportedContentType(contentType) {
+		msg := "content-type must be one of: " + strings.Join(service.SupportedContentTypes(), ", ")
 		w.WriteHeader(http.StatusBadRequest)
-		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "content-type must be image/jpeg or image/png"}); err != nil {
-			fmt.Fprintf(w, `{"error":"content-type must be image/jpeg or image/png"}`)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+			fmt.Fprintf(w, `{"error":%q}`, msg)
 		}
 		return
 	}
 
-	// Read image data
-	imageData, err := io.ReadAll(r.Body)
+	// Reject chunked and other unknown-length uploads before reading them
+	if h.needLen && r.ContentLength < 0 {
+		w.WriteHeader(http.StatusLengthRequired)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "content-length required"}); err != nil {
+			fmt.Fprintf(w, `{"error":"content-length required"}`)
+		}
+		return
+	}
+
+	if !limitBody(w, r, h.maxBytes) {
+		return
+	}
+
+	// Read image data, either the raw body or the image part of a form upload
+	var imageData []byte
+	var err error
+	filePart := true
+	if multipartUpload {
+		imageData, filePart, err = readMultipartImage(r)
+	} else {
+		imageData, err = io.ReadAll(r.Body)
+	}
+	if isBodyTooLarge(err) {
+		writeBodyTooLarge(w, h.maxBytes)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to read image data"}); err != nil {
@@ -83,6 +259,14 @@ func (h *ClassifyHandler) Classify(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	if !filePart {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "multipart form has no image or file part"}); err != nil {
+			fmt.Fprintf(w, `{"error":"multipart form has no image or file part"}`)
+		}
+		return
+	}
+
 	if len(imageData) == 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "empty image data"}); err != nil {
@@ -91,41 +275,121 @@ func (h *ClassifyHandler) Classify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse query parameters with defaults
-	decisionRule := service.GetDefaultDecisionRule()
-
-	// Parse lang from URL parameter (default: "eng+rus")
-	if lang := r.URL.Query().Get("lang"); lang != "" {
-		decisionRule.Language = lang
+	if (h.sniff || multipartUpload) && service.SniffContentType(imageData) == "" {
+		msg := "image data must be in one of: " + strings.Join(service.SupportedContentTypes(), ", ")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+			fmt.Fprintf(w, `{"error":%q}`, msg)
+		}
+		return
 	}
 
-	// Parse level from URL parameter (default: RIL_WORD)
-	if level := r.URL.Query().Get("level"); level != "" {
-		if levelInt, err := parsePageIteratorLevel(level); err == nil {
-			decisionRule.Level = &levelInt
+	// Reject multi-frame images; a failed frame count is not fatal, the first frame is processed
+	if h.oneFrame {
+		err := service.CheckSingleFrame(imageData)
+		if errors.Is(err, service.ErrMultiFrame) {
+			msg := err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Code: CodeMultiFrame}); err != nil {
+				fmt.Fprintf(w, `{"error":%q,"code":%q}`, msg, CodeMultiFrame)
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("failed to check image frames: %v", err)
 		}
 	}
 
-	// Parse confidence_threshold from URL parameter
-	if thresholdStr := r.URL.Query().Get("confidence_threshold"); thresholdStr != "" {
-		if val, err := strconv.ParseFloat(thresholdStr, 64); err == nil && val > 0 && val <= 1 {
-			decisionRule.MinConfidence = val
-		}
+	params, ok := h.parseParams(w, r)
+	if !ok {
+		return
 	}
 
-	// Parse min_token_count from URL parameter
-	if tokenCountStr := r.URL.Query().Get("min_token_count"); tokenCountStr != "" {
-		if val, err := strconv.Atoi(tokenCountStr); err == nil && val > 0 {
-			decisionRule.MinTokenCount = val
+	// Perform classification; multi-page documents yield one result per page
+	start := time.Now()
+	results, err := h.classifier.DetectTextPagesContext(r.Context(), imageData, params.rule)
+	if err != nil && r.Context().Err() != nil {
+		// The client went away: recognition was stopped and nobody reads the response
+		return
+	}
+	h.metrics.ObserveRequest(params.rule.Language, time.Since(start), err != nil)
+	if errors.Is(err, service.ErrUnsupportedColorModel) {
+		msg := err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Code: CodeUnsupportedColorModel}); err != nil {
+			fmt.Fprintf(w, `{"error":%q,"code":%q}`, msg, CodeUnsupportedColorModel)
 		}
+		return
+	}
+	if errors.Is(err, service.ErrInvalidROI) {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+			fmt.Fprintf(w, `{"error":"invalid region of interest"}`)
+		}
+		return
+	}
+	if errors.Is(err, service.ErrImageTooLarge) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+			fmt.Fprintf(w, `{"error":"image too large to be processed"}`)
+		}
+		return
+	}
+	if errors.Is(err, service.ErrEncryptedPDF) {
+		msg := err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Code: CodeEncryptedPDF}); err != nil {
+			fmt.Fprintf(w, `{"error":%q,"code":%q}`, msg, CodeEncryptedPDF)
+		}
+		return
+	}
+	if errors.Is(err, service.ErrInvalidPDF) {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+			fmt.Fprintf(w, `{"error":"invalid PDF document"}`)
+		}
+		return
 	}
-
-	// Perform classification
-	result, err := h.classifier.DetectText(imageData, decisionRule)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to process image"}); err != nil {
-			fmt.Fprintf(w, `{"error":"failed to process image"}`)
+		if !h.softFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to process image"}); err != nil {
+				fmt.Fprintf(w, `{"error":"failed to process image"}`)
+			}
+			return
+		}
+		// Soft fail: report the error inside a zero-confidence result with 200 OK
+		results = []*service.ClassifierResult{service.NewErrorResult(err)}
+	}
+	for i, result := range results {
+		results[i] = h.finishResult(imageData, result, params)
+	}
+
+	if len(results) > 1 {
+		// Pages are always returned as a JSON array, whatever the Accept header
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to encode response"}`)
+		}
+		return
+	}
+	result := results[0]
+	if h.noText == http.StatusNoContent && result.Status == "" && result.TokenCount == 0 {
+		// No text found: the outcome is still counted and archived, but sent without a body
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if acceptsCSV(r) {
+		// Headers are already sent, so a write error can only be logged
+		if err := writeCSV(w, result); err != nil {
+			log.Printf("failed to write CSV response: %v", err)
+		}
+		return
+	}
+	if acceptsHOCR(r) {
+		if err := writeHOCR(w, result); err != nil {
+			log.Printf("failed to write hOCR response: %v", err)
 		}
 		return
 	}
@@ -135,3 +399,35 @@ func (h *ClassifyHandler) Classify(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, `{"error":"failed to encode response"}`)
 	}
 }
+
+// finishResult applies the response parameters to result, records it in the metrics
+// and queues it for archiving. It returns the result to send.
+func (h *ClassifyHandler) finishResult(imageData []byte, result *service.ClassifierResult, params *classifyParams) *service.ClassifierResult {
+	h.metrics.ObserveResult(result)
+	if params.langFallback {
+		result.Warnings = append(result.Warnings, service.WarningLanguageFallback)
+	}
+	if params.region != nil {
+		result.FilterRegion(*params.region)
+	}
+	if params.textFormat != "" || params.region != nil {
+		// The text of the boxes left by the region filter, in the requested format
+		format := params.textFormat
+		if format == "" {
+			format = service.TextFormatLines
+		}
+		result.Text = service.RecognizedText(result.Boxes, format)
+	}
+	if params.coords == service.CoordsPreprocessed {
+		result.ToPreprocessedSpace()
+	}
+	if params.extract == service.ExtractDigits {
+		result.Numbers = service.ExtractNumbers(result.Boxes)
+	}
+	result = service.RoundConfidences(result, h.precision)
+	if h.archiver != nil {
+		// Archiving does not affect the response: a full queue drops the job
+		h.archiver.Enqueue(imageData, result)
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"testing"
+
+	"ocr-classifier/internal/service"
+)
+
+func TestFinishResultText(t *testing.T) {
+	upperHalf, err := service.ParseRegion("0,0,1,0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		params classifyParams
+		want   string
+	}{
+		{name: "default lines", want: "hello world\nbye"},
+		{name: "lines", params: classifyParams{textFormat: service.TextFormatLines}, want: "hello world\nbye"},
+		{name: "flat", params: classifyParams{textFormat: service.TextFormatFlat}, want: "hello world bye"},
+		{name: "region", params: classifyParams{region: &upperHalf}, want: "hello world"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes := []service.BoundingBox{
+				{X: 0, Y: 0, Width: 40, Height: 10, Word: "hello", Confidence: 0.9},
+				{X: 50, Y: 0, Width: 40, Height: 10, Word: "world", Confidence: 0.9},
+				{X: 0, Y: 40, Width: 30, Height: 10, Word: "bye", Confidence: 0.9},
+			}
+			result := &service.ClassifierResult{
+				Boxes:             boxes,
+				Text:              service.RecognizedText(boxes, service.TextFormatLines),
+				ScaleFactor:       1,
+				BoundingBoxWidth:  100,
+				BoundingBoxHeight: 60,
+			}
+			h := &ClassifyHandler{metrics: service.NopMetrics{}}
+
+			if got := h.finishResult(nil, result, &tt.params).Text; got != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
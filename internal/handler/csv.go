@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ocr-classifier/internal/service"
+)
+
+// csvContentType is the media type of CSV responses.
+const csvContentType = "text/csv"
+
+// acceptsCSV reports whether the request explicitly asks for a CSV response.
+func acceptsCSV(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		if strings.TrimSpace(mediaType) == csvContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// csvText escapes recognized text for a CSV cell: text starting with a character that
+// spreadsheets read as the start of a formula (=, +, -, @, tab or carriage return) is
+// prefixed with a single quote, so opening the file cannot run an injected formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeCSV writes the result boxes as CSV rows with a header line.
+// Aggregate metrics are reported in X-OCR-* response headers.
+func writeCSV(w http.ResponseWriter, result *service.ClassifierResult) error {
+	header := w.Header()
+	header.Set("Content-Type", csvContentType+"; charset=utf-8")
+	header.Set("X-OCR-Mean-Confidence", strconv.FormatFloat(result.MeanConfidence, 'f', -1, 64))
+	header.Set("X-OCR-Weighted-Confidence", strconv.FormatFloat(result.WeightedConfidence, 'f', -1, 64))
+	header.Set("X-OCR-Token-Count", strconv.Itoa(result.TokenCount))
+	header.Set("X-OCR-Angle", strconv.Itoa(result.Angle))
+	header.Set("X-OCR-Scale-Factor", strconv.FormatFloat(result.ScaleFactor, 'f', -1, 64))
+	header.Set("X-OCR-Is-Text-Document", strconv.FormatBool(result.IsTextDocument))
+	if result.Status != "" {
+		header.Set("X-OCR-Status", result.Status)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"word", "x", "y", "width", "height", "confidence"}); err != nil {
+		return err
+	}
+	for _, box := range result.Boxes {
+		record := []string{
+			csvText(box.Word),
+			strconv.Itoa(box.X),
+			strconv.Itoa(box.Y),
+			strconv.Itoa(box.Width),
+			strconv.Itoa(box.Height),
+			strconv.FormatFloat(box.Confidence, 'f', -1, 64),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
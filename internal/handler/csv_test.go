@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"testing"
+
+	"ocr-classifier/internal/service"
+)
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "invoice", want: "invoice"},
+		{in: "", want: ""},
+		{in: "=SUM(A1:A9)", want: "'=SUM(A1:A9)"},
+		{in: "+7", want: "'+7"},
+		{in: "-5", want: "'-5"},
+		{in: "@cmd", want: "'@cmd"},
+		{in: "\tTAB", want: "'\tTAB"},
+		{in: "\rCR", want: "'\rCR"},
+		{in: "a=b", want: "a=b"},
+		{in: "счёт", want: "счёт"},
+	}
+	for _, tt := range tests {
+		if got := csvText(tt.in); got != tt.want {
+			t.Errorf("csvText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	result := &service.ClassifierResult{
+		Boxes: []service.BoundingBox{
+			{Word: "=HYPERLINK(\"http://x\",\"y\")", X: 1, Y: 2, Width: 3, Height: 4, Confidence: 0.9},
+			{Word: "total", X: 5, Y: 6, Width: 7, Height: 8, Confidence: 0.8},
+		},
+	}
+	rec := httptest.NewRecorder()
+	if err := writeCSV(rec, result); err != nil {
+		t.Fatalf("writeCSV failed: %v", err)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want a header and 2 rows", len(records))
+	}
+	if got := records[1][0]; got != "'=HYPERLINK(\"http://x\",\"y\")" {
+		t.Errorf("formula cell = %q, want it prefixed with a quote", got)
+	}
+	if got := records[2][0]; got != "total" {
+		t.Errorf("plain cell = %q, want total", got)
+	}
+}
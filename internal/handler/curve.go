@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ocr-classifier/internal/service"
+)
+
+// AngleCurve reports the confidence-by-angle series of an image (see service.Classifier.AngleCurve).
+// It is a tuning endpoint, registered only when OCR_ANGLE_CURVE is set. It accepts the same
+// POST bodies and content types as Classify. Optional query parameters: lang, roi.
+func (h *ClassifyHandler) AngleCurve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "method not allowed, use POST"}); err != nil {
+			fmt.Fprintf(w, `{"error":"method not allowed, use POST"}`)
+		}
+		return
+	}
+
+	// Check content type
+	contentType := r.Header.Get("Content-Type")
+	if !service.IsSupportedContentType(contentType) {
+		msg := "content-type must be one of: " + strings.Join(service.SupportedContentTypes(), ", ")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+			fmt.Fprintf(w, `{"error":%q}`, msg)
+		}
+		return
+	}
+
+	if !limitBody(w, r, h.maxBytes) {
+		return
+	}
+
+	// Read image data
+	imageData, err := io.ReadAll(r.Body)
+	if isBodyTooLarge(err) {
+		writeBodyTooLarge(w, h.maxBytes)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to read image data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to read image data"}`)
+		}
+		return
+	}
+	defer r.Body.Close()
+
+	if len(imageData) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "empty image data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"empty image data"}`)
+		}
+		return
+	}
+
+	rule := h.classifier.DefaultDecisionRule()
+
+	// Parse lang from URL parameter; only plain language strings are supported here
+	if lang := h.param(r, "lang"); lang != "" {
+		if err := service.ValidateLanguage(lang); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid language"}`)
+			}
+			return
+		}
+		rule.Language = lang
+	}
+
+	// Parse roi from URL parameter (x,y,w,h)
+	if roiStr := h.param(r, "roi"); roiStr != "" {
+		roi, err := service.ParseROI(roiStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid region of interest"}`)
+			}
+			return
+		}
+		rule.ROI = &roi
+	}
+
+	curve, err := h.classifier.AngleCurve(imageData, rule)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidROI) || errors.Is(err, service.ErrImageTooSmall) ||
+			errors.Is(err, service.ErrUnsupportedColorModel) {
+			status = http.StatusBadRequest
+		}
+		if errors.Is(err, service.ErrImageTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to process image"}`)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(curve); err != nil {
+		fmt.Fprintf(w, `{"error":"failed to encode response"}`)
+	}
+}
@@ -4,13 +4,59 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+
+	"ocr-classifier/internal/config"
 )
 
+// HealthResponse represents the health check response in JSON format.
+type HealthResponse struct {
+	Status string `json:"status"`
+	// TempDir and TempFreeBytes are reported only when the temp space check is enabled.
+	TempDir       string  `json:"temp_dir,omitempty"`
+	TempFreeBytes *uint64 `json:"temp_free_bytes,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// HealthHandler handles health check requests.
+type HealthHandler struct {
+	minTempSpace uint64
+}
+
+// NewHealthHandler creates a new HealthHandler instance configured from cfg.
+func NewHealthHandler(cfg *config.Config) *HealthHandler {
+	h := &HealthHandler{}
+	if cfg.MinTempSpaceMB > 0 {
+		h.minTempSpace = uint64(cfg.MinTempSpaceMB) << 20
+	}
+	return h
+}
+
 // HealthCheck handles health check requests.
 // Returns a simple status response indicating the service is operational.
-func HealthCheck(w http.ResponseWriter, r *http.Request) {
+// When OCR_MIN_TEMP_SPACE_MB is set, it also checks that the temp directory is writable
+// and has at least that much free space, responding 503 otherwise.
+func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
-		fmt.Fprintf(w, `{"status":"ok"}`)
+
+	resp := HealthResponse{Status: "ok"}
+	if h.minTempSpace > 0 {
+		resp.TempDir = os.TempDir()
+		free, err := checkTempSpace(resp.TempDir)
+		if err == nil {
+			resp.TempFreeBytes = &free
+			if free < h.minTempSpace {
+				err = fmt.Errorf("free temp space %d bytes is below %d bytes", free, h.minTempSpace)
+			}
+		}
+		if err != nil {
+			resp.Status = "unavailable"
+			resp.Error = err.Error()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Fprintf(w, `{"status":%q}`, resp.Status)
 	}
 }
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"ocr-classifier/internal/service"
+)
+
+// hocrContentType is the media type of hOCR responses.
+const hocrContentType = "text/vnd.hocr+html"
+
+// acceptsHOCR reports whether the request asks for an hOCR response,
+// via the Accept header or the format=hocr query parameter.
+func acceptsHOCR(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "hocr" {
+		return true
+	}
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		if strings.TrimSpace(mediaType) == hocrContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeHOCR writes the result as an hOCR document with one page, its lines and words.
+// Coordinates are in pixels of the original image.
+func writeHOCR(w http.ResponseWriter, result *service.ClassifierResult) error {
+	w.Header().Set("Content-Type", hocrContentType+"; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	page := result.OriginalSpacePage()
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+ <head>
+  <title></title>
+  <meta http-equiv="Content-Type" content="text/html;charset=utf-8"/>
+  <meta name="ocr-system" content="ocr-classifier"/>
+  <meta name="ocr-capabilities" content="ocr_page ocr_line ocrx_word"/>
+ </head>
+ <body>
+`)
+	fmt.Fprintf(&b, "  <div class=\"ocr_page\" id=\"page_1\" title=\"bbox %d %d %d %d\">\n",
+		page.Min.X, page.Min.Y, page.Max.X, page.Max.Y)
+
+	for i, line := range service.GroupLines(result.OriginalSpaceBoxes()) {
+		fmt.Fprintf(&b, "   <span class=\"ocr_line\" id=\"line_1_%d\" title=\"%s\">\n", i+1, hocrBBox(line.Box))
+		for j, word := range line.Words {
+			fmt.Fprintf(&b, "    <span class=\"ocrx_word\" id=\"word_1_%d_%d\" title=\"%s; x_wconf %d\">%s</span>\n",
+				i+1, j+1, hocrBBox(word), int(math.Round(word.Confidence*100)), html.EscapeString(word.Word))
+		}
+		b.WriteString("   </span>\n")
+	}
+
+	b.WriteString("  </div>\n </body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// hocrBBox formats a box as an hOCR bbox property (x0 y0 x1 y1).
+func hocrBBox(box service.BoundingBox) string {
+	return fmt.Sprintf("bbox %d %d %d %d", box.X, box.Y, box.X+box.Width, box.Y+box.Height)
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Languages returns the codes of the supported languages whose traineddata is installed,
+// as a sorted JSON array. Supported languages that are not installed are left out and
+// reported in the log at startup.
+func (h *ClassifyHandler) Languages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "method not allowed, use GET"}); err != nil {
+			fmt.Fprintf(w, `{"error":"method not allowed, use GET"}`)
+		}
+		return
+	}
+
+	installed, _ := h.classifier.InstalledLanguages()
+	if installed == nil {
+		installed = []string{}
+	}
+	if err := json.NewEncoder(w).Encode(installed); err != nil {
+		fmt.Fprintf(w, `{"error":"failed to encode response"}`)
+	}
+}
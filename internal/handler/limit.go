@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// limitBody enforces an upload limit of limit bytes (0 means none): a request declaring
+// a larger Content-Length is answered with 413 before its body is read, any other body
+// is wrapped in http.MaxBytesReader so that reading stops at the limit. It returns
+// false if the request has been answered.
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) bool {
+	if limit <= 0 {
+		return true
+	}
+	if r.ContentLength > limit {
+		writeBodyTooLarge(w, limit)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	return true
+}
+
+// isBodyTooLarge reports whether err comes from reading a body past the upload limit.
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// writeBodyTooLarge answers a request whose body exceeds limit bytes with 413.
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	msg := fmt.Sprintf("image data exceeds %d bytes", limit)
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+		fmt.Fprintf(w, `{"error":%q}`, msg)
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+)
+
+// multipartImageFields are the form field names accepted for the uploaded image.
+var multipartImageFields = []string{"image", "file"}
+
+// isMultipart reports whether contentType is multipart/form-data.
+func isMultipart(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// readMultipartImage streams the form in r and returns the contents of the first part
+// named image or file; found is false when the form has no such part. Other parts are
+// skipped without being buffered.
+func readMultipartImage(r *http.Request) (data []byte, found bool, err error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, false, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if slices.Contains(multipartImageFields, part.FormName()) {
+			data, err := io.ReadAll(part)
+			part.Close()
+			return data, true, err
+		}
+		part.Close()
+	}
+}
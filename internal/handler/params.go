@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ocr-classifier/internal/service"
+)
+
+// paramHeaderPrefix prefixes the request headers that may carry request parameters
+// when OCR_HEADER_PARAMS is set, for clients behind proxies that strip query strings.
+const paramHeaderPrefix = "X-OCR-"
+
+// paramHeaderAliases maps request parameters to the short headers also accepted for
+// them, after the header named by paramHeader.
+var paramHeaderAliases = map[string]string{
+	"confidence_threshold": paramHeaderPrefix + "Threshold",
+	"preprocess":           paramHeaderPrefix + "Mode",
+}
+
+// param returns the query parameter name of r. If it is empty and header parameters
+// are enabled, the value of the header named after it is returned instead, e.g.
+// X-OCR-Confidence-Threshold for confidence_threshold, or else of its alias header in
+// paramHeaderAliases. The language header X-OCR-Lang is read even with header
+// parameters disabled.
+func (h *ClassifyHandler) param(r *http.Request, name string) string {
+	if value := r.URL.Query().Get(name); value != "" || !h.headers && name != "lang" {
+		return value
+	}
+	if value := r.Header.Get(paramHeader(name)); value != "" {
+		return value
+	}
+	if alias, ok := paramHeaderAliases[name]; ok {
+		return r.Header.Get(alias)
+	}
+	return ""
+}
+
+// paramHeader returns the name of the header carrying the request parameter name.
+func paramHeader(name string) string {
+	return paramHeaderPrefix + strings.ReplaceAll(name, "_", "-")
+}
+
+// classifyParams are the request parameters of a classify request.
+type classifyParams struct {
+	rule         service.DecisionRule
+	langFallback bool
+	region       *service.Region
+	extract      string
+	textFormat   string
+	coords       string
+}
+
+// parseParams parses the request parameters of r. Invalid parameters are answered
+// with 400, in which case ok is false.
+func (h *ClassifyHandler) parseParams(w http.ResponseWriter, r *http.Request) (params *classifyParams, ok bool) {
+	// Parse query parameters with defaults
+	decisionRule := h.classifier.DefaultDecisionRule()
+
+	// Parse lang from URL parameter (default: OCR_DEFAULT_LANG or "eng+rus").
+	// Repeated lang parameters are recognized together in one pass, as eng+rus
+	langFallback := false
+	lang := h.param(r, "lang")
+	if langs := r.URL.Query()["lang"]; len(langs) > 1 {
+		lang = strings.Join(langs, "+")
+	}
+	// An unescaped plus in the query string decodes to a space
+	lang = strings.Join(strings.Fields(lang), "+")
+	if lang != "" {
+		resolved, fallback, err := h.classifier.ResolveLanguage(lang)
+		if errors.Is(err, service.ErrLanguageUnavailable) {
+			msg := err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Code: CodeLanguageUnavailable}); err != nil {
+				fmt.Fprintf(w, `{"error":%q,"code":%q}`, msg, CodeLanguageUnavailable)
+			}
+			return nil, false
+		}
+		if err != nil {
+			msg := err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+				fmt.Fprintf(w, `{"error":%q}`, msg)
+			}
+			return nil, false
+		}
+		decisionRule.Language = resolved
+		langFallback = fallback
+	}
+
+	// Parse level from URL parameter (default: RIL_WORD)
+	if level := h.param(r, "level"); level != "" {
+		if levelInt, err := parsePageIteratorLevel(level); err == nil {
+			decisionRule.Level = &levelInt
+		}
+	}
+
+	// Parse oem from URL parameter (default: OCR_OEM)
+	if oem := h.param(r, "oem"); oem != "" {
+		if err := service.ValidateOEM(oem); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid oem"}`)
+			}
+			return nil, false
+		}
+		decisionRule.OEM = oem
+	}
+
+	// Parse psm from URL parameter (default: OCR_PSM)
+	if psm := h.param(r, "psm"); psm != "" {
+		if err := service.ValidatePSM(psm); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid psm"}`)
+			}
+			return nil, false
+		}
+		decisionRule.PSM = psm
+	}
+
+	// Parse confidence_threshold from URL parameter.
+	// Precedence: request > OCR_LANG_CONFIDENCE_THRESHOLDS > OCR_CONFIDENCE_THRESHOLD > built-in default,
+	// the request value must be in (0, 1] and is bounded by OCR_MAX_CONFIDENCE_THRESHOLD.
+	if thresholdStr := h.param(r, "confidence_threshold"); thresholdStr != "" {
+		val, err := strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || !(val > 0 && val <= 1) {
+			msg := fmt.Sprintf("confidence_threshold must be a number in (0, 1], got %q", thresholdStr)
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+				fmt.Fprintf(w, `{"error":%q}`, msg)
+			}
+			return nil, false
+		}
+		if maxConfidence := h.classifier.MaxConfidence(); val > maxConfidence {
+			msg := fmt.Sprintf("confidence_threshold must not exceed %g", maxConfidence)
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+				fmt.Fprintf(w, `{"error":%q}`, msg)
+			}
+			return nil, false
+		}
+		decisionRule.MinConfidence = val
+	}
+
+	// Parse min_token_count from URL parameter
+	if tokenCountStr := h.param(r, "min_token_count"); tokenCountStr != "" {
+		if val, err := strconv.Atoi(tokenCountStr); err == nil && val > 0 {
+			decisionRule.MinTokenCount = val
+		}
+	}
+
+	// Parse roi from URL parameter (x,y,w,h)
+	if roiStr := h.param(r, "roi"); roiStr != "" {
+		roi, err := service.ParseROI(roiStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid region of interest"}`)
+			}
+			return nil, false
+		}
+		decisionRule.ROI = &roi
+	}
+
+	// Parse region from URL parameter (normalized x,y,w,h)
+	var region *service.Region
+	if regionStr := h.param(r, "region"); regionStr != "" {
+		parsed, err := service.ParseRegion(regionStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid region"}`)
+			}
+			return nil, false
+		}
+		region = &parsed
+	}
+
+	// Parse skip_rotation from URL parameter (OCR_SKIP_ROTATION cannot be turned off per request)
+	if skipStr := h.param(r, "skip_rotation"); skipStr != "" {
+		skip, err := strconv.ParseBool(skipStr)
+		if err != nil {
+			msg := fmt.Sprintf("skip_rotation must be a boolean, got %q", skipStr)
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+				fmt.Fprintf(w, `{"error":%q}`, msg)
+			}
+			return nil, false
+		}
+		decisionRule.SkipRotation = skip
+	}
+
+	// Parse angles from URL parameter (comma-separated phase 2 rotation angles)
+	if anglesStr := h.param(r, "angles"); anglesStr != "" {
+		angles, err := service.ParseAngles(anglesStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid angles"}`)
+			}
+			return nil, false
+		}
+		decisionRule.Angles = angles
+	}
+
+	// Parse preprocess preset from URL parameter; OCR_EQUALIZE and OCR_BACKGROUND_BAND apply on top of it
+	if presetName := h.param(r, "preprocess"); presetName != "" {
+		preprocess, err := service.PreprocessPreset(presetName)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid preprocess preset"}`)
+			}
+			return nil, false
+		}
+		preprocess.Equalize = preprocess.Equalize || h.equalize
+		preprocess.BackgroundBand = h.band
+		decisionRule.Preprocess = &preprocess
+	}
+
+	// Parse extract from URL parameter
+	extract := h.param(r, "extract")
+	if extract != "" && extract != service.ExtractDigits {
+		msg := fmt.Sprintf("unsupported extract mode %q, supported: %s", extract, service.ExtractDigits)
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+			fmt.Fprintf(w, `{"error":%q}`, msg)
+		}
+		return nil, false
+	}
+
+	// Parse text_format from URL parameter
+	textFormat := h.param(r, "text_format")
+	if textFormat != "" {
+		if err := service.ValidateTextFormat(textFormat); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid text format"}`)
+			}
+			return nil, false
+		}
+	}
+
+	// Parse coords from URL parameter
+	coords := h.param(r, "coords")
+	if err := service.ValidateCoords(coords); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+			fmt.Fprintf(w, `{"error":"invalid coords"}`)
+		}
+		return nil, false
+	}
+
+	// Parse debug from URL parameter
+	if h.param(r, "debug") == "timing" {
+		decisionRule.Timing = service.NewTiming()
+	}
+
+	return &classifyParams{
+		rule:         decisionRule,
+		langFallback: langFallback,
+		region:       region,
+		extract:      extract,
+		textFormat:   textFormat,
+		coords:       coords,
+	}, true
+}
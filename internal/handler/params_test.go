@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ocr-classifier/internal/service"
+)
+
+// testEngine is the name of an OCR engine that recognizes nothing and accepts every
+// supported language, so handlers can be built without Tesseract.
+const testEngine = "test"
+
+type nopEngine struct{}
+
+func (nopEngine) Recognize([]byte, service.OCRParams) ([]service.RecognizedBox, error) {
+	return nil, nil
+}
+
+func init() {
+	service.RegisterOCREngine(testEngine, func() service.OCREngine { return nopEngine{} })
+}
+
+func TestParseParamsHeaders(t *testing.T) {
+	tests := []struct {
+		name           string
+		headers        bool
+		query          string
+		header         map[string]string
+		wantLang       string
+		wantConfidence float64
+		wantPreset     string
+		wantCode       int
+	}{
+		{name: "lang header", header: map[string]string{"X-OCR-Lang": "rus"}, wantLang: "rus"},
+		{name: "lang header with header params", headers: true, header: map[string]string{"X-OCR-Lang": "rus"}, wantLang: "rus"},
+		{name: "query over lang header", query: "lang=eng", header: map[string]string{"X-OCR-Lang": "rus"}, wantLang: "eng"},
+		{name: "header params disabled", header: map[string]string{"X-OCR-Threshold": "0.5", "X-OCR-Mode": "off"}},
+		{name: "threshold header", headers: true, header: map[string]string{"X-OCR-Confidence-Threshold": "0.5"}, wantConfidence: 0.5},
+		{name: "threshold alias", headers: true, header: map[string]string{"X-OCR-Threshold": "0.5"}, wantConfidence: 0.5},
+		{
+			name:           "header over alias",
+			headers:        true,
+			header:         map[string]string{"X-OCR-Confidence-Threshold": "0.5", "X-OCR-Threshold": "0.9"},
+			wantConfidence: 0.5,
+		},
+		{
+			name:           "query over headers",
+			headers:        true,
+			query:          "confidence_threshold=0.8&preprocess=clean",
+			header:         map[string]string{"X-OCR-Confidence-Threshold": "0.5", "X-OCR-Threshold": "0.9", "X-OCR-Mode": "off"},
+			wantConfidence: 0.8,
+			wantPreset:     service.PresetClean,
+		},
+		{name: "mode alias", headers: true, header: map[string]string{"X-OCR-Mode": "off"}, wantPreset: service.PresetOff},
+		{name: "invalid threshold header", headers: true, header: map[string]string{"X-OCR-Confidence-Threshold": "1.5"}, wantCode: http.StatusBadRequest},
+		{name: "invalid threshold alias", headers: true, header: map[string]string{"X-OCR-Threshold": "high"}, wantCode: http.StatusBadRequest},
+		{name: "invalid mode alias", headers: true, header: map[string]string{"X-OCR-Mode": "sharpen"}, wantCode: http.StatusBadRequest},
+		{name: "invalid lang header", header: map[string]string{"X-OCR-Lang": "xx"}, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ClassifyHandler{
+				classifier: service.NewClassifierWithOptions(service.Options{Engine: testEngine}),
+				metrics:    service.NopMetrics{},
+				headers:    tt.headers,
+			}
+			defaults := h.classifier.DefaultDecisionRule()
+			if tt.wantLang == "" {
+				tt.wantLang = defaults.Language
+			}
+			if tt.wantConfidence == 0 {
+				tt.wantConfidence = defaults.MinConfidence
+			}
+			r := httptest.NewRequest(http.MethodPost, "/classify?"+tt.query, nil)
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			params, ok := h.parseParams(w, r)
+			if tt.wantCode != 0 {
+				if ok || w.Code != tt.wantCode {
+					t.Errorf("parseParams answered %d (ok %v), want %d", w.Code, ok, tt.wantCode)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("parseParams failed with %d: %s", w.Code, w.Body)
+			}
+			if params.rule.Language != tt.wantLang {
+				t.Errorf("language %q, want %q", params.rule.Language, tt.wantLang)
+			}
+			if params.rule.MinConfidence != tt.wantConfidence {
+				t.Errorf("confidence threshold %v, want %v", params.rule.MinConfidence, tt.wantConfidence)
+			}
+			if tt.wantPreset == "" {
+				if params.rule.Preprocess != nil {
+					t.Errorf("preprocessing %+v, want the default", *params.rule.Preprocess)
+				}
+				return
+			}
+			want, _ := service.PreprocessPreset(tt.wantPreset)
+			if got := params.rule.Preprocess; got == nil || *got != want {
+				t.Errorf("preprocessing %+v, want the %s preset", got, tt.wantPreset)
+			}
+		})
+	}
+}
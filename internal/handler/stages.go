@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"ocr-classifier/internal/service"
+)
+
+// Stages returns the intermediate preprocessing images of an image as a zip archive of
+// PNG files named after the stages, numbered in pipeline order (see service.Classifier.PreprocessStages).
+// It is a debugging endpoint, registered only when OCR_DEBUG_STAGES is set. It accepts the
+// same POST bodies and content types as Classify. Optional query parameters: roi, preprocess.
+func (h *ClassifyHandler) Stages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "method not allowed, use POST"}); err != nil {
+			fmt.Fprintf(w, `{"error":"method not allowed, use POST"}`)
+		}
+		return
+	}
+
+	// Check content type
+	contentType := r.Header.Get("Content-Type")
+	if !service.IsSupportedContentType(contentType) {
+		msg := "content-type must be one of: " + strings.Join(service.SupportedContentTypes(), ", ")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg}); err != nil {
+			fmt.Fprintf(w, `{"error":%q}`, msg)
+		}
+		return
+	}
+
+	if !limitBody(w, r, h.maxBytes) {
+		return
+	}
+
+	// Read image data
+	imageData, err := io.ReadAll(r.Body)
+	if isBodyTooLarge(err) {
+		writeBodyTooLarge(w, h.maxBytes)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to read image data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to read image data"}`)
+		}
+		return
+	}
+	defer r.Body.Close()
+
+	if len(imageData) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "empty image data"}); err != nil {
+			fmt.Fprintf(w, `{"error":"empty image data"}`)
+		}
+		return
+	}
+
+	rule := h.classifier.DefaultDecisionRule()
+
+	// Parse roi from URL parameter (x,y,w,h)
+	if roiStr := h.param(r, "roi"); roiStr != "" {
+		roi, err := service.ParseROI(roiStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid region of interest"}`)
+			}
+			return
+		}
+		rule.ROI = &roi
+	}
+
+	// Parse preprocess preset from URL parameter; OCR_EQUALIZE and OCR_BACKGROUND_BAND apply on top of it
+	if presetName := h.param(r, "preprocess"); presetName != "" {
+		preprocess, err := service.PreprocessPreset(presetName)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+				fmt.Fprintf(w, `{"error":"invalid preprocess preset"}`)
+			}
+			return
+		}
+		preprocess.Equalize = preprocess.Equalize || h.equalize
+		preprocess.BackgroundBand = h.band
+		rule.Preprocess = &preprocess
+	}
+
+	stages, err := h.classifier.PreprocessStages(imageData, rule)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidROI) || errors.Is(err, service.ErrImageTooSmall) ||
+			errors.Is(err, service.ErrUnsupportedColorModel) {
+			status = http.StatusBadRequest
+		}
+		if errors.Is(err, service.ErrImageTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); err != nil {
+			fmt.Fprintf(w, `{"error":"failed to process image"}`)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="stages.zip"`)
+	w.WriteHeader(http.StatusOK)
+	// Headers are already sent, so a write error can only be logged
+	if err := writeStagesZip(w, stages); err != nil {
+		log.Printf("failed to write stages archive: %v", err)
+	}
+}
+
+// writeStagesZip writes the stages as <NN>-<stage>.png entries of a zip archive.
+func writeStagesZip(w io.Writer, stages []service.PreprocessStage) error {
+	zw := zip.NewWriter(w)
+	for i, stage := range stages {
+		// PNG data is already compressed
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   fmt.Sprintf("%02d-%s.png", i+1, stage.Name),
+			Method: zip.Store,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(stage.PNG); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
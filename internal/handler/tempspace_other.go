@@ -0,0 +1,21 @@
+//go:build !unix
+
+package handler
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkTempSpace verifies that dir is a directory. Free space cannot be measured on
+// this platform, so the check fails rather than silently passing.
+func checkTempSpace(dir string) (uint64, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat temp directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return 0, fmt.Errorf("temp directory %s is not a directory", dir)
+	}
+	return 0, fmt.Errorf("free temp space check is not supported on this platform")
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package handler
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// checkTempSpace verifies that dir is writable and returns the free space available
+// to unprivileged users, in bytes.
+func checkTempSpace(dir string) (uint64, error) {
+	if err := syscall.Access(dir, 0x2 /* W_OK */); err != nil {
+		return 0, fmt.Errorf("temp directory %s is not writable: %w", dir, err)
+	}
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat temp directory %s: %w", dir, err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
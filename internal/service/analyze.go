@@ -0,0 +1,54 @@
+package service
+
+import "fmt"
+
+// ImageAnalysis describes how an image would be preprocessed before OCR.
+type ImageAnalysis struct {
+	Format            string   `json:"format"`
+	Width             int      `json:"width"`
+	Height            int      `json:"height"`
+	Pixels            int      `json:"pixels"`
+	ScaleFactor       float64  `json:"scale_factor"`
+	ScaleFactorX      float64  `json:"scale_factor_x,omitempty"`
+	ScaleFactorY      float64  `json:"scale_factor_y,omitempty"`
+	ScaledWidth       int      `json:"scaled_width"`
+	ScaledHeight      int      `json:"scaled_height"`
+	BelowMinDimension bool     `json:"below_min_dimension"`
+	ExceedsMaxPixels  bool     `json:"exceeds_max_pixels"`
+	Preprocessing     []string `json:"preprocessing"`
+}
+
+// AnalyzeImage decodes the image and reports the scaling that preprocessing would apply
+// with the classifier's scale curve, without running OCR.
+func (c *Classifier) AnalyzeImage(imageData []byte) (*ImageAnalysis, error) {
+	img, format, err := decodeImageData(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	analysis := &ImageAnalysis{
+		Format:            format,
+		Width:             w,
+		Height:            h,
+		Pixels:            w * h,
+		BelowMinDimension: w <= minDimension || h <= minDimension,
+		ExceedsMaxPixels:  w*h > c.opts.ScaleTiers.MaxPixels,
+		Preprocessing:     []string{},
+	}
+
+	// Images below the minimum dimension are not preprocessed (see preprocessImage)
+	if analysis.BelowMinDimension {
+		return analysis, nil
+	}
+
+	analysis.ScaledWidth, analysis.ScaledHeight, analysis.ScaleFactor = calculateScaleDimensions(w, h, w*h, c.opts.ScaleTiers)
+	analysis.ScaleFactorX, analysis.ScaleFactorY = axisScaleFactors(w, h, analysis.ScaledWidth, analysis.ScaledHeight, analysis.ScaleFactor)
+	if analysis.ScaleFactor != 1.0 {
+		analysis.Preprocessing = append(analysis.Preprocessing, "scale")
+	}
+	analysis.Preprocessing = append(analysis.Preprocessing, "median_blur", "grayscale")
+
+	return analysis, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// AutoLanguage is the language parameter value that runs recognition with every
+// supported language and returns the best result.
+const AutoLanguage = "auto"
+
+// DetectTextAuto runs DetectText once per supported language and returns the best result:
+// the one qualifying as a text document, or else the one with the highest weighted confidence.
+// Languages are processed concurrently, at most AutoLanguageParallelism at a time; once a
+// language produces a text document, languages that have not started yet are skipped and
+// the running ones stop before their next OCR pass.
+func (c *Classifier) DetectTextAuto(imageData []byte, rule DecisionRule) (*ClassifierResult, error) {
+	langs := SupportedLanguageCodes()
+	parallelism := c.opts.AutoLanguageParallelism
+	if parallelism <= 0 || parallelism > len(langs) {
+		parallelism = len(langs)
+	}
+
+	// Canceling ctx stops the other languages once one produces a text document
+	parent := rule.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	results := make([]*ClassifierResult, len(langs))
+	errs := make([]error, len(langs))
+	sem := make(chan struct{}, parallelism)
+	found := make(chan struct{})
+	var foundOnce sync.Once
+	var wg sync.WaitGroup
+
+launch:
+	for i, lang := range langs {
+		select {
+		case <-found:
+			break launch
+		case sem <- struct{}{}:
+		}
+		// select picks randomly among ready cases, so a slot may have been taken
+		// after a text document was already found: release it and stop launching.
+		select {
+		case <-found:
+			<-sem
+			break launch
+		default:
+		}
+
+		wg.Add(1)
+		go func(i int, lang string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			langRule := rule
+			langRule.Language = lang
+			langRule.ctx = ctx
+			if rule.Timing != nil {
+				// Each language records its own stages, the result reports the winner's
+				langRule.Timing = NewTiming()
+			}
+			res, err := c.detectText(imageData, langRule)
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to detect text for language %s: %w", lang, err)
+				return
+			}
+			res.Language = lang
+			results[i] = res
+			if res.IsTextDocument {
+				foundOnce.Do(func() {
+					close(found)
+					cancel()
+				})
+			}
+		}(i, lang)
+	}
+	wg.Wait()
+
+	var best *ClassifierResult
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+		if best == nil ||
+			(res.IsTextDocument && !best.IsTextDocument) ||
+			(res.IsTextDocument == best.IsTextDocument && res.WeightedConfidence > best.WeightedConfidence) {
+			best = res
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("no language was processed")
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// languageEngine recognizes the same words in every image with a confidence that
+// depends on the language, so that auto mode has a clear winner.
+func languageEngine(confidence map[string]float64) engineFunc {
+	return func(_ []byte, params OCRParams) ([]RecognizedBox, error) {
+		conf := confidence[params.Language]
+		return []RecognizedBox{
+			word("document", 5, 5, conf),
+			word("content", 5, 30, conf),
+			word("here", 5, 55, conf),
+		}, nil
+	}
+}
+
+// TestConcurrentDetectText runs DetectText and DetectTextAuto concurrently on a shared
+// classifier; run with -race to check the phase 2 sweep and the auto-language workers.
+func TestConcurrentDetectText(t *testing.T) {
+	engine := languageEngine(map[string]float64{"eng": 30, "rus": 95, DefaultLanguage: 40})
+	c := newTestClassifier(Options{Angles: []int{90, 180, 270}}, engine)
+	imageData := encodePNG(t, textImage(48, 40))
+
+	goroutines, iterations := 8, 4
+	if testing.Short() {
+		iterations = 1
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*iterations)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				var err error
+				if g%2 == 0 {
+					_, err = c.DetectText(imageData, c.DefaultDecisionRule())
+				} else {
+					var res *ClassifierResult
+					res, err = c.DetectTextAuto(imageData, c.DefaultDecisionRule())
+					if err == nil && res.Language != "rus" {
+						t.Errorf("DetectTextAuto language = %q, want rus", res.Language)
+					}
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("detection failed: %v", err)
+	}
+}
+
+// slowEngine wraps languageEngine, spending delay on every pass of the languages
+// other than fast and counting their passes.
+func slowEngine(confidence map[string]float64, fast string, delay time.Duration, slowPasses *atomic.Int32) engineFunc {
+	engine := languageEngine(confidence)
+	return func(imageData []byte, params OCRParams) ([]RecognizedBox, error) {
+		if params.Language != fast {
+			slowPasses.Add(1)
+			time.Sleep(delay)
+		}
+		return engine(imageData, params)
+	}
+}
+
+func TestDetectTextAutoCancelsOtherLanguages(t *testing.T) {
+	langs := SupportedLanguageCodes()
+	angles := []int{90, 180, 270}
+	confidence := map[string]float64{"rus": 95}
+	for _, lang := range langs {
+		if lang != "rus" {
+			confidence[lang] = 30
+		}
+	}
+	imageData := encodePNG(t, textImage(48, 40))
+
+	var slowPasses atomic.Int32
+	c := newTestClassifier(Options{Angles: angles}, slowEngine(confidence, "rus", 50*time.Millisecond, &slowPasses))
+	// languageEngine recognizes 19 tokens
+	rule := c.DefaultDecisionRule()
+	rule.MinTokenCount = 10
+	result, err := c.DetectTextAuto(imageData, rule)
+	if err != nil {
+		t.Fatalf("DetectTextAuto failed: %v", err)
+	}
+	if result.Language != "rus" || !result.IsTextDocument {
+		t.Fatalf("language %q, text %v; want a rus text document", result.Language, result.IsTextDocument)
+	}
+
+	// Without cancellation every other language runs phase 1 and the whole sweep
+	full := int32((len(langs) - 1) * (1 + len(angles)))
+	if got := slowPasses.Load(); got >= full {
+		t.Errorf("other languages ran %d passes, want fewer than %d once rus won", got, full)
+	}
+}
+
+func TestDetectTextAutoParentContextCanceled(t *testing.T) {
+	c := newTestClassifier(Options{}, languageEngine(map[string]float64{"rus": 95}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rule := c.DefaultDecisionRule()
+	rule.Language = AutoLanguage
+	if _, err := c.DetectTextContext(ctx, encodePNG(t, textImage(48, 40)), rule); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+// BenchmarkDetectTextAuto compares processing the languages one at a time and all at
+// once on a Russian page; every OCR pass takes a millisecond, standing in for Tesseract.
+func BenchmarkDetectTextAuto(b *testing.B) {
+	confidence := map[string]float64{"eng": 30, "rus": 95, DefaultLanguage: 40}
+	imageData := encodePNG(b, textImage(48, 40))
+	for _, bench := range []struct {
+		name        string
+		parallelism int
+	}{
+		{name: "sequential", parallelism: 1},
+		{name: "concurrent", parallelism: 0},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var slowPasses atomic.Int32
+			engine := slowEngine(confidence, "", time.Millisecond, &slowPasses)
+			c := newTestClassifier(Options{Angles: []int{90, 180, 270}, AutoLanguageParallelism: bench.parallelism}, engine)
+			rule := c.DefaultDecisionRule()
+			rule.MinTokenCount = 10
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.DetectTextAuto(imageData, rule); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(slowPasses.Load())/float64(b.N), "passes/op")
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import "fmt"
+
+// BestOfLanguagePrefix marks a language parameter requesting per-language recognition
+// that keeps the single best language result, e.g. "best-of:eng,rus".
+const BestOfLanguagePrefix = "best-of:"
+
+// detectTextBestOf finds the winning orientation using all languages combined, then
+// recognizes the oriented image with each language separately and returns the result
+// of the language with the highest weighted confidence, reported in Language.
+// Ties go to the language listed first.
+func (c *Classifier) detectTextBestOf(imageData []byte, rule DecisionRule, langs []string) (*ClassifierResult, error) {
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("best-of mode requires at least one language")
+	}
+
+	result, perLanguage, err := c.recognizeEachLanguage(imageData, rule, langs)
+	if err != nil || perLanguage == nil {
+		return result, err
+	}
+
+	best, bestConfidence := 0, -1.0
+	for i, boxes := range perLanguage {
+		boxes, _ = c.countedBoxes(boxes)
+		totalTokens := 0
+		for _, box := range boxes {
+			totalTokens += countTokens(box.Word)
+		}
+		confidence := 0.0
+		if totalTokens > 0 {
+			_, confidence = c.calculateConfidenceMetrics(boxes, totalTokens)
+		}
+		if confidence > bestConfidence {
+			best, bestConfidence = i, confidence
+		}
+	}
+
+	// The decision uses the winning language's threshold
+	langRule := rule
+	langRule.Language = langs[best]
+	result = c.finishLanguageResult(langRule, result, perLanguage[best])
+	result.Language = langs[best]
+	return result, nil
+}
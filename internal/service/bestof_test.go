@@ -0,0 +1,57 @@
+package service
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestDetectTextBestOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		confidence map[string]float64
+		langs      string
+		want       string
+		wantConf   float64
+	}{
+		{name: "english page", confidence: map[string]float64{"eng": 91, "rus": 42, "eng+rus": 70}, langs: "eng,rus", want: "eng", wantConf: 0.91},
+		{name: "russian page", confidence: map[string]float64{"eng": 38, "rus": 88, "eng+rus": 70}, langs: "eng,rus", want: "rus", wantConf: 0.88},
+		{name: "tie goes to the first language", confidence: map[string]float64{"eng": 80, "rus": 80, "rus+eng": 80}, langs: "rus,eng", want: "rus", wantConf: 0.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := map[string]int{}
+			engine := languageEngine(tt.confidence)
+			c := newTestClassifier(Options{SkipRotation: true}, engineFunc(func(imageData []byte, params OCRParams) ([]RecognizedBox, error) {
+				mu.Lock()
+				calls[params.Language]++
+				mu.Unlock()
+				return engine(imageData, params)
+			}))
+			rule := c.DefaultDecisionRule()
+			rule.Language = BestOfLanguagePrefix + tt.langs
+
+			result, err := c.DetectText(encodePNG(t, textImage(48, 40)), rule)
+			if err != nil {
+				t.Fatalf("DetectText failed: %v", err)
+			}
+			if result.Language != tt.want {
+				t.Errorf("language = %q, want %q", result.Language, tt.want)
+			}
+			if math.Abs(result.WeightedConfidence-tt.wantConf) > 1e-9 {
+				t.Errorf("WeightedConfidence = %v, want %v", result.WeightedConfidence, tt.wantConf)
+			}
+			// The orientation is found once with the languages combined, then each
+			// language recognizes the oriented image once
+			for lang, n := range calls {
+				if n != 1 {
+					t.Errorf("%d passes with %q, want 1", n, lang)
+				}
+			}
+			if len(calls) != 3 {
+				t.Errorf("passes by language = %v, want the combined and each single language", calls)
+			}
+		})
+	}
+}
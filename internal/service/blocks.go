@@ -0,0 +1,138 @@
+package service
+
+import (
+	"sort"
+	"strings"
+)
+
+// TextBlock is a block of text as segmented by the OCR engine (RIL_BLOCK),
+// made of paragraphs in reading order.
+type TextBlock struct {
+	Box        BoundingBox     `json:"box"`
+	Paragraphs []TextParagraph `json:"paragraphs"`
+}
+
+// TextParagraph is a paragraph of a text block (RIL_PARA), made of lines in reading order.
+type TextParagraph struct {
+	Box   BoundingBox `json:"box"`
+	Lines []BlockLine `json:"lines"`
+}
+
+// BlockLine is a line of a paragraph (RIL_TEXTLINE) with its word boxes in reading order.
+type BlockLine struct {
+	Box   BoundingBox   `json:"box"`
+	Words []BoundingBox `json:"words"`
+}
+
+// layoutKey orders boxes by their engine-reported position in the page layout.
+type layoutKey struct {
+	block, paragraph, line int
+}
+
+// groupBlocks builds the block, paragraph and line structure of word boxes from the
+// layout indices reported by the engine. Boxes without layout indices (other engines,
+// merged or mixed-orientation boxes) are left out; returns nil if no box has them.
+// Each level's box spans its children, with the joined text in Word and the
+// token-weighted mean confidence in Confidence.
+func groupBlocks(boxes []BoundingBox) []TextBlock {
+	var words []BoundingBox
+	for _, box := range boxes {
+		if box.layout.block > 0 {
+			words = append(words, box)
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	sort.SliceStable(words, func(i, j int) bool {
+		a, b := words[i].layout, words[j].layout
+		if a.block != b.block {
+			return a.block < b.block
+		}
+		if a.paragraph != b.paragraph {
+			return a.paragraph < b.paragraph
+		}
+		return a.line < b.line
+	})
+
+	var blocks []TextBlock
+	for i, word := range words {
+		key := word.layout
+		newBlock := i == 0 || key.block != words[i-1].layout.block
+		newParagraph := newBlock || key.paragraph != words[i-1].layout.paragraph
+		newLine := newParagraph || key.line != words[i-1].layout.line
+
+		if newBlock {
+			blocks = append(blocks, TextBlock{})
+		}
+		block := &blocks[len(blocks)-1]
+		if newParagraph {
+			block.Paragraphs = append(block.Paragraphs, TextParagraph{})
+		}
+		paragraph := &block.Paragraphs[len(block.Paragraphs)-1]
+		if newLine {
+			paragraph.Lines = append(paragraph.Lines, BlockLine{})
+		}
+		line := &paragraph.Lines[len(paragraph.Lines)-1]
+		line.Words = append(line.Words, word)
+	}
+
+	for i := range blocks {
+		block := &blocks[i]
+		var blockWords []BoundingBox
+		for j := range block.Paragraphs {
+			paragraph := &block.Paragraphs[j]
+			var paragraphWords []BoundingBox
+			for k := range paragraph.Lines {
+				line := &paragraph.Lines[k]
+				line.Box = spanBox(line.Words, " ")
+				paragraphWords = append(paragraphWords, line.Words...)
+			}
+			paragraph.Box = spanBox(paragraphWords, " ")
+			blockWords = append(blockWords, paragraphWords...)
+		}
+		block.Box = spanBox(blockWords, " ")
+	}
+	return blocks
+}
+
+// spanBox returns the union box of words with their texts joined by sep and their
+// token-weighted mean confidence.
+func spanBox(words []BoundingBox, sep string) BoundingBox {
+	texts := make([]string, len(words))
+	var weighted, tokens float64
+	span := words[0]
+	for i, word := range words {
+		span = unionBox(span, word)
+		texts[i] = word.Word
+		n := float64(countTokens(word.Word))
+		weighted += word.Confidence * n
+		tokens += n
+	}
+	span.Word = strings.Join(texts, sep)
+	if tokens > 0 {
+		span.Confidence = weighted / tokens
+	}
+	return span
+}
+
+// shiftBlocks moves all boxes of blocks by (dx, dy).
+func shiftBlocks(blocks []TextBlock, dx, dy int) {
+	shift := func(box *BoundingBox) {
+		box.X += dx
+		box.Y += dy
+	}
+	for i := range blocks {
+		shift(&blocks[i].Box)
+		for j := range blocks[i].Paragraphs {
+			paragraph := &blocks[i].Paragraphs[j]
+			shift(&paragraph.Box)
+			for k := range paragraph.Lines {
+				shift(&paragraph.Lines[k].Box)
+				for w := range paragraph.Lines[k].Words {
+					shift(&paragraph.Lines[k].Words[w])
+				}
+			}
+		}
+	}
+}
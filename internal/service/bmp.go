@@ -0,0 +1,9 @@
+package service
+
+import (
+	_ "golang.org/x/image/bmp"
+)
+
+func init() {
+	RegisterImageFormat("image/bmp", "bmp")
+}
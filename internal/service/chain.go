@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// PreprocessProfile is a named set of preprocessing parameters tried in a fallback chain.
+type PreprocessProfile struct {
+	Name    string
+	Options PreprocessOptions
+}
+
+// ParsePreprocessChain parses a comma-separated list of preset names into profiles,
+// in order. An empty string yields an empty chain.
+func ParsePreprocessChain(s string) ([]PreprocessProfile, error) {
+	var chain []PreprocessProfile
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		opts, err := PreprocessPreset(name)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, PreprocessProfile{Name: name, Options: opts})
+	}
+	return chain, nil
+}
+
+// detectWithProfileChain runs detectWithPreprocessing with each profile of the configured
+// chain in order, stopping at the first result with at least ChainMinTokens
+// tokens. If no profile reaches the floor, the result with the most tokens wins (the
+// earlier profile on ties). At most ChainMaxAttempts profiles are tried.
+// Without a chain, or when the request sets its own preprocessing, a single pass runs;
+// with ChainOnRecognitionFail, the chain still runs if that pass failed to recognize.
+func (c *Classifier) detectWithProfileChain(img image.Image, rule DecisionRule) (*ClassifierResult, error) {
+	chain := c.opts.PreprocessChain
+	if len(chain) == 0 {
+		return c.detectWithPreprocessing(img, rule)
+	}
+	if rule.Preprocess != nil {
+		result, err := c.detectWithPreprocessing(img, rule)
+		if err != nil || !c.opts.ChainOnRecognitionFail || result.Status != StatusRecognitionFailed {
+			return result, err
+		}
+		rule.Preprocess = nil
+	}
+	if limit := c.opts.ChainMaxAttempts; limit > 0 && limit < len(chain) {
+		chain = chain[:limit]
+	}
+	minTokens := max(c.opts.ChainMinTokens, 1)
+
+	var best *ClassifierResult
+	for i, profile := range chain {
+		profileRule := rule
+		profileRule.Preprocess = &chain[i].Options
+		result, err := c.detectWithPreprocessing(img, profileRule)
+		if err != nil {
+			return nil, fmt.Errorf("preprocess profile %s: %w", profile.Name, err)
+		}
+		result.PreprocessProfile = profile.Name
+		if best == nil || result.TokenCount > best.TokenCount {
+			best = result
+		}
+		if result.TokenCount >= minTokens {
+			break
+		}
+	}
+	return best, nil
+}
+
+// chainProfile returns the preprocessing parameters of the named chain profile, or nil.
+func (c *Classifier) chainProfile(name string) *PreprocessOptions {
+	for i := range c.opts.PreprocessChain {
+		if c.opts.PreprocessChain[i].Name == name {
+			return &c.opts.PreprocessChain[i].Options
+		}
+	}
+	return nil
+}
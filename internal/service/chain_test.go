@@ -0,0 +1,88 @@
+package service
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestAllZeroConfidence(t *testing.T) {
+	tests := []struct {
+		name  string
+		boxes []RecognizedBox
+		want  bool
+	}{
+		{name: "no boxes"},
+		{name: "all zero", boxes: []RecognizedBox{word("ab", 0, 0, 0), word("cd", 0, 30, 0)}, want: true},
+		{name: "one nonzero", boxes: []RecognizedBox{word("ab", 0, 0, 0), word("cd", 0, 30, 12)}},
+		{name: "blank words ignored", boxes: []RecognizedBox{word("ab", 0, 0, 0), {Word: " ", Confidence: 80}}, want: true},
+		{name: "only blank words", boxes: []RecognizedBox{{Word: "", Confidence: 0}}},
+	}
+	for _, tt := range tests {
+		if got := allZeroConfidence(tt.boxes); got != tt.want {
+			t.Errorf("%s: allZeroConfidence = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectTextAllZeroConfidence(t *testing.T) {
+	zeros := staticEngine(word("invoice", 10, 10, 0), word("total", 10, 40, 0))
+	c := newTestClassifier(Options{SkipRotation: true}, zeros)
+
+	result, err := c.DetectText(encodePNG(t, textImage(48, 40)), c.DefaultDecisionRule())
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	if result.Status != StatusRecognitionFailed || result.TokenCount != 0 || result.IsTextDocument {
+		t.Errorf("status %q, %d tokens, text %v; want %q with no tokens",
+			result.Status, result.TokenCount, result.IsTextDocument, StatusRecognitionFailed)
+	}
+}
+
+func TestChainOnRecognitionFail(t *testing.T) {
+	chain, err := ParsePreprocessChain(PresetScan + "," + PresetPhoto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		enabled     bool
+		wantStatus  string
+		wantProfile string
+		wantCalls   int32
+	}{
+		{name: "disabled", enabled: false, wantStatus: StatusRecognitionFailed, wantCalls: 1},
+		{name: "enabled", enabled: true, wantProfile: PresetScan, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The first pass fails to recognize, the next ones succeed
+			var calls atomic.Int32
+			engine := engineFunc(func([]byte, OCRParams) ([]RecognizedBox, error) {
+				confidence := 90.0
+				if calls.Add(1) == 1 {
+					confidence = 0
+				}
+				return []RecognizedBox{word("invoice", 10, 10, confidence), word("total", 10, 40, confidence)}, nil
+			})
+			c := newTestClassifier(Options{
+				SkipRotation:           true,
+				PreprocessChain:        chain,
+				ChainOnRecognitionFail: tt.enabled,
+			}, engine)
+			rule := c.DefaultDecisionRule()
+			// The request sets its own preprocessing, which skips the chain unless recognition fails
+			rule.Preprocess = &PreprocessOptions{Equalize: true}
+
+			result, err := c.DetectText(encodePNG(t, textImage(48, 40)), rule)
+			if err != nil {
+				t.Fatalf("DetectText failed: %v", err)
+			}
+			if result.Status != tt.wantStatus || result.PreprocessProfile != tt.wantProfile {
+				t.Errorf("status %q, profile %q; want %q, %q", result.Status, result.PreprocessProfile, tt.wantStatus, tt.wantProfile)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("engine called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
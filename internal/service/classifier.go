@@ -2,8 +2,16 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"image"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/otiai10/gosseract/v2"
 )
@@ -13,6 +21,13 @@ const (
 	// Boxes with confidence below this value are discarded.
 	minBoxConfidence = 0.25
 
+	// confidenceTieEpsilon is the difference in weighted confidence below which
+	// two rotation results are considered equally good.
+	confidenceTieEpsilon = 1e-9
+
+	// maxEngineConfidence is the upper bound of the OCR engine word confidence scale (0-100).
+	maxEngineConfidence = 100.0
+
 	// DefaultLanguage is the default language set for Tesseract OCR.
 	DefaultLanguage = "eng+rus"
 
@@ -27,6 +42,20 @@ type OCRParams struct {
 	// Level is the PageIteratorLevel for text structure granularity.
 	// If nil, DefaultPageIteratorLevel will be used.
 	Level *gosseract.PageIteratorLevel
+	// Layout requests the block, paragraph and line indices of each word from the engine.
+	// It is honored at word level only.
+	Layout bool
+	// OEM is the engine mode (OEMDefault, OEMLegacy, OEMLSTM or OEMCombined).
+	// If empty, Tesseract's default will be used. Other engines ignore it.
+	OEM string
+	// PSM is the page segmentation mode (PSMAuto, PSMSingleLine, ...).
+	// If empty, Tesseract's default will be used. Other engines ignore it.
+	PSM string
+
+	// ctx is the request context set by DetectTextContext, checked before each OCR pass.
+	ctx context.Context
+	// prepared is the image decoded by PrepareImage, nil if DetectText decodes it.
+	prepared *preparedImage
 }
 
 // BoundingBox represents a detected text region with its position and confidence.
@@ -37,76 +66,443 @@ type BoundingBox struct {
 	Height     int     `json:"height"`
 	Word       string  `json:"word"`
 	Confidence float64 `json:"confidence"`
+	// Language is the recognition language of the box, set only in merge mode.
+	Language string `json:"language,omitempty"`
+	// Angle is the local text angle of a box recognized in a differently oriented
+	// region of the page, set only in mixed-orientation mode.
+	Angle int `json:"angle,omitempty"`
+	// Color is the dominant foreground color ("#rrggbb") sampled from the original image,
+	// set only when text colors are enabled and the box has high confidence.
+	Color string `json:"color,omitempty"`
+
+	// layout is the position of the word in the engine's page layout, if reported.
+	layout layoutKey
 }
 
 // ClassifierResult contains the results of text detection on an image.
 type ClassifierResult struct {
 	MeanConfidence     float64       `json:"mean_confidence"`
 	WeightedConfidence float64       `json:"weighted_confidence"`
+	UprightConfidence  float64       `json:"upright_confidence"`
 	TokenCount         int           `json:"token_count"`
 	Boxes              []BoundingBox `json:"boxes"`
+	TextLines          []TextLine    `json:"text_lines,omitempty"`
+	QualityWarnings    []string      `json:"quality_warnings,omitempty"`
+	Warnings           []string      `json:"warnings,omitempty"`
+	Phase2Gate         string        `json:"phase2_gate,omitempty"`
+	Timings            []StageTiming `json:"timings,omitempty"`
+	TextColor          string        `json:"text_color,omitempty"`
 	Angle              int           `json:"angle"`
+	RawAngle           int           `json:"raw_angle"`
+	SweepPasses        int           `json:"sweep_passes"`
 	ScaleFactor        float64       `json:"scale_factor"`
 	IsTextDocument     bool          `json:"is_text_document"`
 	BoundingBoxWidth   int           `json:"bounding_box_width"`
 	BoundingBoxHeight  int           `json:"bounding_box_height"`
+	// Status is set to StatusError when the result stands in for a failed classification,
+	// to StatusTooSmall when the image is too small to be processed, or to
+	// StatusRecognitionFailed when every recognized word has zero confidence.
+	Status string `json:"status,omitempty"`
+	// Language is the language that produced the result, set only by DetectTextAuto
+	// and in best-of mode.
+	Language string `json:"language,omitempty"`
+	// Page is the 1-based page number of the result in a multi-page document, set only by DetectTextPages.
+	Page int `json:"page,omitempty"`
+	// Error holds the failure message when Status is StatusError.
+	Error string `json:"error,omitempty"`
+	// Numbers holds numeric candidates, set only when requested with extract=digits.
+	Numbers []NumberCandidate `json:"numbers,omitempty"`
+	// OEM is the effective OCR engine mode.
+	OEM string `json:"oem,omitempty"`
+	// PSM is the page segmentation mode, set only when one was selected.
+	PSM string `json:"psm,omitempty"`
+	// OrientationSource tells whether OSD or the rotation search produced the angle
+	// (OrientationSourceOSD or OrientationSourceSweep), set only when OSD is enabled
+	// and phase 2 ran.
+	OrientationSource string `json:"orientation_source,omitempty"`
+	// Blocks is the block, paragraph and line structure of the words, set only when enabled.
+	Blocks []TextBlock `json:"blocks,omitempty"`
+	// PreprocessProfile is the winning profile of the preprocessing fallback chain,
+	// set only when a chain is configured.
+	PreprocessProfile string `json:"preprocess_profile,omitempty"`
+	// ContentHash is the "algorithm:hex" hash of the uploaded bytes, set only when enabled.
+	ContentHash string `json:"content_hash,omitempty"`
+	// Text is the recognized text in reading order, the words of each line joined with
+	// spaces and the lines with newlines (see RecognizedText). The classify handler
+	// reformats it as requested with text_format.
+	Text string `json:"text,omitempty"`
+	// OriginalBoxes are the boxes mapped to pixel coordinates of the original image
+	// (see OriginalSpaceBoxes), set only when enabled.
+	OriginalBoxes []BoundingBox `json:"original_boxes,omitempty"`
+	// DPI relates the box coordinates to the input resolution, set only when enabled.
+	DPI *DPIInfo `json:"dpi,omitempty"`
+	// ScaleFactorX and ScaleFactorY are the exact per-axis scales, set only when rounding
+	// to whole pixels makes them differ from ScaleFactor.
+	ScaleFactorX float64 `json:"scale_factor_x,omitempty"`
+	ScaleFactorY float64 `json:"scale_factor_y,omitempty"`
+	// Threshold describes the thresholding step of preprocessing, set only when enabled.
+	Threshold *ThresholdInfo `json:"threshold,omitempty"`
+	// ScaleScores are the outcomes of the scale factors tried, set only when MultiScale is enabled.
+	ScaleScores []ScaleScore `json:"scale_scores,omitempty"`
+	// ProcessingMillis is the wall time of DetectText in milliseconds, preprocessing and
+	// all OCR phases included; for a page of a multi-page document, of that page only.
+	ProcessingMillis int64 `json:"processing_ms"`
+
+	// roiOrigin is the origin of the recognized image in the original image: the origin
+	// of the region of interest, if any, less the padding of a small image.
+	roiOrigin image.Point
+	// fullSize is the size of the original image, recorded when the boxes are shifted
+	// to it from the recognized image.
+	fullSize image.Point
+	// preprocessedSpace is set once ToPreprocessedSpace has undone the ROI shift.
+	preprocessedSpace bool
+	// source is the decoded original image, kept only while an output feature needs it.
+	source image.Image
+}
+
+// SourceImage returns the decoded original (color) image, before ROI cropping and
+// preprocessing, or nil unless PreserveColor is enabled or the image could not be decoded.
+// OriginalSpaceBoxes maps the boxes onto it.
+func (r *ClassifierResult) SourceImage() image.Image {
+	return r.source
+}
+
+const (
+	// StatusError marks a ClassifierResult returned in place of a failed classification.
+	StatusError = "error"
+	// StatusTooSmall marks a zero-token ClassifierResult for an image below the minimum dimension.
+	StatusTooSmall = "too_small"
+	// StatusRecognitionFailed marks a zero-token ClassifierResult for which the engine
+	// returned words, all with confidence exactly 0: a recognition failure rather than
+	// unconfident text, worth retrying with other settings.
+	StatusRecognitionFailed = "recognition_failed"
+)
+
+// Phase 2 gate outcomes, reported in ClassifierResult.Phase2Gate.
+const (
+	// Phase2GateSkip means the upright result was confident enough to skip the rotation search.
+	Phase2GateSkip = "skip"
+	// Phase2GateLowConfidence means the rotation search ran because of low upright confidence.
+	Phase2GateLowConfidence = "low_confidence"
+	// Phase2GateLowTokenCount means the rotation search ran because the upright result
+	// was confident but had too few tokens.
+	Phase2GateLowTokenCount = "low_token_count"
+	// Phase2GateDisabled means the rotation search was turned off with SkipRotation.
+	Phase2GateDisabled = "disabled"
+)
+
+// WarningRotationFailed is reported when every phase 2 rotation pass failed and the
+// phase 1 result is returned instead.
+const WarningRotationFailed = "all rotation passes failed, upright result returned"
+
+// NewErrorResult returns a zero-confidence, non-text result describing err.
+func NewErrorResult(err error) *ClassifierResult {
+	return &ClassifierResult{
+		Boxes:  []BoundingBox{},
+		Status: StatusError,
+		Error:  err.Error(),
+	}
+}
+
+// Options holds server-wide classifier settings.
+type Options struct {
+	// FlipCheck enables a fast 0-vs-180 disambiguation before the full rotation sweep.
+	FlipCheck bool
+	// DefaultLanguage is used when a request does not specify a language.
+	// If empty, DefaultLanguage constant will be used.
+	DefaultLanguage string
+	// DedupIoU is the intersection-over-union threshold above which overlapping boxes
+	// are deduplicated, keeping the higher-confidence one. Zero disables deduplication.
+	DedupIoU float64
+	// Orientation selects the phase 2 strategy: OrientationSweep or OrientationEstimate.
+	// If empty, OrientationSweep will be used.
+	Orientation string
+	// SnapTolerance is the maximum distance in degrees from a cardinal angle (0/90/180/270)
+	// at which the phase 2 winning angle is snapped to it. Zero disables snapping.
+	SnapTolerance int
+	// RotationMargin is the minimum weighted confidence gain a rotated result needs
+	// over the upright (phase 1) result to be accepted when neither qualifies as text;
+	// a rotated result meeting the decision rule is accepted regardless. Zero disables it.
+	RotationMargin float64
+	// MinConfidence is the default weighted confidence threshold used when a request
+	// specifies none. If zero, the GetDefaultDecisionRule threshold will be used.
+	MinConfidence float64
+	// LanguageConfidence holds per-language default thresholds, keyed by the exact
+	// language string of a recognition pass (e.g. "rus", "eng+rus"). They take
+	// precedence over MinConfidence, but not over a threshold set in the request.
+	LanguageConfidence map[string]float64
+	// MaxConfidence is the upper bound for per-request confidence thresholds.
+	// If zero, thresholds up to 1 are allowed.
+	MaxConfidence float64
+	// AutoLanguageParallelism limits how many languages DetectTextAuto processes concurrently.
+	// If zero, all supported languages are processed at once.
+	AutoLanguageParallelism int
+	// ScriptDominance is the share of letters (0-1) a script needs in the first pass of
+	// the ScriptLanguage mode to select its language. If not in (0, 1], DefaultScriptDominance.
+	ScriptDominance float64
+	// Preprocess enables optional preprocessing stages.
+	Preprocess PreprocessOptions
+	// ScaleTiers is the size-based scale curve of preprocessing. Without MaxPixels,
+	// DefaultScaleTiers is used.
+	ScaleTiers ScaleTiers
+	// PreprocessChain is an ordered list of preprocessing profiles tried until one yields
+	// ChainMinTokens tokens. Requests with their own preprocessing skip it.
+	PreprocessChain []PreprocessProfile
+	// ChainMinTokens is the token count that ends the chain (0 means 1).
+	ChainMinTokens int
+	// ChainMaxAttempts caps the number of chain profiles tried (0 means all).
+	ChainMaxAttempts int
+	// ChainOnRecognitionFail runs the PreprocessChain after a request's own preprocessing
+	// produced a StatusRecognitionFailed result.
+	ChainOnRecognitionFail bool
+	// MaxSweepPasses caps the number of rotation angles tried in phase 2.
+	// If zero, all candidate angles are tried.
+	MaxSweepPasses int
+	// Engine is the name of the registered OCREngine to use.
+	// If empty or unknown, DefaultEngine will be used.
+	Engine string
+	// RepeatThreshold, when positive, discounts low-variation words occurring at least
+	// this many times from the token count and confidence aggregates as OCR noise.
+	RepeatThreshold int
+	// TextLines enables grouping of word boxes into text lines in the result.
+	TextLines bool
+	// Blocks enables the block/paragraph/line structure of the words in the result,
+	// built from the layout reported by the engine.
+	Blocks bool
+	// OriginalBoxes adds the boxes mapped to pixel coordinates of the original image
+	// (scaling and rotation undone) to the result.
+	OriginalBoxes bool
+	// CompressIntermediate compresses the images passed to the OCR engine,
+	// trading encode time for lower memory use.
+	CompressIntermediate bool
+	// QualityWarnings enables reporting of image quality problems (low DPI,
+	// JPEG blockiness, low contrast) in the result.
+	QualityWarnings bool
+	// MixedOrientation enables re-recognition of page regions whose text runs at a
+	// different angle than the page (e.g. rotated stamps). It adds OCR passes.
+	MixedOrientation bool
+	// ReportThreshold adds the luminance thresholds applied during preprocessing to the result.
+	ReportThreshold bool
+	// MultiScale enables recognizing the image again at the winning angle with a lower
+	// and a higher scale factor than the one chosen from the image size, keeping the
+	// most confident result. It adds up to two OCR passes.
+	MultiScale bool
+	// TextColor enables sampling of the text color from the original image.
+	TextColor bool
+	// MaxPixels rejects images with more pixels (width x height) with ErrImageTooLarge
+	// before decoding them. If zero, images of any size are accepted.
+	MaxPixels int
+	// PDFDPI is the resolution PDF pages are rendered at when PDF support is built in.
+	// If zero, DefaultPDFDPI will be used.
+	PDFDPI float64
+	// PadSmallImages, when above the minimum dimension (32 px), pads images with a side
+	// at or below the minimum dimension with their background color up to this size
+	// instead of rejecting them as too small. Box coordinates exclude the padding.
+	PadSmallImages int
+	// ColorModels selects the handling of CMYK and 16-bit images: ColorModelsNormalize
+	// or ColorModelsReject. If empty, ColorModelsNormalize will be used.
+	ColorModels string
+	// LanguageFallback makes ResolveLanguage replace a language whose data is not
+	// installed with DefaultLanguage instead of failing.
+	LanguageFallback bool
+	// ReportDPI adds the input and effective DPI and the scale factors to the result.
+	ReportDPI bool
+	// DPIHint is the input DPI reported when the image metadata stores none (0 means unknown).
+	DPIHint int
+	// PreserveColor keeps the decoded original image in the result (see
+	// ClassifierResult.SourceImage) for output features that render onto it.
+	// It holds the image in memory until the result is released.
+	PreserveColor bool
+	// SkipSweepConfidence is the upright weighted confidence (0-1) needed to skip the
+	// rotation search (0 means the decision rule's confidence threshold).
+	SkipSweepConfidence float64
+	// SkipSweepMinTokens is the upright token count needed to skip the rotation search
+	// (0 means the decision rule's minimum token count).
+	SkipSweepMinTokens int
+	// SkewClamp, when positive, prunes the phase 2 sweep for documents whose estimated
+	// skew is within SkewClamp degrees of upright: only the cardinal angles and a tight
+	// window around the estimate are tried.
+	SkewClamp int
+	// SkipRotation disables phase 2: the phase 1 result is returned whatever its confidence.
+	// A request can also set DecisionRule.SkipRotation.
+	SkipRotation bool
+	// Angles, if set, are the rotation angles phase 2 tries instead of the candidates
+	// estimated from the image, whatever the Orientation strategy. A request can
+	// override them with DecisionRule.Angles.
+	Angles []int
+	// Deskew rotates the preprocessed image by the estimated fine skew of its text lines
+	// (up to deskewMaxAngle degrees) before phase 1; the skew is reported in Angle.
+	Deskew bool
+	// RotationFill selects the fill of the corners uncovered by rotating the image by a
+	// non-cardinal angle: RotationFillWhite (the default if empty) or RotationFillBorder.
+	RotationFill string
+	// OSD runs the engine's orientation and script detection before the rotation search
+	// and recognizes the image only at the detected angle; the search is run when the
+	// engine does not implement OrientationDetector or its confidence is below
+	// OSDMinConfidence.
+	OSD bool
+	// OSDMinConfidence is the OSD orientation confidence needed to skip the rotation
+	// search (0 means DefaultOSDMinConfidence).
+	OSDMinConfidence float64
+	// EngineRetries is the number of times a failed OCR engine call is retried,
+	// unless the error is marked with ErrPermanent.
+	EngineRetries int
+	// OEM is the default engine mode used when a request specifies none.
+	// If empty, OEMDefault will be used.
+	OEM string
+	// PSM is the default page segmentation mode used when a request specifies none.
+	// If empty, Tesseract's default will be used.
+	PSM string
+	// NormalizeWords enables normalization of recognized words: whitespace is unified,
+	// control characters are removed and the text is converted to Unicode NFC.
+	NormalizeWords bool
+	// ContentHash is the algorithm (see ValidateHashAlgorithm) used to hash the uploaded
+	// bytes into ClassifierResult.ContentHash. If empty, no hash is computed.
+	ContentHash string
+	// CoarseScale, when in (0, 1), runs the phase 2 angle search on a copy of the
+	// preprocessed image downscaled by this factor, followed by one full-resolution
+	// pass at the winning angle.
+	CoarseScale float64
 }
 
 // Classifier performs OCR-based text detection on images.
-type Classifier struct{}
+type Classifier struct {
+	opts   Options
+	engine OCREngine
 
-// NewClassifier creates a new Classifier instance.
-func NewClassifier() *Classifier {
-	return &Classifier{}
+	languagesOnce sync.Once
+	languages     map[string]bool
 }
 
-// detectTextSingle performs OCR on a single image using specified language and level.
-// It returns the detected text boxes with confidence scores and token counts.
-func (c *Classifier) detectTextSingle(imageData []byte, params OCRParams) (*ClassifierResult, error) {
-	client := gosseract.NewClient()
-	defer client.Close()
+// NewClassifier creates a new Classifier instance with default options.
+func NewClassifier() *Classifier {
+	return NewClassifierWithOptions(Options{})
+}
 
-	language := params.Language
-	if language == "" {
-		language = DefaultLanguage
+// NewClassifierWithOptions creates a new Classifier instance with the given options.
+func NewClassifierWithOptions(opts Options) *Classifier {
+	if opts.DefaultLanguage == "" {
+		opts.DefaultLanguage = DefaultLanguage
 	}
+	if opts.Orientation == "" {
+		opts.Orientation = OrientationSweep
+	}
+	if opts.MinConfidence <= 0 || opts.MinConfidence > 1 {
+		opts.MinConfidence = GetDefaultDecisionRule().MinConfidence
+	}
+	if opts.MaxConfidence <= 0 || opts.MaxConfidence > 1 {
+		opts.MaxConfidence = 1
+	}
+	if opts.ScaleTiers.MaxPixels <= 0 {
+		opts.ScaleTiers = DefaultScaleTiers()
+	}
+	return &Classifier{opts: opts, engine: newOCREngine(opts.Engine)}
+}
 
-	if err := client.SetLanguage(language); err != nil {
-		return nil, fmt.Errorf("failed to set language: %w", err)
+// Close releases the resources held by the OCR engine, such as pooled Tesseract clients.
+// Detections still running finish normally.
+func (c *Classifier) Close() error {
+	if closer, ok := c.engine.(io.Closer); ok {
+		return closer.Close()
 	}
+	return nil
+}
 
-	if err := client.SetImageFromBytes(imageData); err != nil {
-		return nil, fmt.Errorf("failed to set image: %w", err)
+// DefaultDecisionRule returns the default decision criteria with the classifier's
+// default language. The confidence threshold is left unset, so that each recognition
+// pass resolves it for its own language (see Options.LanguageConfidence).
+func (c *Classifier) DefaultDecisionRule() DecisionRule {
+	rule := GetDefaultDecisionRule()
+	rule.Language = c.opts.DefaultLanguage
+	rule.MinConfidence = 0
+	return rule
+}
+
+// MaxConfidence returns the upper bound for per-request confidence thresholds.
+func (c *Classifier) MaxConfidence() float64 {
+	return c.opts.MaxConfidence
+}
+
+// preprocessOptions returns the preprocessing parameters for a request:
+// the rule override if set, otherwise the configured ones, with the classifier's
+// scale curve.
+func (c *Classifier) preprocessOptions(rule DecisionRule) PreprocessOptions {
+	opts := c.opts.Preprocess
+	if rule.Preprocess != nil {
+		opts = *rule.Preprocess
 	}
+	opts.tiers = &c.opts.ScaleTiers
+	return opts
+}
 
-	level := params.Level
-	if level == nil {
-		defaultLevel := DefaultPageIteratorLevel
-		level = &defaultLevel
+// recognize runs the OCR engine, retrying up to EngineRetries times on errors
+// not marked with ErrPermanent. Each attempt uses a fresh engine client.
+// No attempt starts once the request context is done.
+func (c *Classifier) recognize(imageData []byte, params OCRParams) ([]RecognizedBox, error) {
+	if err := params.canceled(); err != nil {
+		return nil, err
 	}
+	boxes, err := c.engine.Recognize(imageData, params)
+	for attempt := 0; err != nil && attempt < c.opts.EngineRetries && !errors.Is(err, ErrPermanent); attempt++ {
+		if err := params.canceled(); err != nil {
+			return nil, err
+		}
+		boxes, err = c.engine.Recognize(imageData, params)
+	}
+	return boxes, err
+}
 
-	boxes, err := client.GetBoundingBoxes(*level)
+// detectTextSingle performs OCR on a single image using specified language and level.
+// It returns the detected text boxes with confidence scores and token counts.
+func (c *Classifier) detectTextSingle(imageData []byte, params OCRParams) (*ClassifierResult, error) {
+	boxes, err := c.recognize(imageData, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get bounding boxes: %w", err)
+		return nil, err
 	}
+	sanitizeWords(boxes)
+	if c.opts.NormalizeWords {
+		normalizeWords(boxes)
+	}
+	boxes = dedupBoxes(boxes, c.opts.DedupIoU)
 
-	// Decode image to get dimensions (gosseract does not expose ImageWidth/ImageHeight in v2)
-	img, _, err := image.Decode(bytes.NewReader(imageData))
+	// Read image dimensions from the header (OCR engines do not report them)
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(imageData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image for dimensions: %w", err)
 	}
-	bounds := img.Bounds()
-	imgWidth := bounds.Dx()
-	imgHeight := bounds.Dy()
 
-	return c.processBoundingBoxes(boxes, imgWidth, imgHeight)
+	result, err := c.processBoundingBoxes(boxes, imgConfig.Width, imgConfig.Height)
+	if err != nil {
+		return nil, err
+	}
+	result.OEM = params.OEM
+	result.PSM = params.PSM
+	return result, nil
+}
+
+// encodeIntermediate encodes a preprocessed or rotated image for the OCR engine.
+// Uncompressed PNG is used unless CompressIntermediate is set: these images never
+// leave the process, and deflate dominates the cost of every rotation attempt.
+func (c *Classifier) encodeIntermediate(img image.Image) ([]byte, error) {
+	if c.opts.CompressIntermediate {
+		return encodeImage(img, "png")
+	}
+	return encodeImage(img, "png-raw")
 }
 
 // processBoundingBoxes processes raw OCR bounding boxes and calculates confidence metrics.
-func (c *Classifier) processBoundingBoxes(boxes []gosseract.BoundingBox, imgWidth, imgHeight int) (*ClassifierResult, error) {
+func (c *Classifier) processBoundingBoxes(boxes []RecognizedBox, imgWidth, imgHeight int) (*ClassifierResult, error) {
 	if len(boxes) == 0 {
 		return &ClassifierResult{IsTextDocument: false, BoundingBoxWidth: imgWidth, BoundingBoxHeight: imgHeight}, nil
 	}
+	if allZeroConfidence(boxes) {
+		return &ClassifierResult{
+			Boxes:             []BoundingBox{},
+			BoundingBoxWidth:  imgWidth,
+			BoundingBoxHeight: imgHeight,
+			Status:            StatusRecognitionFailed,
+		}, nil
+	}
 
 	resultBoxes, totalTokens := c.filterAndConvertBoxes(boxes)
 
@@ -114,9 +510,19 @@ func (c *Classifier) processBoundingBoxes(boxes []gosseract.BoundingBox, imgWidt
 		return &ClassifierResult{IsTextDocument: false, BoundingBoxWidth: imgWidth, BoundingBoxHeight: imgHeight}, nil
 	}
 
-	meanConfidence, weightedConfidence := c.calculateConfidenceMetrics(resultBoxes, totalTokens)
+	counted, noisy := c.countedBoxes(resultBoxes)
+	if noisy {
+		totalTokens = 0
+		for _, box := range counted {
+			totalTokens += countTokens(box.Word)
+		}
+	}
+	var meanConfidence, weightedConfidence float64
+	if totalTokens > 0 {
+		meanConfidence, weightedConfidence = c.calculateConfidenceMetrics(counted, totalTokens)
+	}
 
-	return &ClassifierResult{
+	result := &ClassifierResult{
 		MeanConfidence:     meanConfidence,
 		WeightedConfidence: weightedConfidence,
 		TokenCount:         totalTokens,
@@ -124,21 +530,47 @@ func (c *Classifier) processBoundingBoxes(boxes []gosseract.BoundingBox, imgWidt
 		Angle:              0,
 		BoundingBoxWidth:   imgWidth,
 		BoundingBoxHeight:  imgHeight,
-	}, nil
+		Text:               RecognizedText(resultBoxes, TextFormatLines),
+	}
+	if c.opts.TextLines {
+		result.TextLines = groupTextLines(resultBoxes)
+	}
+	if c.opts.Blocks {
+		result.Blocks = groupBlocks(resultBoxes)
+	}
+	if noisy {
+		addWarning(result, WarningRepeatedTokens)
+	}
+	return result, nil
+}
+
+// allZeroConfidence reports whether boxes contain words and all of them have
+// confidence exactly 0, the degenerate output of a failed recognition.
+func allZeroConfidence(boxes []RecognizedBox) bool {
+	words := 0
+	for _, box := range boxes {
+		if strings.TrimSpace(box.Word) == "" {
+			continue
+		}
+		if box.Confidence != 0 {
+			return false
+		}
+		words++
+	}
+	return words > 0
 }
 
 // filterAndConvertBoxes filters valid boxes and converts them to BoundingBox format.
-// Boxes are excluded if they have zero confidence, no valid tokens,
-// or confidence below MinBoxConfidence (postprocessing threshold).
+// Boxes are excluded if their confidence is outside the Tesseract range, they have
+// no valid tokens, or confidence below MinBoxConfidence (postprocessing threshold).
 // Returns the filtered boxes and total token count.
-func (c *Classifier) filterAndConvertBoxes(boxes []gosseract.BoundingBox) ([]BoundingBox, int) {
+func (c *Classifier) filterAndConvertBoxes(boxes []RecognizedBox) ([]BoundingBox, int) {
 	resultBoxes := make([]BoundingBox, 0, len(boxes))
 	totalTokens := 0
 
 	for _, box := range boxes {
-		boxConfidence := float64(box.Confidence) / 100.0
-
-		if boxConfidence < minBoxConfidence {
+		boxConfidence, ok := normalizeConfidence(box.Confidence)
+		if !ok || boxConfidence < minBoxConfidence {
 			continue
 		}
 
@@ -156,79 +588,193 @@ func (c *Classifier) filterAndConvertBoxes(boxes []gosseract.BoundingBox) ([]Bou
 			Height:     box.Box.Max.Y - box.Box.Min.Y,
 			Word:       box.Word,
 			Confidence: boxConfidence,
+			layout:     layoutKey{block: box.Block, paragraph: box.Paragraph, line: box.Line},
 		})
 	}
 
 	return resultBoxes, totalTokens
 }
 
+// normalizeConfidence converts an OCR engine word confidence (0-100) to the 0-1 scale
+// used by boxes and aggregates. Values outside 0-100 (or NaN) are reported as invalid,
+// so that such boxes are dropped instead of being silently clamped.
+func normalizeConfidence(raw float64) (float64, bool) {
+	if math.IsNaN(raw) || raw < 0 || raw > maxEngineConfidence {
+		return 0, false
+	}
+	return raw / maxEngineConfidence, true
+}
+
 // calculateConfidenceMetrics calculates mean and weighted confidence from boxes.
+// Box confidences are already normalized to 0-1, so both metrics stay in that range.
 func (c *Classifier) calculateConfidenceMetrics(boxes []BoundingBox, totalTokens int) (meanConfidence, weightedConfidence float64) {
 	var totalConfidence float64
 	var weightedConfidenceSum float64
 
 	for _, box := range boxes {
 		tokens := countTokens(box.Word)
-		totalConfidence += box.Confidence * 100.0
+		totalConfidence += box.Confidence
 		weightedConfidenceSum += box.Confidence * float64(tokens)
 	}
 
-	meanConfidence = totalConfidence / float64(len(boxes)) / 100.0
-	meanConfidence = clampFloat64(meanConfidence, 0.0, 1.0)
-
+	meanConfidence = totalConfidence / float64(len(boxes))
 	weightedConfidence = weightedConfidenceSum / float64(totalTokens)
-	weightedConfidence = clampFloat64(weightedConfidence, 0.0, 1.0)
 
 	return meanConfidence, weightedConfidence
 }
 
-// clampFloat64 clamps a float64 value to the range [min, max].
-func clampFloat64(value, min, max float64) float64 {
-	if value > max {
-		return max
-	}
-	if value < min {
-		return min
-	}
-	return value
-}
-
 // DetectText performs text detection on the provided image data.
 // It applies preprocessing, attempts OCR at multiple rotation angles if needed,
 // and evaluates the result against the provided decision rule.
+// A language of the form "merge:eng,rus" recognizes each language separately and merges the boxes,
+// "best-of:eng,rus" keeps the boxes of the most confident language; the AutoLanguage value delegates to DetectTextAuto,
+// the ScriptLanguage value picks the language from the scripts recognized in a first pass.
+// If ContentHash is set, the hash of imageData is added to the result, if ReportDPI is set, the DPI metadata,
+// if OriginalBoxes is set, the boxes in original image coordinates. The wall time of the
+// whole call is reported in ProcessingMillis.
 func (c *Classifier) DetectText(imageData []byte, rule DecisionRule) (*ClassifierResult, error) {
+	start := time.Now()
+	var hash string
+	if c.opts.ContentHash != "" {
+		hash = contentHash(imageData, c.opts.ContentHash)
+	}
+	result, err := c.detectText(imageData, rule)
+	if err != nil {
+		return nil, err
+	}
+	result.ContentHash = hash
+	if c.opts.OriginalBoxes {
+		result.OriginalBoxes = result.OriginalSpaceBoxes()
+	}
+	if c.opts.ReportDPI {
+		result.DPI = c.dpiInfo(imageData, result)
+	}
+	if !c.opts.PreserveColor {
+		result.source = nil
+	}
+	result.ProcessingMillis = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// detectText is DetectText without the content hash, used for the nested
+// detections of the auto, merge and best-of modes.
+func (c *Classifier) detectText(imageData []byte, rule DecisionRule) (*ClassifierResult, error) {
+	if rule.Language == AutoLanguage {
+		return c.DetectTextAuto(imageData, rule)
+	}
+	if rule.Language == ScriptLanguage {
+		return c.detectTextScript(imageData, rule)
+	}
+	if langs, ok := parseMergeLanguages(rule.Language); ok {
+		return c.detectTextMerged(imageData, rule, langs)
+	}
+	if langs, ok := parseLanguageList(rule.Language, BestOfLanguagePrefix); ok {
+		return c.detectTextBestOf(imageData, rule, langs)
+	}
 	rule = c.normalizeDecisionRule(rule)
 
-	img, err := c.decodeImage(imageData)
-	if err != nil {
+	prepared := rule.prepared
+	if prepared == nil {
+		prepared = c.prepareInput(imageData, rule)
+	}
+	if err := prepared.err; err != nil {
+		if errors.Is(err, ErrUnsupportedColorModel) || errors.Is(err, ErrImageTooLarge) || errors.Is(err, ErrInvalidROI) {
+			return nil, err
+		}
+		if rule.ROI != nil {
+			return nil, fmt.Errorf("%w: image cannot be decoded for cropping", ErrInvalidROI)
+		}
 		return c.detectWithoutPreprocessing(imageData, rule)
 	}
+	source, img, input, pad := prepared.source, prepared.img, prepared.input, prepared.pad
 
-	return c.detectWithPreprocessing(img, rule)
+	result, err := c.detectWithProfileChain(input, rule)
+	if err != nil {
+		return nil, err
+	}
+	if c.keepSource() {
+		result.source = source
+	}
+	if c.opts.TextColor {
+		start := rule.Timing.start()
+		annotateTextColors(input, result)
+		rule.Timing.record("text_color", start)
+	}
+	origin := image.Point{}
+	if rule.ROI != nil {
+		origin = rule.ROI.Min
+	}
+	if origin = origin.Sub(pad); origin != (image.Point{}) {
+		offsetBoxes(result, origin, source.Bounds().Size())
+	}
+	if c.opts.QualityWarnings {
+		_, format, _ := image.DecodeConfig(bytes.NewReader(imageData))
+		result.QualityWarnings = assessImageQuality(img, format == "jpeg")
+	}
+	result.Timings = rule.Timing.Stages()
+	return result, nil
+}
+
+// keepSource reports whether results carry the decoded original image: it is needed
+// for PreserveColor and for the text colors of the merge mode.
+func (c *Classifier) keepSource() bool {
+	return c.opts.PreserveColor || c.opts.TextColor
 }
 
 // normalizeDecisionRule ensures valid decision rule parameters.
 func (c *Classifier) normalizeDecisionRule(rule DecisionRule) DecisionRule {
+	if rule.Language == "" {
+		rule.Language = c.opts.DefaultLanguage
+	}
 	if rule.MinConfidence <= 0 || rule.MinConfidence > 1 {
-		rule.MinConfidence = GetDefaultDecisionRule().MinConfidence
+		rule.MinConfidence = c.opts.MinConfidence
+		if threshold, ok := c.opts.LanguageConfidence[rule.Language]; ok {
+			rule.MinConfidence = threshold
+		}
+	}
+	if rule.MinConfidence > c.opts.MaxConfidence {
+		rule.MinConfidence = c.opts.MaxConfidence
 	}
 	if rule.MinTokenCount <= 0 {
 		rule.MinTokenCount = GetDefaultDecisionRule().MinTokenCount
 	}
-	if rule.Language == "" {
-		rule.Language = DefaultLanguage
-	}
 	if rule.Level == nil {
 		level := DefaultPageIteratorLevel
 		rule.Level = &level
 	}
+	if c.opts.Blocks {
+		rule.Layout = true
+	}
+	if rule.OEM == "" {
+		rule.OEM = c.opts.OEM
+	}
+	if rule.OEM == "" {
+		rule.OEM = OEMDefault
+	}
+	if rule.PSM == "" {
+		rule.PSM = c.opts.PSM
+	}
 	return rule
 }
 
-// decodeImage attempts to decode image data.
+// ErrImageTooLarge is returned by decodeImage for images with more pixels than Options.MaxPixels.
+var ErrImageTooLarge = errors.New("image too large to be processed")
+
+// decodeImage attempts to decode image data. Images above MaxPixels are rejected with
+// ErrImageTooLarge before they are decoded. CMYK and 16-bit images are normalized
+// or rejected according to Options.ColorModels.
 func (c *Classifier) decodeImage(imageData []byte) (image.Image, error) {
-	img, _, err := image.Decode(bytes.NewReader(imageData))
-	return img, err
+	if c.opts.MaxPixels > 0 {
+		config, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+		if err == nil && int64(config.Width)*int64(config.Height) > int64(c.opts.MaxPixels) {
+			return nil, fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrImageTooLarge, config.Width, config.Height, c.opts.MaxPixels)
+		}
+	}
+	img, _, err := decodeImageData(imageData)
+	if err != nil {
+		return nil, err
+	}
+	return c.normalizeColorModel(img)
 }
 
 // detectWithoutPreprocessing performs OCR without image preprocessing.
@@ -241,38 +787,197 @@ func (c *Classifier) detectWithoutPreprocessing(imageData []byte, rule DecisionR
 	}
 	result.Angle = 0
 	result.ScaleFactor = 0
+	result.UprightConfidence = result.WeightedConfidence
 	result.IsTextDocument = EvaluateDecision(result.WeightedConfidence, result.TokenCount, rule)
 	return result, nil
 }
 
 // detectWithPreprocessing performs OCR with image preprocessing and rotation detection.
 func (c *Classifier) detectWithPreprocessing(img image.Image, rule DecisionRule) (*ClassifierResult, error) {
-	preprocessed, scaleFactor, imgWidth, imgHeight := preprocessImage(img)
+	// The image prepared by PrepareImage is reused if it was preprocessed with the same parameters
+	opts := c.preprocessOptions(rule)
+	pre := rule.prepared.preprocessedWith(opts)
+	if pre == nil {
+		pre = preprocessInput(img, opts, rule.Timing)
+	}
+	preprocessed, scaleFactor, imgWidth, imgHeight, threshold := pre.img, pre.scaleFactor, pre.width, pre.height, pre.threshold
 	if preprocessed == nil {
-		return &ClassifierResult{IsTextDocument: false}, nil
+		bounds := img.Bounds()
+		return &ClassifierResult{
+			Boxes:             []BoundingBox{},
+			IsTextDocument:    false,
+			BoundingBoxWidth:  bounds.Dx(),
+			BoundingBoxHeight: bounds.Dy(),
+			Status:            StatusTooSmall,
+		}, nil
+	}
+
+	// Phase 1 recognizes the deskewed image when deskewing is enabled and the skew is reliable
+	phase1, skew := preprocessed, 0
+	if c.opts.Deskew {
+		start := rule.Timing.start()
+		if skew = estimateDeskewAngle(grayImage(preprocessed)); skew != 0 {
+			phase1 = rotateImage(preprocessed, skew, c.opts.RotationFill)
+		}
+		rule.Timing.record("deskew", start)
 	}
 
-	preprocessedData, err := encodeImage(preprocessed, "png")
+	start := rule.Timing.start()
+	preprocessedData, err := c.encodeIntermediate(phase1)
+	rule.Timing.record("encode_0", start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode preprocessed image: %w", err)
 	}
 
-	result, err := c.detectTextOriginal(preprocessedData, scaleFactor, rule, imgWidth, imgHeight)
+	upright, err := c.detectTextOriginal(preprocessedData, scaleFactor, rule, imgWidth, imgHeight)
 	if err != nil {
 		return nil, err
 	}
+	if err := rule.canceled(); err != nil {
+		return nil, err
+	}
+
+	// Phase 2 treats the deskewed result as upright, so that RotationMargin and the
+	// tie-breaks apply to it; the skew is reported only if it remains the result
+	result, err := c.detectOrientation(preprocessed, scaleFactor, upright, rule, imgWidth, imgHeight)
+	if err != nil {
+		return nil, err
+	}
+	if result == upright {
+		result.Angle, result.RawAngle = skew, skew
+	}
+	if err := rule.canceled(); err != nil {
+		return nil, err
+	}
+
+	if c.opts.MultiScale && !c.preprocessOptions(rule).Off {
+		var scaled image.Image
+		if result, scaled = c.tryScales(img, result, rule); scaled != nil {
+			preprocessed, scaleFactor = scaled, result.ScaleFactor
+			imgWidth, imgHeight = scaled.Bounds().Dx(), scaled.Bounds().Dy()
+		}
+	}
+
+	if c.opts.MixedOrientation {
+		start = rule.Timing.start()
+		c.detectMixedOrientation(preprocessed, result, rule)
+		rule.Timing.record("mixed_orientation", start)
+	}
 
-	if result.IsTextDocument {
-		return result, nil
+	bounds := img.Bounds()
+	result.ScaleFactorX, result.ScaleFactorY = axisScaleFactors(bounds.Dx(), bounds.Dy(), imgWidth, imgHeight, scaleFactor)
+	result.UprightConfidence = upright.WeightedConfidence
+	if c.opts.ReportThreshold && result.Threshold == nil {
+		result.Threshold = threshold
 	}
+	return result, nil
+}
 
-	return c.detectTextWithRotations(preprocessed, scaleFactor, result, rule, imgWidth, imgHeight)
+// axisScales returns the scale of the OCR input relative to the original image per axis,
+// 1 if the image was not preprocessed.
+func (r *ClassifierResult) axisScales() (scaleX, scaleY float64) {
+	if r.ScaleFactorX > 0 && r.ScaleFactorY > 0 {
+		return r.ScaleFactorX, r.ScaleFactorY
+	}
+	if r.ScaleFactor > 0 {
+		return r.ScaleFactor, r.ScaleFactor
+	}
+	return 1, 1
+}
+
+// detectOrientation runs the second phase of detection (rotation search) unless it is
+// disabled with SkipRotation or the upright result passes the phase 2 gate (see phase2Gate).
+// With OSD enabled, a confident detected orientation replaces the search.
+func (c *Classifier) detectOrientation(preprocessed image.Image, scaleFactor float64, upright *ClassifierResult, rule DecisionRule, imgWidth, imgHeight int) (*ClassifierResult, error) {
+	if c.opts.SkipRotation || rule.SkipRotation {
+		upright.Phase2Gate = Phase2GateDisabled
+		return upright, nil
+	}
+
+	gate := c.phase2Gate(upright, rule)
+	if gate == Phase2GateSkip {
+		upright.Phase2Gate = gate
+		return upright, nil
+	}
+
+	if c.opts.OSD {
+		result, ok, err := c.detectWithOSD(preprocessed, scaleFactor, upright, rule, imgWidth, imgHeight)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result.Phase2Gate = gate
+			return result, nil
+		}
+	}
+
+	result := upright
+	if c.opts.FlipCheck {
+		var decided bool
+		result, decided = c.disambiguateFlip(preprocessed, scaleFactor, result, rule, imgWidth, imgHeight)
+		if decided {
+			result.Phase2Gate = gate
+			if c.opts.OSD {
+				result.OrientationSource = OrientationSourceSweep
+			}
+			return result, nil
+		}
+	}
+
+	angles := rule.Angles
+	if angles == nil {
+		angles = c.opts.Angles
+	}
+	var err error
+	switch {
+	case angles != nil:
+		result, err = c.tryRotationAngles(preprocessed, scaleFactor, result, rule, angles, imgWidth, imgHeight)
+	case c.opts.Orientation == OrientationEstimate:
+		result, err = c.detectTextWithEstimate(preprocessed, scaleFactor, result, rule, imgWidth, imgHeight)
+	default:
+		result, err = c.detectTextWithRotations(preprocessed, scaleFactor, result, rule, imgWidth, imgHeight)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result = c.snapToCardinal(preprocessed, scaleFactor, result, rule, imgWidth, imgHeight)
+	result.Phase2Gate = gate
+	if c.opts.OSD {
+		result.OrientationSource = OrientationSourceSweep
+	}
+	return result, nil
+}
+
+// phase2Gate decides whether the rotation search can be skipped: the upright result must
+// reach both SkipSweepConfidence and SkipSweepMinTokens, which default to the decision
+// rule's thresholds. Returns Phase2GateSkip, or the reason the search is needed.
+func (c *Classifier) phase2Gate(upright *ClassifierResult, rule DecisionRule) string {
+	minConfidence := c.opts.SkipSweepConfidence
+	if minConfidence <= 0 {
+		minConfidence = rule.MinConfidence
+	}
+	minTokens := c.opts.SkipSweepMinTokens
+	if minTokens <= 0 {
+		minTokens = rule.MinTokenCount
+	}
+
+	switch {
+	case upright.WeightedConfidence < minConfidence:
+		return Phase2GateLowConfidence
+	case upright.TokenCount < minTokens:
+		return Phase2GateLowTokenCount
+	default:
+		return Phase2GateSkip
+	}
 }
 
 // detectTextOriginal performs the first phase of detection without rotation.
 func (c *Classifier) detectTextOriginal(imageData []byte, scaleFactor float64, rule DecisionRule, imgWidth, imgHeight int) (*ClassifierResult, error) {
 	rule = c.normalizeDecisionRule(rule)
+	start := rule.Timing.start()
 	result, err := c.detectTextSingle(imageData, rule.OCRParams)
+	rule.Timing.record("ocr_0", start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect text in phase 1: %w", err)
 	}
@@ -287,9 +992,11 @@ func (c *Classifier) detectTextOriginal(imageData []byte, scaleFactor float64, r
 }
 
 // detectTextWithRotations attempts OCR at multiple rotation angles to find the best text detection.
-// It uses candidate angles detected via Canny edge detection and Hough Line Transform.
-func (c *Classifier) detectTextWithRotations(preprocessed *image.Gray, scaleFactor float64, phase1Result *ClassifierResult, rule DecisionRule, imgWidth, imgHeight int) (*ClassifierResult, error) {
-	candidateAngles := detectSkewAngle(preprocessed)
+// It uses candidate angles detected via Canny edge detection and Hough Line Transform,
+// pruned by clampNearUpright when SkewClamp is set.
+func (c *Classifier) detectTextWithRotations(preprocessed image.Image, scaleFactor float64, phase1Result *ClassifierResult, rule DecisionRule, imgWidth, imgHeight int) (*ClassifierResult, error) {
+	gray := grayImage(preprocessed)
+	candidateAngles := c.clampNearUpright(gray, detectSkewAngle(gray))
 
 	if len(candidateAngles) == 0 {
 		phase1Result.IsTextDocument = EvaluateDecision(phase1Result.WeightedConfidence, phase1Result.TokenCount, rule)
@@ -300,52 +1007,139 @@ func (c *Classifier) detectTextWithRotations(preprocessed *image.Gray, scaleFact
 }
 
 // tryRotationAngles attempts OCR at each candidate angle and returns the best result.
-func (c *Classifier) tryRotationAngles(preprocessed *image.Gray, scaleFactor float64, currentBest *ClassifierResult, rule DecisionRule, angles []int, imgWidth, imgHeight int) (*ClassifierResult, error) {
+// If every pass fails, currentBest is returned with WarningRotationFailed, or, when
+// currentBest has no tokens to fall back on, an error aggregating the pass failures.
+func (c *Classifier) tryRotationAngles(preprocessed image.Image, scaleFactor float64, currentBest *ClassifierResult, rule DecisionRule, angles []int, imgWidth, imgHeight int) (*ClassifierResult, error) {
+	if coarse := downscaleForCoarseSearch(preprocessed, c.opts.CoarseScale); coarse != nil {
+		return c.tryRotationAnglesCoarse(preprocessed, coarse, scaleFactor, currentBest, rule, angles, imgWidth, imgHeight)
+	}
+
 	bestResult := currentBest
+	passes := 0
+	var errs []error
 
-	for _, angle := range angles {
-		if angle == 0 {
+	for _, angle := range c.sweepAngles(angles) {
+		if err := rule.canceled(); err != nil {
+			return nil, err
+		}
+		passes++
+		result, shouldReturn, err := c.trySingleRotation(preprocessed, scaleFactor, rule, angle, imgWidth, imgHeight)
+		if err != nil {
+			errs = append(errs, err)
 			continue
 		}
-
-		result, shouldReturn := c.trySingleRotation(preprocessed, scaleFactor, rule, angle, imgWidth, imgHeight)
-		if shouldReturn && result != nil {
+		// A pass that qualifies as text wins outright, the margin only applies among the others
+		if shouldReturn {
+			result.SweepPasses = passes
 			return result, nil
 		}
 
-		if result != nil && result.WeightedConfidence > bestResult.WeightedConfidence {
+		if c.exceedsRotationMargin(result, currentBest) && betterRotation(result, bestResult) {
 			bestResult = result
 		}
 	}
 
+	if err := checkSweepFailures(currentBest, passes, errs); err != nil {
+		return nil, err
+	}
+
+	bestResult.SweepPasses = passes
 	bestResult.IsTextDocument = EvaluateDecision(bestResult.WeightedConfidence, bestResult.TokenCount, rule)
 	return bestResult, nil
 }
 
+// checkSweepFailures handles a sweep in which every pass failed: it adds
+// WarningRotationFailed to currentBest, or returns an error aggregating the pass
+// failures if currentBest has no tokens to fall back on.
+func checkSweepFailures(currentBest *ClassifierResult, passes int, errs []error) error {
+	if passes == 0 || len(errs) < passes {
+		return nil
+	}
+	if currentBest.TokenCount == 0 {
+		return fmt.Errorf("all %d rotation passes failed: %w", passes, errors.Join(errs...))
+	}
+	currentBest.Warnings = append(currentBest.Warnings, WarningRotationFailed)
+	return nil
+}
+
+// sweepAngles returns the candidate angles phase 2 should try: angles already covered
+// by phase 1 (and the flip check, if enabled) are dropped, and when MaxSweepPasses is set
+// the list is cut to that many angles, cardinal orientations first.
+func (c *Classifier) sweepAngles(angles []int) []int {
+	result := make([]int, 0, len(angles))
+	for _, angle := range angles {
+		if angle == 0 || (c.opts.FlipCheck && angle == 180) {
+			continue
+		}
+		result = append(result, angle)
+	}
+
+	if c.opts.MaxSweepPasses <= 0 || len(result) <= c.opts.MaxSweepPasses {
+		return result
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i]%90 == 0 && result[j]%90 != 0
+	})
+	return result[:c.opts.MaxSweepPasses]
+}
+
+// exceedsRotationMargin reports whether a rotated result improves on the upright result
+// by at least RotationMargin. Without a margin, and for results compared against a
+// non-upright baseline, it always holds.
+func (c *Classifier) exceedsRotationMargin(rotated, upright *ClassifierResult) bool {
+	if c.opts.RotationMargin <= 0 || upright.Angle != 0 {
+		return true
+	}
+	return rotated.WeightedConfidence >= upright.WeightedConfidence+c.opts.RotationMargin
+}
+
+// betterRotation reports whether candidate beats best. Weighted confidences within
+// confidenceTieEpsilon are a tie, broken deterministically by preferring the angle closer
+// to a cardinal orientation, then the higher token count, then the lower angle.
+func betterRotation(candidate, best *ClassifierResult) bool {
+	if diff := candidate.WeightedConfidence - best.WeightedConfidence; math.Abs(diff) > confidenceTieEpsilon {
+		return diff > 0
+	}
+	if dc, db := cardinalDistance(candidate.Angle), cardinalDistance(best.Angle); dc != db {
+		return dc < db
+	}
+	if candidate.TokenCount != best.TokenCount {
+		return candidate.TokenCount > best.TokenCount
+	}
+	return candidate.Angle < best.Angle
+}
+
 // trySingleRotation attempts OCR at a single rotation angle.
-// Returns the result, and a boolean indicating if early exit should occur.
-func (c *Classifier) trySingleRotation(preprocessed *image.Gray, scaleFactor float64, rule DecisionRule, angle int, imgWidth, imgHeight int) (*ClassifierResult, bool) {
+// Returns the result, a boolean indicating if early exit should occur, and the error
+// if the pass failed (the result is nil then).
+func (c *Classifier) trySingleRotation(preprocessed image.Image, scaleFactor float64, rule DecisionRule, angle int, imgWidth, imgHeight int) (*ClassifierResult, bool, error) {
 	rule = c.normalizeDecisionRule(rule)
-	rotated := rotateImage(preprocessed, angle)
-	data, err := encodeImage(rotated, "png")
+	start := rule.Timing.start()
+	rotated := rotateImage(preprocessed, angle, c.opts.RotationFill)
+	data, err := c.encodeIntermediate(rotated)
+	rule.Timing.recordAngle("rotate", angle, start)
 	if err != nil {
-		return nil, false
+		return nil, false, fmt.Errorf("failed to encode image rotated by %d degrees: %w", angle, err)
 	}
 
+	start = rule.Timing.start()
 	res, err := c.detectTextSingle(data, rule.OCRParams)
+	rule.Timing.recordAngle("ocr", angle, start)
 	if err != nil {
-		return nil, false
+		return nil, false, fmt.Errorf("failed to detect text at %d degrees: %w", angle, err)
 	}
 
 	res.Angle = angle
+	res.RawAngle = angle
 	res.ScaleFactor = scaleFactor
 	res.BoundingBoxWidth = imgWidth
 	res.BoundingBoxHeight = imgHeight
 
 	if EvaluateDecision(res.WeightedConfidence, res.TokenCount, rule) {
 		res.IsTextDocument = true
-		return res, true
+		return res, true, nil
 	}
 
-	return res, false
+	return res, false, nil
 }
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBetterRotation(t *testing.T) {
+	result := func(angle int, confidence float64, tokens int) *ClassifierResult {
+		return &ClassifierResult{Angle: angle, WeightedConfidence: confidence, TokenCount: tokens}
+	}
+	tie := confidenceTieEpsilon / 2
+
+	tests := []struct {
+		name      string
+		candidate *ClassifierResult
+		best      *ClassifierResult
+		want      bool
+	}{
+		{name: "higher confidence wins", candidate: result(95, 0.80, 10), best: result(90, 0.70, 10), want: true},
+		{name: "lower confidence loses", candidate: result(90, 0.70, 10), best: result(95, 0.80, 10), want: false},
+		{name: "tie prefers cardinal angle", candidate: result(90, 0.70, 10), best: result(95, 0.70+tie, 10), want: true},
+		{name: "tie keeps cardinal best", candidate: result(95, 0.70+tie, 10), best: result(90, 0.70, 10), want: false},
+		{name: "tie prefers angle closer to cardinal", candidate: result(358, 0.70, 10), best: result(5, 0.70, 10), want: true},
+		{name: "tie prefers more tokens", candidate: result(180, 0.70, 12), best: result(90, 0.70, 10), want: true},
+		{name: "tie keeps best with more tokens", candidate: result(90, 0.70, 10), best: result(180, 0.70, 12), want: false},
+		{name: "tie prefers lower angle", candidate: result(90, 0.70, 10), best: result(270, 0.70, 10), want: true},
+		{name: "tie keeps lower angle", candidate: result(270, 0.70, 10), best: result(90, 0.70, 10), want: false},
+		{name: "identical results keep best", candidate: result(90, 0.70, 10), best: result(90, 0.70, 10), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := betterRotation(tt.candidate, tt.best); got != tt.want {
+				t.Errorf("betterRotation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotationSweepTieIsDeterministic(t *testing.T) {
+	// Every angle recognizes the same words with the same confidence, so the winner
+	// must not depend on the order of the angles
+	c := newTestClassifier(Options{}, staticEngine(word("rotated", 5, 5, 40), word("text", 5, 30, 40)))
+	preprocessed := textImage(60, 48)
+	rule := c.DefaultDecisionRule()
+
+	for _, angles := range [][]int{{95, 5, 90, 270}, {270, 90, 5, 95}, {5, 270, 95, 90}} {
+		upright := &ClassifierResult{WeightedConfidence: 0.1, TokenCount: 3}
+		result, err := c.tryRotationAngles(preprocessed, 1, upright, rule, angles, 60, 48)
+		if err != nil {
+			t.Fatalf("angles %v: %v", angles, err)
+		}
+		if result.Angle != 90 {
+			t.Errorf("angles %v: winning angle %d, want 90", angles, result.Angle)
+		}
+	}
+}
+
+func TestRotationSweepMargin(t *testing.T) {
+	// words returns n five-character words, n*5 tokens, recognized with confidence
+	words := func(n int, confidence float64) engineFunc {
+		boxes := make([]RecognizedBox, n)
+		for i := range boxes {
+			boxes[i] = word("words", 2, 2+i*8, confidence)
+		}
+		return staticEngine(boxes...)
+	}
+
+	tests := []struct {
+		name      string
+		margin    float64
+		upright   *ClassifierResult
+		rotated   engineFunc
+		wantAngle int
+	}{
+		{
+			name:    "qualifying rotation below upright confidence",
+			upright: &ClassifierResult{WeightedConfidence: 0.70, TokenCount: 5},
+			rotated: words(5, 68), wantAngle: 90,
+		},
+		{
+			name: "margin does not veto a qualifying rotation", margin: 0.1,
+			upright: &ClassifierResult{WeightedConfidence: 0.70, TokenCount: 5},
+			rotated: words(5, 68), wantAngle: 90,
+		},
+		{
+			name:    "better non-qualifying rotation without margin",
+			upright: &ClassifierResult{WeightedConfidence: 0.60, TokenCount: 5},
+			rotated: words(1, 68), wantAngle: 90,
+		},
+		{
+			name: "non-qualifying rotation within margin", margin: 0.1,
+			upright: &ClassifierResult{WeightedConfidence: 0.60, TokenCount: 5},
+			rotated: words(1, 68), wantAngle: 0,
+		},
+		{
+			name:    "worse non-qualifying rotation",
+			upright: &ClassifierResult{WeightedConfidence: 0.60, TokenCount: 5},
+			rotated: words(1, 58), wantAngle: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClassifier(Options{RotationMargin: tt.margin}, tt.rotated)
+			result, err := c.tryRotationAngles(textImage(60, 48), 1, tt.upright, c.DefaultDecisionRule(), []int{90}, 60, 48)
+			if err != nil {
+				t.Fatalf("tryRotationAngles failed: %v", err)
+			}
+			if result.Angle != tt.wantAngle {
+				t.Errorf("angle = %d (confidence %v, %d tokens), want %d",
+					result.Angle, result.WeightedConfidence, result.TokenCount, tt.wantAngle)
+			}
+		})
+	}
+}
+
+func TestRotationSweepFailures(t *testing.T) {
+	errOCR := errors.New("failed to get bounding boxes")
+	failing := engineFunc(func([]byte, OCRParams) ([]RecognizedBox, error) {
+		return nil, errOCR
+	})
+	var calls atomic.Int32
+	failingOnce := engineFunc(func([]byte, OCRParams) ([]RecognizedBox, error) {
+		if calls.Add(1) == 1 {
+			return nil, errOCR
+		}
+		return []RecognizedBox{word("rotated", 5, 5, 40)}, nil
+	})
+
+	tests := []struct {
+		name        string
+		engine      OCREngine
+		tokens      int
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "all passes fail with upright tokens", engine: failing, tokens: 3, wantWarning: true},
+		{name: "all passes fail without upright tokens", engine: failing, wantErr: true},
+		{name: "some passes fail", engine: failingOnce, tokens: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClassifier(Options{}, tt.engine)
+			upright := &ClassifierResult{WeightedConfidence: 0.1, TokenCount: tt.tokens}
+
+			result, err := c.tryRotationAngles(textImage(60, 48), 1, upright, c.DefaultDecisionRule(), []int{90, 180, 270}, 60, 48)
+			if tt.wantErr {
+				if !errors.Is(err, errOCR) {
+					t.Fatalf("error = %v, want the aggregated pass failures", err)
+				}
+				if !strings.Contains(err.Error(), "all 3 rotation passes failed") {
+					t.Errorf("error = %q, want the pass count", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := slices.Contains(result.Warnings, WarningRotationFailed); got != tt.wantWarning {
+				t.Errorf("warnings = %v, want WarningRotationFailed: %v", result.Warnings, tt.wantWarning)
+			}
+			if tt.wantWarning && result != upright {
+				t.Errorf("got a rotated result, want the upright one")
+			}
+		})
+	}
+}
+
+func TestDetectTextRotationFailureWarning(t *testing.T) {
+	// Phase 1 finds too little to stop, then every rotation pass fails
+	var calls atomic.Int32
+	engine := engineFunc(func([]byte, OCRParams) ([]RecognizedBox, error) {
+		if calls.Add(1) == 1 {
+			return []RecognizedBox{word("faint", 5, 5, 35)}, nil
+		}
+		return nil, errors.New("failed to set image")
+	})
+	c := newTestClassifier(Options{Angles: []int{90, 180, 270}}, engine)
+
+	result, err := c.DetectText(encodePNG(t, textImage(48, 40)), c.DefaultDecisionRule())
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	if calls.Load() < 2 {
+		t.Fatalf("engine called %d times, want the rotation sweep to run", calls.Load())
+	}
+	if !slices.Contains(result.Warnings, WarningRotationFailed) {
+		t.Errorf("warnings = %v, want %q", result.Warnings, WarningRotationFailed)
+	}
+	if result.Angle != 0 {
+		t.Errorf("angle = %d, want the upright result", result.Angle)
+	}
+}
+
+func TestDetectTextRotatesUnpreprocessedImage(t *testing.T) {
+	// A color page with a red mark, so that a grayscale or rescaled copy is told apart
+	src := image.NewNRGBA(image.Rect(0, 0, 200, 120))
+	for i, g := range textImage(200, 120).Pix {
+		src.Pix[4*i], src.Pix[4*i+1], src.Pix[4*i+2], src.Pix[4*i+3] = g, g, g, 255
+	}
+	for y := 10; y < 30; y++ {
+		for x := 150; x < 190; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 220, A: 255})
+		}
+	}
+
+	var rotated image.Image
+	engine := engineFunc(func(imageData []byte, params OCRParams) ([]RecognizedBox, error) {
+		img, err := png.Decode(bytes.NewReader(imageData))
+		if err != nil {
+			return nil, err
+		}
+		if img.Bounds().Dy() <= img.Bounds().Dx() {
+			return []RecognizedBox{word("rotated", 4, 4, 30)}, nil
+		}
+		rotated = img
+		return []RecognizedBox{word("rotated", 4, 4, 95), word("document", 4, 30, 95), word("page", 4, 56, 95)}, nil
+	})
+	c := newTestClassifier(Options{Angles: []int{90}}, engine)
+	rule := c.DefaultDecisionRule()
+	rule.Preprocess = &PreprocessOptions{Off: true}
+
+	result, err := c.DetectText(encodePNG(t, src), rule)
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	if result.Angle != 90 || result.ScaleFactor != 1 {
+		t.Fatalf("angle %d at scale %v, want 90 at 1", result.Angle, result.ScaleFactor)
+	}
+	// Boxes are returned as recognized, without un-scaling
+	want := []image.Rectangle{image.Rect(4, 4, 74, 24), image.Rect(4, 30, 84, 50), image.Rect(4, 56, 44, 76)}
+	if len(result.Boxes) != len(want) {
+		t.Fatalf("got %d boxes, want %d", len(result.Boxes), len(want))
+	}
+	for i, box := range result.Boxes {
+		if got := image.Rect(box.X, box.Y, box.X+box.Width, box.Y+box.Height); got != want[i] {
+			t.Errorf("box %d = %v, want %v", i, got, want[i])
+		}
+	}
+
+	// The engine got the decoded image itself rotated, in its original size and colors
+	wantImage := rotateImage(src, 90, c.opts.RotationFill)
+	if rotated == nil || rotated.Bounds().Size() != wantImage.Bounds().Size() {
+		t.Fatalf("rotated pass got %v, want a %v image", rotated, wantImage.Bounds().Size())
+	}
+	b := wantImage.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			got := color.NRGBAModel.Convert(rotated.At(x, y))
+			if want := color.NRGBAModel.Convert(wantImage.At(x, y)); got != want {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestNormalizeConfidence(t *testing.T) {
+	tests := []struct {
+		raw    float64
+		want   float64
+		wantOK bool
+	}{
+		{raw: 0, want: 0, wantOK: true},
+		{raw: 42.5, want: 0.425, wantOK: true},
+		{raw: 100, want: 1, wantOK: true},
+		{raw: -1},
+		{raw: 100.5},
+		{raw: math.NaN()},
+		{raw: math.Inf(1)},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeConfidence(tt.raw)
+		if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("normalizeConfidence(%v) = %v, %v, want %v, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestConfidenceBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		boxes      []RecognizedBox
+		wantTokens int
+		wantConf   float64
+	}{
+		{name: "in range", boxes: []RecognizedBox{word("ab", 0, 0, 80), word("cd", 0, 30, 60)}, wantTokens: 4, wantConf: 0.7},
+		{name: "full confidence", boxes: []RecognizedBox{word("ab", 0, 0, 100)}, wantTokens: 2, wantConf: 1},
+		{name: "above range dropped", boxes: []RecognizedBox{word("ab", 0, 0, 80), word("cd", 0, 30, 250)}, wantTokens: 2, wantConf: 0.8},
+		{name: "negative dropped", boxes: []RecognizedBox{word("ab", 0, 0, -5), word("cd", 0, 30, 60)}, wantTokens: 2, wantConf: 0.6},
+		{name: "NaN dropped", boxes: []RecognizedBox{word("ab", 0, 0, math.NaN()), word("cd", 0, 30, 90)}, wantTokens: 2, wantConf: 0.9},
+		{name: "all out of range", boxes: []RecognizedBox{word("ab", 0, 0, 101)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClassifier(Options{}, nil)
+			result, err := c.processBoundingBoxes(tt.boxes, 100, 100)
+			if err != nil {
+				t.Fatalf("processBoundingBoxes failed: %v", err)
+			}
+			if result.TokenCount != tt.wantTokens {
+				t.Errorf("TokenCount = %d, want %d", result.TokenCount, tt.wantTokens)
+			}
+			if math.Abs(result.WeightedConfidence-tt.wantConf) > 1e-9 {
+				t.Errorf("WeightedConfidence = %v, want %v", result.WeightedConfidence, tt.wantConf)
+			}
+			for _, box := range result.Boxes {
+				if box.Confidence < 0 || box.Confidence > 1 {
+					t.Errorf("box confidence %v outside 0-1", box.Confidence)
+				}
+			}
+		})
+	}
+}
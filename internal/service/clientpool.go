@@ -0,0 +1,74 @@
+package service
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/otiai10/gosseract/v2"
+)
+
+// clientKey identifies the settings a Tesseract client is initialized with. Tesseract
+// loads the language data and config file once, and the page segmentation mode stays
+// set on the client, so a client is reused only for the same language, engine mode and
+// page segmentation mode.
+type clientKey struct {
+	language   string
+	configFile string
+	psm        string
+}
+
+// clientPool keeps idle gosseract clients for reuse, so recognition passes skip the
+// Tesseract initialization. It is safe for concurrent use; a client is used by one
+// goroutine at a time between get and put.
+type clientPool struct {
+	mu      sync.Mutex
+	idle    map[clientKey][]*gosseract.Client
+	maxIdle int
+	closed  bool
+}
+
+// newClientPool creates a pool keeping at most GOMAXPROCS idle clients per key.
+func newClientPool() *clientPool {
+	return &clientPool{idle: make(map[clientKey][]*gosseract.Client), maxIdle: runtime.GOMAXPROCS(0)}
+}
+
+// get returns an idle client for key, or nil if there is none.
+func (p *clientPool) get(key clientKey) *gosseract.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clients := p.idle[key]
+	if len(clients) == 0 {
+		return nil
+	}
+	client := clients[len(clients)-1]
+	p.idle[key] = clients[:len(clients)-1]
+	return client
+}
+
+// put returns a client that completed a recognition to the pool, or closes it when
+// the pool is full or closed.
+func (p *clientPool) put(key clientKey, client *gosseract.Client) {
+	p.mu.Lock()
+	if !p.closed && len(p.idle[key]) < p.maxIdle {
+		p.idle[key] = append(p.idle[key], client)
+		client = nil
+	}
+	p.mu.Unlock()
+	if client != nil {
+		client.Close()
+	}
+}
+
+// close closes the idle clients; clients in use are closed when they are put back.
+func (p *clientPool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = make(map[clientKey][]*gosseract.Client)
+	p.closed = true
+	p.mu.Unlock()
+	for _, clients := range idle {
+		for _, client := range clients {
+			client.Close()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// downscaleForCoarseSearch returns a copy of preprocessed scaled by scale for the coarse
+// angle search, or nil if coarse search is disabled (scale outside (0, 1)) or the copy
+// would be too small to recognize.
+func downscaleForCoarseSearch(preprocessed image.Image, scale float64) *image.Gray {
+	if scale <= 0 || scale >= 1 {
+		return nil
+	}
+	bounds := preprocessed.Bounds()
+	w := int(float64(bounds.Dx())*scale + 0.5)
+	h := int(float64(bounds.Dy())*scale + 0.5)
+	if w <= minDimension || h <= minDimension {
+		return nil
+	}
+	return convertToGray(imaging.Resize(preprocessed, w, h, imaging.Linear), 255)
+}
+
+// tryRotationAnglesCoarse finds the best candidate angle on the downscaled coarse copy,
+// then runs one full-resolution pass at that angle. The full-resolution result replaces
+// currentBest under the same rules as in tryRotationAngles. The coarse passes and the
+// final pass are all counted in SweepPasses.
+func (c *Classifier) tryRotationAnglesCoarse(preprocessed image.Image, coarse *image.Gray, scaleFactor float64, currentBest *ClassifierResult, rule DecisionRule, angles []int, imgWidth, imgHeight int) (*ClassifierResult, error) {
+	coarseScale := float64(coarse.Bounds().Dx()) / float64(preprocessed.Bounds().Dx())
+	coarseW, coarseH := coarse.Bounds().Dx(), coarse.Bounds().Dy()
+
+	var coarseBest *ClassifierResult
+	passes := 0
+	var errs []error
+	for _, angle := range c.sweepAngles(angles) {
+		if err := rule.canceled(); err != nil {
+			return nil, err
+		}
+		passes++
+		result, isText, err := c.trySingleRotation(coarse, scaleFactor*coarseScale, rule, angle, coarseW, coarseH)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if coarseBest == nil || betterRotation(result, coarseBest) {
+			coarseBest = result
+		}
+		if isText {
+			break
+		}
+	}
+
+	if err := checkSweepFailures(currentBest, passes, errs); err != nil {
+		return nil, err
+	}
+
+	bestResult := currentBest
+	if coarseBest != nil {
+		passes++
+		result, isText, err := c.trySingleRotation(preprocessed, scaleFactor, rule, coarseBest.Angle, imgWidth, imgHeight)
+		if err == nil && (isText || c.exceedsRotationMargin(result, currentBest) && betterRotation(result, currentBest)) {
+			bestResult = result
+		}
+	}
+
+	bestResult.SweepPasses = passes
+	bestResult.IsTextDocument = EvaluateDecision(bestResult.WeightedConfidence, bestResult.TokenCount, rule)
+	return bestResult, nil
+}
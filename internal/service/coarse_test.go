@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+// portraitEngine recognizes text confidently only in portrait images, so among
+// the candidate angles of a landscape page only 90 and 270 win.
+func portraitEngine(imageData []byte, _ OCRParams) ([]RecognizedBox, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, err
+	}
+	confidence := 30.0
+	if cfg.Height > cfg.Width {
+		confidence = 95
+	}
+	return []RecognizedBox{word("rotated", 4, 4, confidence), word("page", 4, 30, confidence)}, nil
+}
+
+func TestDownscaleForCoarseSearch(t *testing.T) {
+	img := textImage(200, 120)
+	tests := []struct {
+		scale    float64
+		wantSize image.Point
+	}{
+		{scale: 0},
+		{scale: 1},
+		{scale: 0.5, wantSize: image.Pt(100, 60)},
+		// 20x12 is below the minimum dimension
+		{scale: 0.1},
+	}
+	for _, tt := range tests {
+		coarse := downscaleForCoarseSearch(img, tt.scale)
+		if tt.wantSize == (image.Point{}) {
+			if coarse != nil {
+				t.Errorf("scale %v: got a %v copy, want none", tt.scale, coarse.Bounds().Size())
+			}
+			continue
+		}
+		if coarse == nil || coarse.Bounds().Size() != tt.wantSize {
+			t.Errorf("scale %v: got %v, want %v", tt.scale, coarse, tt.wantSize)
+		}
+	}
+}
+
+func TestCoarseSearchFindsAngle(t *testing.T) {
+	c := newTestClassifier(Options{CoarseScale: 0.5}, engineFunc(portraitEngine))
+	preprocessed := textImage(200, 120)
+	angles := []int{5, 90, 180, 355}
+	upright := &ClassifierResult{WeightedConfidence: 0.3, TokenCount: 11}
+
+	result, err := c.tryRotationAngles(preprocessed, 1, upright, c.DefaultDecisionRule(), angles, 200, 120)
+	if err != nil {
+		t.Fatalf("tryRotationAngles failed: %v", err)
+	}
+	if result.Angle != 90 {
+		t.Errorf("angle = %d, want 90", result.Angle)
+	}
+	// The final pass recognizes the full-resolution image
+	if result.BoundingBoxWidth != 200 || result.BoundingBoxHeight != 120 || result.ScaleFactor != 1 {
+		t.Errorf("result is %dx%d at scale %v, want the full-resolution 200x120 at 1",
+			result.BoundingBoxWidth, result.BoundingBoxHeight, result.ScaleFactor)
+	}
+	if result.SweepPasses != len(angles)+1 {
+		t.Errorf("SweepPasses = %d, want %d coarse passes and the final one", result.SweepPasses, len(angles)+1)
+	}
+}
+
+// BenchmarkCoarseSearch measures the phase 2 sweep over 15 non-cardinal angles on a
+// full-size page, at full resolution and with coarse searches at half and quarter scale.
+func BenchmarkCoarseSearch(b *testing.B) {
+	preprocessed := textImage(2000, 1500)
+	var angles []int
+	for angle := 5; angle < 360 && len(angles) < 15; angle += 5 {
+		if angle%90 != 0 {
+			angles = append(angles, angle)
+		}
+	}
+	for _, bench := range []struct {
+		name  string
+		scale float64
+	}{
+		{name: "full", scale: 0},
+		{name: "half", scale: 0.5},
+		{name: "quarter", scale: 0.25},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			c := newTestClassifier(Options{CoarseScale: bench.scale}, engineFunc(portraitEngine))
+			rule := c.DefaultDecisionRule()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				upright := &ClassifierResult{WeightedConfidence: 0.3, TokenCount: 11}
+				if _, err := c.tryRotationAngles(preprocessed, 1, upright, rule, angles, 2000, 1500); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// textColorMinConfidence is the minimum box confidence (0-1) for a box to be sampled
+// for its text color: low-confidence boxes are often noise or graphics.
+const textColorMinConfidence = 0.6
+
+// colorSum accumulates RGB values of sampled pixels.
+type colorSum struct {
+	r, g, b, n uint64
+}
+
+func (s *colorSum) add(o colorSum) {
+	s.r, s.g, s.b, s.n = s.r+o.r, s.g+o.g, s.b+o.b, s.n+o.n
+}
+
+// hex returns the average color as "#rrggbb", or "" if nothing was sampled.
+func (s colorSum) hex() string {
+	if s.n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", s.r/s.n, s.g/s.n, s.b/s.n)
+}
+
+// annotateTextColors samples the original color image within each high-confidence box
+// of result and sets the box's dominant foreground color and the overall text color.
+// img must be the image recognition started from (after ROI cropping), boxes must still be
+// in the coordinates of the oriented, scaled OCR input.
+func annotateTextColors(img image.Image, result *ClassifierResult) {
+	scaleX, scaleY := result.axisScales()
+	oriented := img
+	if result.Angle != 0 {
+		oriented = rotateImage(img, result.Angle, RotationFillWhite)
+	}
+	bounds := oriented.Bounds()
+
+	var total colorSum
+	for i, box := range result.Boxes {
+		if box.Confidence < textColorMinConfidence {
+			continue
+		}
+		rect := image.Rect(
+			int(math.Floor(float64(box.X)/scaleX)),
+			int(math.Floor(float64(box.Y)/scaleY)),
+			int(math.Ceil(float64(box.X+box.Width)/scaleX)),
+			int(math.Ceil(float64(box.Y+box.Height)/scaleY)),
+		).Add(bounds.Min).Intersect(bounds)
+
+		fg := foregroundColor(oriented, rect)
+		result.Boxes[i].Color = fg.hex()
+		total.add(fg)
+	}
+	result.TextColor = total.hex()
+}
+
+// foregroundColor returns the color sum of the foreground pixels within rect.
+// Pixels are split at the mean luminance; the smaller group is taken as the text,
+// which handles both dark-on-light and light-on-dark text.
+func foregroundColor(img image.Image, rect image.Rectangle) colorSum {
+	if rect.Empty() {
+		return colorSum{}
+	}
+
+	type pixel struct{ r, g, b, lum uint32 }
+	pixels := make([]pixel, 0, rect.Dx()*rect.Dy())
+	var lumSum uint64
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			r, g, b = r>>8, g>>8, b>>8
+			lum := (299*r + 587*g + 114*b) / 1000
+			pixels = append(pixels, pixel{r, g, b, lum})
+			lumSum += uint64(lum)
+		}
+	}
+	mean := uint32(lumSum / uint64(len(pixels)))
+
+	var dark, light colorSum
+	for _, p := range pixels {
+		group := &light
+		if p.lum < mean {
+			group = &dark
+		}
+		group.add(colorSum{uint64(p.r), uint64(p.g), uint64(p.b), 1})
+	}
+	if light.n > 0 && light.n < dark.n {
+		return light
+	}
+	return dark
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// Handling of decoded images with color models the OCR input is not prepared for,
+// selected with Options.ColorModels.
+const (
+	// ColorModelsNormalize converts CMYK and 16-bit images to 8-bit RGB or grayscale after decoding.
+	ColorModelsNormalize = "normalize"
+	// ColorModelsReject rejects CMYK and 16-bit images with ErrUnsupportedColorModel.
+	ColorModelsReject = "reject"
+)
+
+// ErrUnsupportedColorModel is returned for images whose color model is rejected
+// (see ColorModelsReject).
+var ErrUnsupportedColorModel = errors.New("unsupported color model")
+
+// ValidateColorModels checks that policy is a supported color model handling.
+// Empty means ColorModelsNormalize.
+func ValidateColorModels(policy string) error {
+	switch policy {
+	case "", ColorModelsNormalize, ColorModelsReject:
+		return nil
+	}
+	return fmt.Errorf("unsupported color model handling %q, supported: %s, %s", policy, ColorModelsNormalize, ColorModelsReject)
+}
+
+// unusualColorModel names the color model of img if it is one that is normalized
+// or rejected: CMYK (pro-scanner JPEG output) or 16 bits per channel (PNG, TIFF).
+// It returns an empty string for 8-bit RGB, YCbCr, grayscale and paletted images.
+func unusualColorModel(img image.Image) string {
+	switch img.(type) {
+	case *image.CMYK:
+		return "CMYK"
+	case *image.Gray16:
+		return "16-bit grayscale"
+	case *image.RGBA64, *image.NRGBA64:
+		return "16-bit RGB"
+	case *image.Alpha16:
+		return "16-bit alpha"
+	}
+	return ""
+}
+
+// normalizeColorModel converts a CMYK or 16-bit image to 8-bit grayscale (for grayscale
+// input) or RGB, or rejects it with ErrUnsupportedColorModel under ColorModelsReject.
+// Other images are returned as is.
+func (c *Classifier) normalizeColorModel(img image.Image) (image.Image, error) {
+	model := unusualColorModel(img)
+	if model == "" {
+		return img, nil
+	}
+	if c.opts.ColorModels == ColorModelsReject {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedColorModel, model)
+	}
+
+	if gray16, ok := img.(*image.Gray16); ok {
+		b := gray16.Bounds()
+		gray := image.NewGray(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				gray.Pix[gray.PixOffset(x, y)] = uint8(gray16.Gray16At(x, y).Y >> 8)
+			}
+		}
+		return gray, nil
+	}
+	return imaging.Clone(img), nil
+}
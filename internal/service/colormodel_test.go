@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"math/bits"
+	"testing"
+)
+
+// encodeCMYKJPEG encodes img as a baseline Adobe CMYK JPEG, the format of pro-scanner
+// output that image/jpeg decodes but cannot encode. Every 8x8 block of img must be a
+// single color (its top-left pixel is used), so only DC coefficients are coded.
+func encodeCMYKJPEG(t testing.TB, img *image.CMYK) []byte {
+	t.Helper()
+	b := img.Bounds()
+	if b.Dx()%8 != 0 || b.Dy()%8 != 0 {
+		t.Fatalf("image size %v is not a multiple of 8", b.Size())
+	}
+
+	var buf bytes.Buffer
+	segment := func(marker byte, payload []byte) {
+		buf.Write([]byte{0xff, marker})
+		binary.Write(&buf, binary.BigEndian, uint16(len(payload)+2))
+		buf.Write(payload)
+	}
+	buf.Write([]byte{0xff, 0xd8})
+	// APP14 Adobe, transform 0: CMYK stored inverted
+	segment(0xee, []byte{'A', 'd', 'o', 'b', 'e', 0, 100, 0, 0, 0, 0, 0})
+	// Quantization table 0: all ones
+	segment(0xdb, append([]byte{0}, bytes.Repeat([]byte{1}, 64)...))
+	sof := []byte{8, byte(b.Dy() >> 8), byte(b.Dy()), byte(b.Dx() >> 8), byte(b.Dx()), 4}
+	for i := byte(1); i <= 4; i++ {
+		sof = append(sof, i, 0x11, 0)
+	}
+	segment(0xc0, sof)
+	// DC table: categories 0-11 as 4-bit codes equal to the category; AC table: only EOB, coded "0"
+	dc := make([]byte, 17, 29)
+	dc[4] = 12
+	for i := byte(0); i < 12; i++ {
+		dc = append(dc, i)
+	}
+	segment(0xc4, dc)
+	ac := make([]byte, 17, 18)
+	ac[0], ac[1] = 0x10, 1
+	segment(0xc4, append(ac, 0))
+	segment(0xda, []byte{4, 1, 0, 2, 0, 3, 0, 4, 0, 0, 63, 0})
+
+	var acc uint32
+	var nbits uint
+	writeBits := func(v uint32, n uint) {
+		acc, nbits = acc<<n|v&(1<<n-1), nbits+n
+		for nbits >= 8 {
+			c := byte(acc >> (nbits - 8))
+			buf.WriteByte(c)
+			if c == 0xff {
+				buf.WriteByte(0)
+			}
+			nbits -= 8
+		}
+	}
+	var prev [4]int
+	for y := b.Min.Y; y < b.Max.Y; y += 8 {
+		for x := b.Min.X; x < b.Max.X; x += 8 {
+			c := img.CMYKAt(x, y)
+			for i, v := range []uint8{c.C, c.M, c.Y, c.K} {
+				// A flat block of samples s has the DC coefficient 8*(s-128)
+				dcValue := 8 * (int(255-v) - 128)
+				diff := dcValue - prev[i]
+				prev[i] = dcValue
+				magnitude := diff
+				if diff < 0 {
+					magnitude = -diff
+				}
+				category := uint(bits.Len(uint(magnitude)))
+				writeBits(uint32(category), 4)
+				if diff < 0 {
+					diff += 1<<category - 1
+				}
+				writeBits(uint32(diff), category)
+				writeBits(0, 1)
+			}
+		}
+	}
+	if nbits > 0 {
+		writeBits(1<<(8-nbits)-1, 8-nbits)
+	}
+	buf.Write([]byte{0xff, 0xd9})
+	return buf.Bytes()
+}
+
+// cmykPage returns a white CMYK page with black text lines made of 8x8 blocks.
+func cmykPage(w, h int) *image.CMYK {
+	img := image.NewCMYK(image.Rect(0, 0, w, h))
+	for y := 16; y < h-16; y++ {
+		if (y/8)%2 == 0 {
+			continue
+		}
+		for x := 16; x < w-16; x++ {
+			img.SetCMYK(x, y, color.CMYK{K: 255})
+		}
+	}
+	return img
+}
+
+func TestCMYKJPEGFixture(t *testing.T) {
+	src := cmykPage(96, 64)
+	img, format, err := image.Decode(bytes.NewReader(encodeCMYKJPEG(t, src)))
+	if err != nil {
+		t.Fatalf("failed to decode the fixture: %v", err)
+	}
+	cmyk, ok := img.(*image.CMYK)
+	if !ok || format != "jpeg" {
+		t.Fatalf("decoded %T (%s), want *image.CMYK (jpeg)", img, format)
+	}
+	if !bytes.Equal(cmyk.Pix, src.Pix) {
+		t.Errorf("decoded pixels differ from the encoded ones")
+	}
+}
+
+func TestDetectTextCMYKJPEG(t *testing.T) {
+	imageData := encodeCMYKJPEG(t, cmykPage(96, 64))
+	tests := []struct {
+		policy  string
+		wantErr error
+	}{
+		{policy: ""},
+		{policy: ColorModelsNormalize},
+		{policy: ColorModelsReject, wantErr: ErrUnsupportedColorModel},
+	}
+	for _, tt := range tests {
+		c := newTestClassifier(Options{ColorModels: tt.policy, SkipRotation: true}, engineFunc(inkEngine))
+		result, err := c.DetectText(imageData, c.DefaultDecisionRule())
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("policy %q: error = %v, want %v", tt.policy, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("policy %q: DetectText failed: %v", tt.policy, err)
+		}
+		// The engine sees the black text: the CMYK page was converted, not misread
+		if result.TokenCount == 0 {
+			t.Errorf("policy %q: no ink recognized in the normalized image", tt.policy)
+		}
+	}
+}
+
+func TestNormalizeColorModel(t *testing.T) {
+	gray16 := image.NewGray16(image.Rect(0, 0, 2, 1))
+	gray16.SetGray16(0, 0, color.Gray16{Y: 0xffff})
+	gray16.SetGray16(1, 0, color.Gray16{Y: 0x1234})
+	rgba64 := image.NewRGBA64(image.Rect(0, 0, 1, 1))
+	rgba64.SetRGBA64(0, 0, color.RGBA64{R: 0xffff, G: 0x8000, B: 0, A: 0xffff})
+	cmyk := image.NewCMYK(image.Rect(0, 0, 1, 1))
+	cmyk.SetCMYK(0, 0, color.CMYK{K: 255})
+
+	tests := []struct {
+		name      string
+		img       image.Image
+		wantModel string
+		want      color.Color
+	}{
+		{name: "gray16", img: gray16, wantModel: "16-bit grayscale", want: color.Gray{Y: 0x12}},
+		{name: "rgba64", img: rgba64, wantModel: "16-bit RGB", want: color.NRGBA{R: 0xff, G: 0x80, A: 0xff}},
+		{name: "cmyk", img: cmyk, wantModel: "CMYK", want: color.NRGBA{A: 0xff}},
+		{name: "gray", img: image.NewGray(image.Rect(0, 0, 2, 1)), want: color.Gray{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unusualColorModel(tt.img); got != tt.wantModel {
+				t.Errorf("unusualColorModel = %q, want %q", got, tt.wantModel)
+			}
+			c := newTestClassifier(Options{}, nil)
+			img, err := c.normalizeColorModel(tt.img)
+			if err != nil {
+				t.Fatalf("normalizeColorModel failed: %v", err)
+			}
+			if unusualColorModel(img) != "" {
+				t.Errorf("normalized to %T, want an 8-bit model", img)
+			}
+			last := img.Bounds().Max.Sub(image.Pt(1, 1))
+			if got := img.ColorModel().Convert(img.At(last.X, last.Y)); got != img.ColorModel().Convert(tt.want) {
+				t.Errorf("pixel = %v, want %v", got, tt.want)
+			}
+
+			c = newTestClassifier(Options{ColorModels: ColorModelsReject}, nil)
+			if _, err := c.normalizeColorModel(tt.img); (tt.wantModel != "") != errors.Is(err, ErrUnsupportedColorModel) {
+				t.Errorf("reject: error = %v", err)
+			}
+		})
+	}
+}
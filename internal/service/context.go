@@ -0,0 +1,35 @@
+package service
+
+import "context"
+
+// DetectTextContext is DetectText bound to ctx: once ctx is done, no further OCR pass
+// starts (the rotation search stops between angles) and ctx.Err() is returned.
+// A pass already running in the engine completes first.
+func (c *Classifier) DetectTextContext(ctx context.Context, imageData []byte, rule DecisionRule) (*ClassifierResult, error) {
+	rule.ctx = ctx
+	result, err := c.DetectText(imageData, rule)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
+
+// DetectTextPagesContext is DetectTextPages bound to ctx like DetectTextContext;
+// pages after the one running when ctx is done are not processed.
+func (c *Classifier) DetectTextPagesContext(ctx context.Context, imageData []byte, rule DecisionRule) ([]*ClassifierResult, error) {
+	rule.ctx = ctx
+	results, err := c.DetectTextPages(imageData, rule)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return results, err
+}
+
+// canceled returns the error of the request context the parameters are bound to,
+// or nil if there is none or it is not done.
+func (p OCRParams) canceled() error {
+	if p.ctx == nil {
+		return nil
+	}
+	return p.ctx.Err()
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrImageTooSmall is returned when an image is below the minimum dimension for preprocessing.
+var ErrImageTooSmall = errors.New("image too small to be processed")
+
+// AngleScore is the recognition score of one rotation angle.
+type AngleScore struct {
+	Angle              int     `json:"angle"`
+	WeightedConfidence float64 `json:"weighted_confidence"`
+	TokenCount         int     `json:"token_count"`
+	// Error holds the failure message if recognition at this angle failed.
+	Error string `json:"error,omitempty"`
+}
+
+// AngleCurve is the confidence-by-angle series of an image, for tuning the phase 2 angle set.
+type AngleCurve struct {
+	// SkewCandidates are the phase 2 candidate angles derived from the image skew.
+	SkewCandidates []int `json:"skew_candidates"`
+	// Scores holds the upright score followed by the score of every candidate, in order.
+	Scores []AngleScore `json:"scores"`
+	// BestAngle is the angle the phase 2 comparison would pick among all scores.
+	BestAngle int `json:"best_angle"`
+}
+
+// AngleCurve recognizes the preprocessed image upright and at every phase 2 candidate
+// angle, without early exit, gating, pruning or pass limits, and returns the score of
+// each angle. It is a tuning aid: the classification pipeline usually tries far fewer
+// angles. Failures at single angles are reported in the scores, not as an error.
+func (c *Classifier) AngleCurve(imageData []byte, rule DecisionRule) (*AngleCurve, error) {
+	rule = c.normalizeDecisionRule(rule)
+	img, err := c.decodeImage(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if rule.ROI != nil {
+		if img, err = cropToROI(img, *rule.ROI); err != nil {
+			return nil, err
+		}
+	}
+
+	img, _ = c.padSmallImage(img)
+
+	preprocessed, scaleFactor, imgWidth, imgHeight := preprocessImage(img, c.preprocessOptions(rule))
+	if preprocessed == nil {
+		return nil, ErrImageTooSmall
+	}
+	data, err := c.encodeIntermediate(preprocessed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preprocessed image: %w", err)
+	}
+
+	curve := &AngleCurve{SkewCandidates: detectSkewAngle(grayImage(preprocessed))}
+	var best *ClassifierResult
+	score := func(angle int, res *ClassifierResult, err error) {
+		s := AngleScore{Angle: angle}
+		if err != nil {
+			s.Error = err.Error()
+		} else {
+			s.WeightedConfidence, s.TokenCount = res.WeightedConfidence, res.TokenCount
+			res.Angle = angle
+			if best == nil || betterRotation(res, best) {
+				best = res
+			}
+		}
+		curve.Scores = append(curve.Scores, s)
+	}
+
+	upright, err := c.detectTextSingle(data, rule.OCRParams)
+	score(0, upright, err)
+	for _, angle := range curve.SkewCandidates {
+		if angle == 0 {
+			continue
+		}
+		res, _, err := c.trySingleRotation(preprocessed, scaleFactor, rule, angle, imgWidth, imgHeight)
+		score(angle, res, err)
+	}
+	if best != nil {
+		curve.BestAngle = best.Angle
+	}
+	return curve, nil
+}
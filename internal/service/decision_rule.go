@@ -1,5 +1,7 @@
 package service
 
+import "image"
+
 // DecisionRule holds the criteria for determining if a document is a text document.
 type DecisionRule struct {
 	MinConfidence float64 // Minimum weighted confidence (0-1)
@@ -7,6 +9,17 @@ type DecisionRule struct {
 	// OCRParams holds OCR-specific parameters (optional).
 	// If empty defaults will be used: Language="eng+rus", Level=RIL_WORD
 	OCRParams
+	// ROI is an optional region of interest (relative to the image origin) to crop
+	// the image to before preprocessing. Boxes are reported in full-image coordinates.
+	ROI *image.Rectangle
+	// Preprocess optionally overrides the classifier's preprocessing parameters.
+	Preprocess *PreprocessOptions
+	// SkipRotation disables phase 2 for the request (see Options.SkipRotation).
+	SkipRotation bool
+	// Angles optionally replaces the phase 2 candidate angles (see Options.Angles).
+	Angles []int
+	// Timing, if set, records pipeline stage durations, which are returned in the result.
+	Timing *Timing
 }
 
 // GetDefaultDecisionRule returns the default decision criteria.
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"image"
+	"sort"
+)
+
+// dedupBoxes performs non-maximum suppression on raw OCR boxes.
+// Boxes are visited in descending confidence order; a box is dropped when its
+// intersection-over-union with an already kept box exceeds iouThreshold.
+// The relative order of the kept boxes is preserved.
+func dedupBoxes(boxes []RecognizedBox, iouThreshold float64) []RecognizedBox {
+	if iouThreshold <= 0 || len(boxes) < 2 {
+		return boxes
+	}
+
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return boxes[order[i]].Confidence > boxes[order[j]].Confidence
+	})
+
+	keep := make([]bool, len(boxes))
+	var kept []int
+	for _, idx := range order {
+		suppressed := false
+		for _, k := range kept {
+			if boxIoU(boxes[idx].Box, boxes[k].Box) > iouThreshold {
+				suppressed = true
+				break
+			}
+		}
+		if !suppressed {
+			keep[idx] = true
+			kept = append(kept, idx)
+		}
+	}
+
+	result := make([]RecognizedBox, 0, len(kept))
+	for i, box := range boxes {
+		if keep[i] {
+			result = append(result, box)
+		}
+	}
+	return result
+}
+
+// boxIoU returns the intersection-over-union ratio of two rectangles.
+func boxIoU(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+	interArea := float64(inter.Dx() * inter.Dy())
+	unionArea := float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - interArea
+	if unionArea <= 0 {
+		return 0
+	}
+	return interArea / unionArea
+}
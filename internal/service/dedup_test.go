@@ -0,0 +1,92 @@
+package service
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDedupBoxes(t *testing.T) {
+	box := func(text string, r image.Rectangle, confidence float64) RecognizedBox {
+		return RecognizedBox{Box: r, Word: text, Confidence: confidence}
+	}
+	word := box("invoice", image.Rect(10, 10, 80, 30), 90)
+	nested := box("inv", image.Rect(12, 10, 78, 30), 60)
+	shifted := box("invoice", image.Rect(40, 10, 110, 30), 95)
+	other := box("total", image.Rect(10, 50, 60, 70), 80)
+
+	tests := []struct {
+		name      string
+		boxes     []RecognizedBox
+		threshold float64
+		want      []string
+	}{
+		{name: "disabled", boxes: []RecognizedBox{word, nested, other}, threshold: 0, want: []string{"invoice", "inv", "total"}},
+		{name: "nested box dropped", boxes: []RecognizedBox{word, nested, other}, threshold: 0.5, want: []string{"invoice", "total"}},
+		{name: "higher confidence kept", boxes: []RecognizedBox{nested, word}, threshold: 0.5, want: []string{"invoice"}},
+		{name: "overlap below threshold kept", boxes: []RecognizedBox{word, shifted}, threshold: 0.5, want: []string{"invoice", "invoice"}},
+		{name: "overlap above low threshold dropped", boxes: []RecognizedBox{word, shifted}, threshold: 0.2, want: []string{"invoice"}},
+		{name: "disjoint boxes kept", boxes: []RecognizedBox{word, other}, threshold: 0.1, want: []string{"invoice", "total"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dedupBoxes(tt.boxes, tt.threshold)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d boxes, want %d", len(got), len(tt.want))
+			}
+			for i, b := range got {
+				if b.Word != tt.want[i] {
+					t.Errorf("box %d = %q, want %q", i, b.Word, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBoxIoU(t *testing.T) {
+	a := image.Rect(0, 0, 10, 10)
+	tests := []struct {
+		b    image.Rectangle
+		want float64
+	}{
+		{b: a, want: 1},
+		{b: image.Rect(5, 0, 15, 10), want: 50.0 / 150},
+		{b: image.Rect(10, 0, 20, 10), want: 0},
+		{b: image.Rect(2, 2, 8, 8), want: 36.0 / 100},
+	}
+	for _, tt := range tests {
+		if got := boxIoU(a, tt.b); got != tt.want {
+			t.Errorf("boxIoU(%v, %v) = %v, want %v", a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDetectTextSingleDedupTokens(t *testing.T) {
+	// Tesseract reports the word and a nested fragment of it
+	engine := staticEngine(
+		RecognizedBox{Box: image.Rect(10, 10, 80, 30), Word: "invoice", Confidence: 90},
+		RecognizedBox{Box: image.Rect(12, 11, 79, 30), Word: "invoice", Confidence: 40},
+		RecognizedBox{Box: image.Rect(10, 50, 60, 70), Word: "total", Confidence: 90},
+	)
+	imageData := encodePNG(t, textImage(120, 80))
+
+	// Tokens are counted per character: "invoice" has 7, "total" 5
+	for _, tt := range []struct {
+		iou        float64
+		wantTokens int
+	}{
+		{iou: 0, wantTokens: 19},
+		{iou: 0.5, wantTokens: 12},
+	} {
+		c := newTestClassifier(Options{DedupIoU: tt.iou}, engine)
+		result, err := c.detectTextSingle(imageData, OCRParams{})
+		if err != nil {
+			t.Fatalf("detectTextSingle failed: %v", err)
+		}
+		if result.TokenCount != tt.wantTokens {
+			t.Errorf("DedupIoU %v: TokenCount = %d, want %d", tt.iou, result.TokenCount, tt.wantTokens)
+		}
+		if tt.iou > 0 && result.WeightedConfidence < 0.89 {
+			t.Errorf("DedupIoU %v: WeightedConfidence = %v, want the low-confidence duplicate dropped", tt.iou, result.WeightedConfidence)
+		}
+	}
+}
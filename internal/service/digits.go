@@ -0,0 +1,94 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ExtractDigits is the extract parameter value requesting numeric candidates.
+const ExtractDigits = "digits"
+
+// numberGapRatio is the maximum horizontal gap between two digit boxes, relative to
+// the taller box height, for them to be joined into one number.
+const numberGapRatio = 1.0
+
+// NumberCandidate is a number assembled from spatially adjacent digit boxes.
+type NumberCandidate struct {
+	Value      string      `json:"value"`
+	Confidence float64     `json:"confidence"`
+	Box        BoundingBox `json:"box"`
+}
+
+// ExtractNumbers returns the numbers found in boxes, top-to-bottom and left-to-right.
+// Letters are ignored; dots and commas are kept only between digits (as in countTokens).
+// Digit boxes on the same line separated by at most numberGapRatio of their height are
+// joined into one number, e.g. a meter reading split into single-digit boxes.
+// The number confidence is the mean of its box confidences weighted by digit count.
+func ExtractNumbers(boxes []BoundingBox) []NumberCandidate {
+	var digitBoxes []BoundingBox
+	for _, box := range boxes {
+		if digits := digitsOnly(box.Word); digits != "" {
+			box.Word = digits
+			digitBoxes = append(digitBoxes, box)
+		}
+	}
+	if len(digitBoxes) == 0 {
+		return nil
+	}
+
+	lines, lineBoxes := groupLineBoxes(digitBoxes)
+	order := make([]int, len(lines))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return lineBoxes[order[i]].Y < lineBoxes[order[j]].Y
+	})
+
+	var numbers []NumberCandidate
+	for _, i := range order {
+		var weight float64
+		for j, box := range lines[i] {
+			boxWeight := float64(countTokens(box.Word))
+			if j > 0 && adjacentDigits(lines[i][j-1], box) {
+				num := &numbers[len(numbers)-1]
+				num.Value += box.Word
+				num.Confidence = (num.Confidence*weight + box.Confidence*boxWeight) / (weight + boxWeight)
+				num.Box = unionBox(num.Box, box)
+				weight += boxWeight
+				continue
+			}
+			numbers = append(numbers, NumberCandidate{Value: box.Word, Confidence: box.Confidence, Box: unionBox(box, box)})
+			weight = boxWeight
+		}
+	}
+	for i := range numbers {
+		numbers[i].Box.Word = numbers[i].Value
+		numbers[i].Box.Confidence = numbers[i].Confidence
+	}
+	return numbers
+}
+
+// digitsOnly keeps the digits of s, plus dots and commas between digits.
+func digitsOnly(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '.' || r == ',':
+			if i > 0 && i < len(runes)-1 && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1]) {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// adjacentDigits reports whether next, on the same line, directly follows prev.
+func adjacentDigits(prev, next BoundingBox) bool {
+	gap := next.X - (prev.X + prev.Width)
+	return gap >= -prev.Width/2 && float64(gap) <= numberGapRatio*float64(max(prev.Height, next.Height))
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// DPI sources, reported in DPIInfo.Source.
+const (
+	// DPISourceMetadata means the input DPI was read from the image file (JFIF, EXIF or PNG pHYs).
+	DPISourceMetadata = "metadata"
+	// DPISourceHint means the input DPI is the configured hint.
+	DPISourceHint = "hint"
+)
+
+// DPIInfo relates the coordinate space of the result to the resolution of the input,
+// so callers can map boxes onto their own renderings: a box coordinate divided by the
+// scale of its axis is in input pixels, and input pixels divided by the input DPI are inches.
+type DPIInfo struct {
+	// InputDPIX and InputDPIY are the assumed resolution of the input image, 0 if unknown.
+	InputDPIX float64 `json:"input_dpi_x"`
+	InputDPIY float64 `json:"input_dpi_y"`
+	// Source is DPISourceMetadata or DPISourceHint, empty if the input DPI is unknown.
+	Source string `json:"source,omitempty"`
+	// ScaleX and ScaleY are the factors from input pixels to result coordinates.
+	ScaleX float64 `json:"scale_x"`
+	ScaleY float64 `json:"scale_y"`
+	// EffectiveDPIX and EffectiveDPIY are the resolution of the image recognized by OCR,
+	// 0 if the input DPI is unknown.
+	EffectiveDPIX float64 `json:"effective_dpi_x"`
+	EffectiveDPIY float64 `json:"effective_dpi_y"`
+}
+
+// dpiInfo builds the DPI metadata of result: the input DPI is read from the image
+// metadata, falling back to the DPIHint option.
+func (c *Classifier) dpiInfo(imageData []byte, result *ClassifierResult) *DPIInfo {
+	info := &DPIInfo{}
+	info.ScaleX, info.ScaleY = result.axisScales()
+	if x, y, ok := readDPI(imageData); ok {
+		info.InputDPIX, info.InputDPIY, info.Source = x, y, DPISourceMetadata
+	} else if c.opts.DPIHint > 0 {
+		hint := float64(c.opts.DPIHint)
+		info.InputDPIX, info.InputDPIY, info.Source = hint, hint, DPISourceHint
+	}
+	info.EffectiveDPIX = math.Round(info.InputDPIX*info.ScaleX*100) / 100
+	info.EffectiveDPIY = math.Round(info.InputDPIY*info.ScaleY*100) / 100
+	return info
+}
+
+// readDPI returns the resolution stored in JPEG (JFIF or EXIF) or PNG (pHYs) metadata.
+// ok is false for other formats and when no absolute resolution is stored.
+func readDPI(data []byte) (x, y float64, ok bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return readPNGDPI(data)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return readJPEGDPI(data)
+	}
+	return 0, 0, false
+}
+
+// readPNGDPI reads the pHYs chunk, which precedes the image data.
+func readPNGDPI(data []byte) (x, y float64, ok bool) {
+	const inchesPerMeter = 39.3701
+	for pos := 8; pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		body := pos + 8
+		if length < 0 || body+length > len(data) || typ == "IDAT" {
+			break
+		}
+		// pHYs: pixels per unit X and Y (uint32), unit (1 = meter, 0 = aspect ratio only)
+		if typ == "pHYs" && length == 9 && data[body+8] == 1 {
+			ppmX := float64(binary.BigEndian.Uint32(data[body:]))
+			ppmY := float64(binary.BigEndian.Uint32(data[body+4:]))
+			if ppmX > 0 && ppmY > 0 {
+				return math.Round(ppmX / inchesPerMeter), math.Round(ppmY / inchesPerMeter), true
+			}
+		}
+		pos = body + length + 4 // skip the CRC
+	}
+	return 0, 0, false
+}
+
+// readJPEGDPI reads the density of the JFIF APP0 segment, or else the EXIF resolution
+// of the APP1 segment, scanning the segments up to the start of the scan.
+func readJPEGDPI(data []byte) (x, y float64, ok bool) {
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF; {
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan, end of image
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos+4 : pos+2+length]
+		switch {
+		case marker == 0xE0 && len(segment) >= 12 && bytes.HasPrefix(segment, []byte("JFIF\x00")):
+			// Units (0 = aspect ratio only, 1 = dots per inch, 2 = dots per cm), then X and Y density
+			x = float64(binary.BigEndian.Uint16(segment[8:]))
+			y = float64(binary.BigEndian.Uint16(segment[10:]))
+			if x > 0 && y > 0 {
+				switch segment[7] {
+				case 1:
+					return x, y, true
+				case 2:
+					return math.Round(x * 2.54), math.Round(y * 2.54), true
+				}
+			}
+		case marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			if x, y, ok = readEXIFDPI(segment[6:]); ok {
+				return x, y, true
+			}
+		}
+		pos += 2 + length
+	}
+	return 0, 0, false
+}
+
+// readEXIFDPI reads XResolution, YResolution and ResolutionUnit from IFD0 of a TIFF
+// structure (the EXIF payload).
+func readEXIFDPI(tiff []byte) (x, y float64, ok bool) {
+	if len(tiff) < 8 {
+		return 0, 0, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, 0, false
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0, 0, false
+	}
+
+	rational := func(offset int) float64 {
+		if offset < 0 || offset+8 > len(tiff) {
+			return 0
+		}
+		num, den := order.Uint32(tiff[offset:]), order.Uint32(tiff[offset+4:])
+		if den == 0 {
+			return 0
+		}
+		return float64(num) / float64(den)
+	}
+	unit := uint16(2) // inches, the TIFF default
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		switch order.Uint16(tiff[entry:]) {
+		case 0x011A: // XResolution
+			x = rational(int(order.Uint32(tiff[entry+8:])))
+		case 0x011B: // YResolution
+			y = rational(int(order.Uint32(tiff[entry+8:])))
+		case 0x0128: // ResolutionUnit (1 = none, 2 = inch, 3 = cm)
+			unit = order.Uint16(tiff[entry+8:])
+		}
+	}
+	if x <= 0 || y <= 0 {
+		return 0, 0, false
+	}
+	switch unit {
+	case 2:
+		return math.Round(x), math.Round(y), true
+	case 3:
+		return math.Round(x * 2.54), math.Round(y * 2.54), true
+	}
+	return 0, 0, false
+}
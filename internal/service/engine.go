@@ -0,0 +1,199 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"github.com/otiai10/gosseract/v2"
+)
+
+// DefaultEngine is the name of the default OCR engine.
+const DefaultEngine = "tesseract"
+
+// RecognizedBox is a raw text region reported by an OCREngine.
+type RecognizedBox struct {
+	Box  image.Rectangle
+	Word string
+	// Confidence is the recognition confidence on the 0-100 scale.
+	Confidence float64
+	// Block, Paragraph and Line are the 1-based position of the word in the page layout,
+	// reported only when OCRParams.Layout is set and the engine supports it (0 otherwise).
+	Block, Paragraph, Line int
+}
+
+// OCREngine is an OCR backend that recognizes text regions in an image.
+type OCREngine interface {
+	// Recognize returns the text regions found in the encoded image.
+	Recognize(imageData []byte, params OCRParams) ([]RecognizedBox, error)
+}
+
+// ErrPermanent marks OCR engine errors that retrying cannot fix, such as missing
+// language data. Engines wrap such errors with it; all other errors are treated as
+// transient and retried (see Options.EngineRetries).
+var ErrPermanent = errors.New("permanent OCR engine failure")
+
+// ocrEngines maps engine names to their constructors.
+var ocrEngines = map[string]func() OCREngine{
+	DefaultEngine: func() OCREngine { return TesseractEngine{pool: newClientPool()} },
+}
+
+// RegisterOCREngine makes an OCR engine available under the given name.
+// It is not safe for concurrent use and is intended to be called from init functions.
+func RegisterOCREngine(name string, factory func() OCREngine) {
+	ocrEngines[name] = factory
+}
+
+// ValidateOCREngine checks that an OCR engine is registered under name.
+func ValidateOCREngine(name string) error {
+	if _, ok := ocrEngines[name]; !ok {
+		return fmt.Errorf("unsupported OCR engine %q, supported: %s", name, strings.Join(ocrEngineNames(), ", "))
+	}
+	return nil
+}
+
+// newOCREngine creates the engine registered under name, or the default engine if name is unknown.
+func newOCREngine(name string) OCREngine {
+	if factory, ok := ocrEngines[name]; ok {
+		return factory()
+	}
+	return ocrEngines[DefaultEngine]()
+}
+
+// ocrEngineNames returns the sorted names of registered OCR engines.
+func ocrEngineNames() []string {
+	names := make([]string, 0, len(ocrEngines))
+	for name := range ocrEngines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TesseractEngine is the default OCREngine backed by gosseract. The engine created for
+// a Classifier reuses initialized clients across recognitions until Close; the zero
+// value creates a client per recognition.
+type TesseractEngine struct {
+	pool *clientPool
+}
+
+// Close closes the idle pooled clients. Recognitions still running close their clients
+// when they finish.
+func (e TesseractEngine) Close() error {
+	if e.pool != nil {
+		e.pool.close()
+	}
+	return nil
+}
+
+// AvailableLanguages lists the languages with traineddata in the Tesseract data directory.
+func (TesseractEngine) AvailableLanguages() ([]string, error) {
+	return gosseract.GetAvailableLanguages()
+}
+
+// Recognize runs Tesseract on the image using the specified language and level.
+func (e TesseractEngine) Recognize(imageData []byte, params OCRParams) ([]RecognizedBox, error) {
+	key := clientKey{language: params.Language}
+	if key.language == "" {
+		key.language = DefaultLanguage
+	}
+	if params.OEM != "" && params.OEM != OEMDefault {
+		path, err := oemConfigFile(params.OEM)
+		if err != nil {
+			return nil, err
+		}
+		key.configFile = path
+	}
+	if params.PSM != "" {
+		if _, ok := psmValues[params.PSM]; !ok {
+			return nil, fmt.Errorf("unsupported page segmentation mode %q: %w", params.PSM, ErrPermanent)
+		}
+		key.psm = params.PSM
+	}
+
+	client, err := e.client(key)
+	if err != nil {
+		return nil, err
+	}
+	boxes, err := recognizeWithClient(client, imageData, params)
+	if err != nil || e.pool == nil {
+		// A failed client may be left half-initialized: do not reuse it
+		client.Close()
+	} else {
+		e.pool.put(key, client)
+	}
+	return boxes, err
+}
+
+// client returns a pooled client for key, or a new client configured with its language,
+// config file and page segmentation mode; Tesseract initializes it on the first recognition.
+func (e TesseractEngine) client(key clientKey) (*gosseract.Client, error) {
+	if e.pool != nil {
+		if client := e.pool.get(key); client != nil {
+			return client, nil
+		}
+	}
+
+	client := gosseract.NewClient()
+	if err := client.SetLanguage(key.language); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to set language: %w: %w", ErrPermanent, err)
+	}
+	if key.configFile != "" {
+		if err := client.SetConfigFile(key.configFile); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to set engine mode: %w", err)
+		}
+	}
+	if key.psm != "" {
+		if err := client.SetPageSegMode(psmValues[key.psm]); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to set page segmentation mode: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// recognizeWithClient recognizes imageData with a configured client. Setting the image
+// replaces the one of the previous recognition; the initialized language data is kept.
+func recognizeWithClient(client *gosseract.Client, imageData []byte, params OCRParams) ([]RecognizedBox, error) {
+	if err := client.SetImageFromBytes(imageData); err != nil {
+		return nil, fmt.Errorf("failed to set image: %w", err)
+	}
+
+	level := params.Level
+	if level == nil {
+		defaultLevel := DefaultPageIteratorLevel
+		level = &defaultLevel
+	}
+
+	var boxes []gosseract.BoundingBox
+	var err error
+	if params.Layout && *level == gosseract.RIL_WORD {
+		boxes, err = client.GetBoundingBoxesVerbose()
+	} else {
+		boxes, err = client.GetBoundingBoxes(*level)
+	}
+	if err != nil {
+		// Tesseract initialization fails when the language data is not installed
+		if strings.Contains(err.Error(), "failed to initialize TessBaseAPI") {
+			return nil, fmt.Errorf("failed to get bounding boxes: %w: %w", ErrPermanent, err)
+		}
+		return nil, fmt.Errorf("failed to get bounding boxes: %w", err)
+	}
+
+	result := make([]RecognizedBox, len(boxes))
+	for i, box := range boxes {
+		result[i] = RecognizedBox{
+			Box:        box.Box,
+			Word:       box.Word,
+			Confidence: box.Confidence,
+			Block:      box.BlockNum,
+			Paragraph:  box.ParNum,
+			Line:       box.LineNum,
+		}
+	}
+	return result, nil
+}
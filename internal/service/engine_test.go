@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sync/atomic"
+	"testing"
+)
+
+// engineFunc is an OCREngine backed by a function, so tests run without Tesseract.
+type engineFunc func(imageData []byte, params OCRParams) ([]RecognizedBox, error)
+
+func (f engineFunc) Recognize(imageData []byte, params OCRParams) ([]RecognizedBox, error) {
+	return f(imageData, params)
+}
+
+// staticEngine returns an engine that recognizes boxes in every image.
+func staticEngine(boxes ...RecognizedBox) engineFunc {
+	return func([]byte, OCRParams) ([]RecognizedBox, error) {
+		return append([]RecognizedBox(nil), boxes...), nil
+	}
+}
+
+// newTestClassifier creates a classifier with opts that recognizes with engine.
+func newTestClassifier(opts Options, engine OCREngine) *Classifier {
+	c := NewClassifierWithOptions(opts)
+	c.engine = engine
+	return c
+}
+
+// word returns a recognized word box at x, y with the given confidence (0-100).
+func word(text string, x, y int, confidence float64) RecognizedBox {
+	return RecognizedBox{Box: image.Rect(x, y, x+10*len(text), y+20), Word: text, Confidence: confidence}
+}
+
+// textImage returns a white w x h image with dark horizontal bars standing in for text lines.
+func textImage(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for y := h / 8; y+h/16 < h*7/8; y += h / 6 {
+		for yy := y; yy < y+h/16; yy++ {
+			for x := w / 10; x < w*9/10; x++ {
+				img.SetGray(x, yy, color.Gray{Y: 20})
+			}
+		}
+	}
+	return img
+}
+
+// encodePNG encodes img as PNG.
+func encodePNG(t testing.TB, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRecognizeRetries(t *testing.T) {
+	errTransient := errors.New("failed to set image")
+	errMissing := fmt.Errorf("failed to set language: %w", ErrPermanent)
+
+	tests := []struct {
+		name      string
+		retries   int
+		failures  []error
+		wantCalls int32
+		wantErr   error
+	}{
+		{name: "no failure", retries: 1, wantCalls: 1},
+		{name: "transient failure retried", retries: 1, failures: []error{errTransient}, wantCalls: 2},
+		{name: "retries exhausted", retries: 1, failures: []error{errTransient, errTransient}, wantCalls: 2, wantErr: errTransient},
+		{name: "retries disabled", retries: 0, failures: []error{errTransient}, wantCalls: 1, wantErr: errTransient},
+		{name: "permanent failure not retried", retries: 3, failures: []error{errMissing}, wantCalls: 1, wantErr: ErrPermanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			engine := engineFunc(func([]byte, OCRParams) ([]RecognizedBox, error) {
+				n := int(calls.Add(1))
+				if n <= len(tt.failures) {
+					return nil, tt.failures[n-1]
+				}
+				return []RecognizedBox{word("text", 0, 0, 90)}, nil
+			})
+			c := newTestClassifier(Options{EngineRetries: tt.retries}, engine)
+
+			boxes, err := c.recognize(nil, OCRParams{})
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("engine called %d times, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(boxes) != 1 {
+				t.Errorf("got %d boxes, want 1", len(boxes))
+			}
+		})
+	}
+}
+
+func TestDetectTextRetriesTransientFailure(t *testing.T) {
+	var calls atomic.Int32
+	engine := engineFunc(func([]byte, OCRParams) ([]RecognizedBox, error) {
+		if calls.Add(1) == 1 {
+			return nil, errors.New("failed to get bounding boxes")
+		}
+		return []RecognizedBox{word("document", 10, 10, 95), word("text", 10, 40, 95)}, nil
+	})
+	c := newTestClassifier(Options{EngineRetries: 1, SkipRotation: true}, engine)
+
+	result, err := c.DetectText(encodePNG(t, textImage(160, 120)), c.DefaultDecisionRule())
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	if result.TokenCount == 0 {
+		t.Errorf("TokenCount = 0, want the words of the retried pass")
+	}
+}
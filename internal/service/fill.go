@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Fill colors of the corners uncovered by rotating an image by a non-cardinal angle,
+// selected with Options.RotationFill.
+const (
+	// RotationFillWhite fills the corners with white, matching light documents.
+	RotationFillWhite = "white"
+	// RotationFillBorder fills the corners with the median color of the image border,
+	// so that dark or colored backgrounds are not broken by white triangles.
+	RotationFillBorder = "border"
+)
+
+// ValidateRotationFill checks that fill is a supported rotation fill. Empty means
+// RotationFillWhite.
+func ValidateRotationFill(fill string) error {
+	switch fill {
+	case "", RotationFillWhite, RotationFillBorder:
+		return nil
+	}
+	return fmt.Errorf("unsupported rotation fill %q, supported: %s, %s", fill, RotationFillWhite, RotationFillBorder)
+}
+
+// rotationFillColor returns the color filling the corners of img rotated with fill.
+func rotationFillColor(img image.Image, fill string) color.Color {
+	if fill == RotationFillBorder {
+		return borderMedianColor(img)
+	}
+	return color.White
+}
+
+// borderMedianColor returns the per-channel median color of the outermost pixels of img,
+// or white if img is empty.
+func borderMedianColor(img image.Image) color.Color {
+	if img.Bounds().Empty() {
+		return color.White
+	}
+
+	var hist [3][256]int
+	n := 0
+	forEachBorderPixel(img, func(c color.NRGBA) {
+		hist[0][c.R]++
+		hist[1][c.G]++
+		hist[2][c.B]++
+		n++
+	})
+
+	var median [3]uint8
+	for ch := range hist {
+		count := 0
+		for v, c := range hist[ch] {
+			if count += c; 2*count >= n {
+				median[ch] = uint8(v)
+				break
+			}
+		}
+	}
+	return color.NRGBA{R: median[0], G: median[1], B: median[2], A: 255}
+}
@@ -0,0 +1,48 @@
+package service
+
+import "image"
+
+// flipDominanceRatio is the minimum token count ratio between the upright and
+// the upside-down pass required to consider one of them a clear winner.
+const flipDominanceRatio = 2.0
+
+// flipMinTokens is the token count both passes need for their ratio to be compared:
+// against a pass with fewer, a few noise tokens would dominate.
+const flipMinTokens = 3
+
+// disambiguateFlip runs a single OCR pass at 180 degrees and compares its token count
+// with the phase 1 (upright) result. When one orientation clearly wins, it returns
+// that result and true, so the full rotation sweep can be skipped: the winner needs at
+// least the rule's MinTokenCount tokens and flipDominanceRatio times the tokens of the
+// other pass, both having at least flipMinTokens, and an upside-down winner must exceed
+// RotationMargin. A 180 degree pass that qualifies as text wins outright.
+// When the comparison is inconclusive, it returns the better of the two passes and false.
+func (c *Classifier) disambiguateFlip(preprocessed image.Image, scaleFactor float64, upright *ClassifierResult, rule DecisionRule, imgWidth, imgHeight int) (*ClassifierResult, bool) {
+	flipped, isText, _ := c.trySingleRotation(preprocessed, scaleFactor, rule, 180, imgWidth, imgHeight)
+	if flipped == nil {
+		return upright, false
+	}
+	if isText {
+		return flipped, true
+	}
+
+	rule = c.normalizeDecisionRule(rule)
+	if upright.TokenCount >= flipMinTokens && flipped.TokenCount >= flipMinTokens {
+		uprightTokens := float64(upright.TokenCount)
+		flippedTokens := float64(flipped.TokenCount)
+		switch {
+		case flipped.TokenCount >= rule.MinTokenCount && flippedTokens >= uprightTokens*flipDominanceRatio &&
+			c.exceedsRotationMargin(flipped, upright):
+			flipped.IsTextDocument = EvaluateDecision(flipped.WeightedConfidence, flipped.TokenCount, rule)
+			return flipped, true
+		case upright.TokenCount >= rule.MinTokenCount && uprightTokens >= flippedTokens*flipDominanceRatio:
+			upright.IsTextDocument = EvaluateDecision(upright.WeightedConfidence, upright.TokenCount, rule)
+			return upright, true
+		}
+	}
+
+	if c.exceedsRotationMargin(flipped, upright) && flipped.WeightedConfidence > upright.WeightedConfidence {
+		return flipped, false
+	}
+	return upright, false
+}
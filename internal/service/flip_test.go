@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDisambiguateFlip(t *testing.T) {
+	// words returns n five-character words, n*5 tokens, recognized with confidence
+	words := func(n int, confidence float64) []RecognizedBox {
+		boxes := make([]RecognizedBox, n)
+		for i := range boxes {
+			boxes[i] = word("words", 2, 2+i*8, confidence)
+		}
+		return boxes
+	}
+
+	tests := []struct {
+		name            string
+		margin          float64
+		upright         []RecognizedBox
+		flipped         []RecognizedBox
+		wantAngle       int
+		wantSweepPasses bool
+	}{
+		{
+			name:    "one noise token upside down",
+			flipped: []RecognizedBox{word("x", 2, 2, 40)},
+			// The page is rotated by 90 degrees, which only the sweep finds
+			wantAngle: 90, wantSweepPasses: true,
+		},
+		{
+			name:    "clear upside-down winner",
+			upright: []RecognizedBox{word("abc", 2, 2, 40)}, flipped: words(5, 50),
+			wantAngle: 180,
+		},
+		{
+			name:    "clear upright winner",
+			upright: words(5, 50), flipped: []RecognizedBox{word("abc", 2, 2, 40)},
+			wantAngle: 0,
+		},
+		{
+			name:    "upside-down winner within margin",
+			margin:  0.2,
+			upright: []RecognizedBox{word("abc", 2, 2, 40)}, flipped: words(5, 50),
+			wantAngle: 90, wantSweepPasses: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Landscape passes are the upright one, then the upside-down one; only
+			// portrait images, at 90 and 270 degrees, hold text
+			var landscape, portrait atomic.Int32
+			engine := engineFunc(func(imageData []byte, _ OCRParams) ([]RecognizedBox, error) {
+				cfg, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+				if err != nil {
+					return nil, err
+				}
+				if cfg.Height > cfg.Width {
+					portrait.Add(1)
+					return words(5, 95), nil
+				}
+				if landscape.Add(1) == 1 {
+					return tt.upright, nil
+				}
+				return tt.flipped, nil
+			})
+			c := newTestClassifier(Options{FlipCheck: true, RotationMargin: tt.margin, Angles: []int{90, 180, 270}}, engine)
+
+			result, err := c.DetectText(encodePNG(t, textImage(60, 48)), c.DefaultDecisionRule())
+			if err != nil {
+				t.Fatalf("DetectText failed: %v", err)
+			}
+			if result.Angle != tt.wantAngle {
+				t.Errorf("angle = %d, want %d", result.Angle, tt.wantAngle)
+			}
+			if swept := portrait.Load() > 0; swept != tt.wantSweepPasses {
+				t.Errorf("sweep ran: %v, want %v", swept, tt.wantSweepPasses)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"sort"
+)
+
+// imageFormats maps accepted request content types to the image.Decode format names
+// that handle them. It is the single source of truth for input validation.
+var imageFormats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+}
+
+// frameCounters maps image.Decode format names to functions counting the frames (pages,
+// animation frames, top-level images) in data of that format. Formats without a counter
+// are single-frame.
+var frameCounters = map[string]func(data []byte) (int, error){}
+
+// firstFrameDecoders maps image.Decode format names to functions decoding the first frame
+// of data the registered image decoder rejects, such as animations.
+var firstFrameDecoders = map[string]func(data []byte) (image.Image, error){}
+
+// ErrMultiFrame is returned for multi-frame images when they are rejected.
+var ErrMultiFrame = errors.New("multi-frame images are not supported")
+
+// RegisterImageFormat adds a content type handled by the given image.Decode format.
+// The decoder itself must be registered with the image package (usually via a blank import).
+// It is not safe for concurrent use and is intended to be called from init functions.
+func RegisterImageFormat(contentType, format string) {
+	imageFormats[contentType] = format
+}
+
+// RegisterFrameCounter sets the function counting frames in images of the given
+// image.Decode format. Like RegisterImageFormat, it is intended for init functions.
+func RegisterFrameCounter(format string, count func(data []byte) (int, error)) {
+	frameCounters[format] = count
+}
+
+// RegisterFirstFrameDecoder sets the function decoding the first frame of multi-frame
+// images of the given image.Decode format that its image decoder cannot handle.
+// Like RegisterImageFormat, it is intended for init functions.
+func RegisterFirstFrameDecoder(format string, decode func(data []byte) (image.Image, error)) {
+	firstFrameDecoders[format] = decode
+}
+
+// decodeImageData decodes imageData with the registered image decoders and returns
+// the format name. If the decoder fails, the first-frame decoder of the format, if any,
+// is tried; the error of the image decoder is returned if there is none.
+func decodeImageData(imageData []byte) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(imageData))
+	if err == nil {
+		return img, format, nil
+	}
+	_, format, configErr := image.DecodeConfig(bytes.NewReader(imageData))
+	if configErr != nil {
+		return nil, "", err
+	}
+	decode, ok := firstFrameDecoders[format]
+	if !ok {
+		return nil, "", err
+	}
+	if img, err = decode(imageData); err != nil {
+		return nil, "", fmt.Errorf("failed to decode first %s frame: %w", format, err)
+	}
+	return img, format, nil
+}
+
+// CheckSingleFrame returns ErrMultiFrame if imageData holds more than one frame.
+// Data that cannot be decoded, or whose format has no frame counter, passes the check.
+func CheckSingleFrame(imageData []byte) error {
+	_, format, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil
+	}
+	count, ok := frameCounters[format]
+	if !ok {
+		return nil
+	}
+	frames, err := count(imageData)
+	if err != nil {
+		return fmt.Errorf("failed to count %s frames: %w", format, err)
+	}
+	if frames > 1 {
+		return fmt.Errorf("%w: %s image has %d frames", ErrMultiFrame, format, frames)
+	}
+	return nil
+}
+
+// IsSupportedContentType reports whether contentType has a registered decoder.
+func IsSupportedContentType(contentType string) bool {
+	_, ok := imageFormats[contentType]
+	return ok
+}
+
+// SupportedContentTypes returns the sorted list of accepted content types.
+func SupportedContentTypes() []string {
+	types := make([]string, 0, len(imageFormats))
+	for contentType := range imageFormats {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// SniffContentType determines the content type of imageData from its bytes, ignoring any
+// declared type. It tries http.DetectContentType first and falls back to the registered
+// image decoders for formats it does not know (e.g. HEIC). Returns "" if the data is not
+// in a supported format.
+func SniffContentType(imageData []byte) string {
+	if contentType := http.DetectContentType(imageData); IsSupportedContentType(contentType) {
+		return contentType
+	}
+	_, format, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return ""
+	}
+	for _, contentType := range SupportedContentTypes() {
+		if imageFormats[contentType] == format {
+			return contentType
+		}
+	}
+	return ""
+}
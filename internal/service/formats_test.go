@@ -0,0 +1,105 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+// palettedImage returns src drawn on a paletted image, as GIF frames are. The
+// palette holds every gray level, so gray images are encoded without loss.
+func palettedImage(src image.Image) *image.Paletted {
+	grays := make(color.Palette, 256)
+	for i := range grays {
+		grays[i] = color.Gray{Y: uint8(i)}
+	}
+	img := image.NewPaletted(src.Bounds(), grays)
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+	return img
+}
+
+func TestDetectTextBMPAndGIF(t *testing.T) {
+	page := textImage(64, 48)
+	// The second frame of the animation is solid black, so recognizing it is told apart
+	black := image.NewGray(page.Bounds())
+
+	encodeBMP := func(t *testing.T) []byte {
+		var buf bytes.Buffer
+		if err := bmp.Encode(&buf, page); err != nil {
+			t.Fatalf("failed to encode BMP: %v", err)
+		}
+		return buf.Bytes()
+	}
+	encodeGIF := func(frames ...image.Image) func(t *testing.T) []byte {
+		return func(t *testing.T) []byte {
+			anim := &gif.GIF{}
+			for _, frame := range frames {
+				anim.Image = append(anim.Image, palettedImage(frame))
+				anim.Delay = append(anim.Delay, 10)
+			}
+			var buf bytes.Buffer
+			if err := gif.EncodeAll(&buf, anim); err != nil {
+				t.Fatalf("failed to encode GIF: %v", err)
+			}
+			return buf.Bytes()
+		}
+	}
+
+	tests := []struct {
+		name       string
+		encode     func(t *testing.T) []byte
+		wantType   string
+		wantFrames bool
+	}{
+		{name: "bmp", encode: encodeBMP, wantType: "image/bmp"},
+		{name: "gif", encode: encodeGIF(page), wantType: "image/gif"},
+		{name: "animated gif", encode: encodeGIF(page, black), wantType: "image/gif", wantFrames: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageData := tt.encode(t)
+			if got := SniffContentType(imageData); got != tt.wantType {
+				t.Errorf("content type %q, want %q", got, tt.wantType)
+			}
+			if err := CheckSingleFrame(imageData); errors.Is(err, ErrMultiFrame) != tt.wantFrames {
+				t.Errorf("CheckSingleFrame = %v, want multi-frame %v", err, tt.wantFrames)
+			}
+
+			engine := &recordingEngine{}
+			c := newTestClassifier(Options{SkipRotation: true}, engine)
+			rule := c.DefaultDecisionRule()
+			rule.Preprocess = &PreprocessOptions{Off: true}
+			result, err := c.DetectText(imageData, rule)
+			if err != nil {
+				t.Fatalf("DetectText failed: %v", err)
+			}
+			if result.BoundingBoxWidth != 64 || result.BoundingBoxHeight != 48 {
+				t.Errorf("size %dx%d, want 64x48", result.BoundingBoxWidth, result.BoundingBoxHeight)
+			}
+
+			// Only the first frame is recognized
+			if len(engine.images) != 1 {
+				t.Fatalf("engine called %d times, want once", len(engine.images))
+			}
+			got, err := png.Decode(bytes.NewReader(engine.images[0]))
+			if err != nil {
+				t.Fatalf("failed to decode the recognized image: %v", err)
+			}
+			b := page.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if g, w := color.GrayModel.Convert(got.At(x, y)), page.At(x, y); g != w {
+						t.Fatalf("pixel (%d, %d) = %v, want %v of the first frame", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
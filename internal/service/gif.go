@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	_ "image/gif"
+)
+
+func init() {
+	RegisterImageFormat("image/gif", "gif")
+	RegisterFrameCounter("gif", countGIFFrames)
+}
+
+// errInvalidGIF is returned for GIF data whose block structure is malformed.
+var errInvalidGIF = errors.New("invalid GIF structure")
+
+// GIF block introducers and the color table flag shared by the screen and image descriptors.
+const (
+	gifExtension       = 0x21
+	gifImageDescriptor = 0x2C
+	gifTrailer         = 0x3B
+	gifColorTableFlag  = 0x80
+)
+
+// countGIFFrames returns the number of images in a GIF file by walking its blocks
+// without decoding them. The gif decoder returns the first image, so animations need
+// no first-frame decoder.
+func countGIFFrames(data []byte) (int, error) {
+	// Header (6 bytes) and logical screen descriptor (7 bytes)
+	if len(data) < 13 {
+		return 0, errInvalidGIF
+	}
+	pos := 13 + gifColorTableSize(data[10])
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case gifTrailer:
+			return frames, nil
+		case gifExtension:
+			// Introducer and label, then data sub-blocks
+			pos += 2
+		case gifImageDescriptor:
+			if pos+10 > len(data) {
+				return 0, errInvalidGIF
+			}
+			// Descriptor (10 bytes), local color table, LZW minimum code size, then image data sub-blocks
+			pos += 10 + gifColorTableSize(data[pos+9]) + 1
+			frames++
+		default:
+			return 0, errInvalidGIF
+		}
+		var ok bool
+		if pos, ok = skipGIFSubBlocks(data, pos); !ok {
+			return 0, errInvalidGIF
+		}
+	}
+	// A missing trailer is tolerated, as by the gif decoder
+	if frames == 0 {
+		return 0, errInvalidGIF
+	}
+	return frames, nil
+}
+
+// gifColorTableSize returns the size in bytes of the color table described by the
+// packed fields of a screen or image descriptor.
+func gifColorTableSize(packed byte) int {
+	if packed&gifColorTableFlag == 0 {
+		return 0
+	}
+	return 3 << (packed&0x07 + 1)
+}
+
+// skipGIFSubBlocks returns the position after the data sub-blocks starting at pos,
+// up to and including the zero-length block terminator.
+func skipGIFSubBlocks(data []byte, pos int) (int, bool) {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, true
+		}
+		pos += size
+	}
+	return pos, false
+}
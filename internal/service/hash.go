@@ -0,0 +1,54 @@
+package service
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// DefaultHashAlgorithm is the content hash algorithm used when none is configured.
+const DefaultHashAlgorithm = "sha256"
+
+// hashAlgorithms maps content hash algorithm names to their constructors.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ValidateHashAlgorithm checks that a content hash algorithm is supported under name.
+func ValidateHashAlgorithm(name string) error {
+	if _, ok := hashAlgorithms[name]; !ok {
+		return fmt.Errorf("unsupported hash algorithm %q, supported: %s", name, strings.Join(hashAlgorithmNames(), ", "))
+	}
+	return nil
+}
+
+// hashAlgorithmNames returns the sorted names of supported hash algorithms.
+func hashAlgorithmNames() []string {
+	names := make([]string, 0, len(hashAlgorithms))
+	for name := range hashAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// contentHash returns the hash of data as "algorithm:hex", e.g. "sha256:9f86d0...".
+// Unknown algorithms fall back to DefaultHashAlgorithm.
+func contentHash(data []byte, algorithm string) string {
+	newHash, ok := hashAlgorithms[algorithm]
+	if !ok {
+		algorithm, newHash = DefaultHashAlgorithm, hashAlgorithms[DefaultHashAlgorithm]
+	}
+	h := newHash()
+	h.Write(data)
+	return algorithm + ":" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,27 @@
+//go:build heic
+
+package service
+
+import (
+	"github.com/strukturag/libheif/go/heif"
+)
+
+func init() {
+	RegisterImageFormat("image/heic", "heif")
+	RegisterImageFormat("image/heif", "heif")
+	RegisterFrameCounter("heif", countHEIFImages)
+}
+
+// countHEIFImages returns the number of top-level images (e.g. burst or sequence frames)
+// in a HEIF container. Importing the heif package also registers its decoder with the
+// image package (requires libheif and cgo).
+func countHEIFImages(data []byte) (int, error) {
+	ctx, err := heif.NewContext()
+	if err != nil {
+		return 0, err
+	}
+	if err := ctx.ReadFromMemory(data); err != nil {
+		return 0, err
+	}
+	return ctx.GetNumberOfTopLevelImages(), nil
+}
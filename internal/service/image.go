@@ -24,7 +24,9 @@ const (
 )
 
 // rotateImage rotates an image by the specified angle in degrees using imaging library.
-func rotateImage(img image.Image, angleDeg int) image.Image {
+// The corners uncovered by a non-cardinal angle are filled as selected by fill
+// (RotationFillWhite or RotationFillBorder, empty means white).
+func rotateImage(img image.Image, angleDeg int, fill string) image.Image {
 	// Normalize angle to 0-359
 	angleDeg = ((angleDeg % 360) + 360) % 360
 
@@ -42,12 +44,13 @@ func rotateImage(img image.Image, angleDeg int) image.Image {
 		return imaging.Rotate270(img)
 	}
 
-	// General rotation for arbitrary angles with white background
-	return imaging.Rotate(img, float64(angleDeg), color.White)
+	// General rotation for arbitrary angles
+	return imaging.Rotate(img, float64(angleDeg), rotationFillColor(img, fill))
 }
 
 // encodeImage encodes an image to bytes in the specified format.
-// Supported formats: "png", "jpeg" (default).
+// Supported formats: "png", "png-raw" (uncompressed PNG, much faster to encode
+// and decode), "jpeg" (default).
 func encodeImage(img image.Image, format string) ([]byte, error) {
 	var buf bytes.Buffer
 	var err error
@@ -55,6 +58,9 @@ func encodeImage(img image.Image, format string) ([]byte, error) {
 	switch format {
 	case "png":
 		err = png.Encode(&buf, img)
+	case "png-raw":
+		enc := png.Encoder{CompressionLevel: png.NoCompression}
+		err = enc.Encode(&buf, img)
 	default:
 		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
 	}
@@ -65,60 +71,151 @@ func encodeImage(img image.Image, format string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// preprocessImage applies preprocessing pipeline: scale, grayscale, median blur.
-// Returns (nil, 0, 0, 0) if image is too small to process.
-// Returns (processedImage, scaleFactor, width, height) on success.
-func preprocessImage(img image.Image) (*image.Gray, float64, int, int) {
+// defaultWhiteThreshold is the luminance from which light gray shades are treated as pure white.
+const defaultWhiteThreshold = 224
+
+// PreprocessOptions holds toggles for optional preprocessing stages.
+// The zero value is the default pipeline (see PresetScan).
+type PreprocessOptions struct {
+	// Equalize applies global histogram equalization to the grayscale image before thresholding.
+	Equalize bool
+	// NoMedianBlur skips the median blur stage.
+	NoMedianBlur bool
+	// WhiteThreshold is the luminance from which pixels are treated as pure white.
+	// Zero means defaultWhiteThreshold.
+	WhiteThreshold uint8
+	// BackgroundBand, when non-zero, whitens pixels within this many luminance levels
+	// of the background, suppressing faint strokes such as watermarks.
+	BackgroundBand uint8
+	// Off skips all stages: the image is used as decoded, at scale factor 1.
+	Off bool
+	// Scale, if positive, replaces the scale factor chosen from the image size.
+	Scale float64
+
+	// tiers is the scale curve of the classifier (see Options.ScaleTiers),
+	// nil for DefaultScaleTiers.
+	tiers *ScaleTiers
+}
+
+// preprocessImage applies preprocessing pipeline: scale, grayscale, median blur,
+// plus the optional stages enabled in opts.
+// Returns (nil, 0, 0, 0) if image is too small to process, including degenerate
+// zero-width/height and 1×N images, so no interpolation math runs on them.
+// Returns (processedImage, scaleFactor, width, height) on success; with opts.Off the
+// processed image is img itself, which need not be grayscale.
+func preprocessImage(img image.Image, opts PreprocessOptions) (image.Image, float64, int, int) {
+	preprocessed, scaleFactor, w, h, _ := preprocessImageStages(img, opts, nil)
+	return preprocessed, scaleFactor, w, h
+}
+
+// preprocessImageStages is preprocessImage passing each intermediate image to record,
+// if set, along with its stage name, and also returning the thresholds applied (nil with
+// opts.Off). Images may be modified by later stages, so record must not retain them.
+func preprocessImageStages(img image.Image, opts PreprocessOptions, record func(stage string, img image.Image)) (image.Image, float64, int, int, *ThresholdInfo) {
 	bounds := img.Bounds()
 	w, h := bounds.Dx(), bounds.Dy()
 	pixels := w * h
 
 	// Skip images with any dimension too small to process
 	if w <= minDimension || h <= minDimension {
-		return nil, 0, 0, 0
+		return nil, 0, 0, 0, nil
+	}
+	if opts.Off {
+		return img, 1, w, h, nil
 	}
 
 	// Calculate target dimensions and scale factor based on megapixels
-	newW, newH, scaleFactor := calculateScaleDimensions(w, h, pixels)
+	var tiers ScaleTiers
+	if opts.tiers != nil {
+		tiers = *opts.tiers
+	}
+	newW, newH, scaleFactor := calculateScaleDimensions(w, h, pixels, tiers)
+	if opts.Scale > 0 {
+		scaleFactor = opts.Scale
+		newW = max(int(math.Round(float64(w)*scaleFactor)), 1)
+		newH = max(int(math.Round(float64(h)*scaleFactor)), 1)
+	}
 
 	// Step 1: Scale image using cubic interpolation (CatmullRom)
 	scaled := imaging.Resize(img, newW, newH, imaging.CatmullRom)
+	if record != nil {
+		record("scaled", scaled)
+	}
 
 	// Step 2: Apply median blur to reduce noise
-	blurred := effect.Median(scaled, medianRadius)
+	var blurred image.Image = scaled
+	if !opts.NoMedianBlur {
+		blurred = effect.Median(scaled, medianRadius)
+		if record != nil {
+			record("blurred", blurred)
+		}
+	}
+
+	// Step 3: Convert to grayscale, light gray (224..255 by default) treated as pure white
+	whiteThreshold := opts.WhiteThreshold
+	if whiteThreshold == 0 {
+		whiteThreshold = defaultWhiteThreshold
+	}
+	var grayImg *image.Gray
+	if opts.Equalize {
+		// Equalize the plain luminance first, so thresholding sees the stretched range
+		plain := convertToGray(blurred, 255)
+		if record != nil {
+			record("grayscale", plain)
+		}
+		equalized := equalizeHistogram(plain)
+		if record != nil {
+			record("equalized", equalized)
+		}
+		grayImg = convertToGray(equalized, whiteThreshold)
+	} else {
+		if record != nil {
+			record("grayscale", convertToGray(blurred, 255))
+		}
+		grayImg = convertToGray(blurred, whiteThreshold)
+	}
+	if record != nil {
+		record("thresholded", grayImg)
+	}
+	threshold := &ThresholdInfo{Method: ThresholdMethodFixed, Threshold: int(whiteThreshold)}
+	if opts.BackgroundBand > 0 {
+		background, cutoff := suppressBackgroundBand(grayImg, opts.BackgroundBand)
+		if record != nil {
+			record("background_band", grayImg)
+		}
+		threshold.Method = ThresholdMethodBackgroundBand
+		threshold.Threshold = min(threshold.Threshold, max(cutoff, 0))
+		threshold.BackgroundLevel = background
+	}
 
-	// Step 3: Convert to grayscale, light gray (224..255) treated as pure white
-	grayImg := convertToGray(blurred, 224)
+	return grayImg, scaleFactor, newW, newH, threshold
+}
 
-	return grayImg, scaleFactor, newW, newH
+// calculateScaleDimensions determines target dimensions based on the megapixel
+// thresholds of tiers (see ScaleTiers).
+// Fractional dimensions are rounded to the nearest pixel, so the exact per-axis scale
+// (newW/w, newH/h) may differ slightly from scaleFactor; see axisScaleFactors.
+func calculateScaleDimensions(w, h, pixels int, tiers ScaleTiers) (newW, newH int, scaleFactor float64) {
+	return tiers.dimensions(w, h, pixels)
 }
 
-// calculateScaleDimensions determines target dimensions based on megapixel thresholds.
-func calculateScaleDimensions(w, h, pixels int) (newW, newH int, scaleFactor float64) {
-	switch {
-	case pixels < halfMegapixel:
-		// Less than 0.5 MP: scale 4x
-		scaleFactor = 4.0
-		newW, newH = w*4, h*4
-	case pixels < oneMegapixel:
-		// Less than 1 MP: scale 3x
-		scaleFactor = 3.0
-		newW, newH = w*3, h*3
-	case pixels < twoMegapixels:
-		// Less than 2 MP: scale 1.5x
-		scaleFactor = 1.5
-		newW, newH = w*3/2, h*3/2
-	case pixels <= threeMegapixels:
-		// 2-3 MP: no scaling
-		scaleFactor = 1.0
-		newW, newH = w, h
-	default:
-		// More than 3 MP: scale down to 3 MP
-		scaleFactor = math.Sqrt(float64(threeMegapixels) / float64(pixels))
-		newW = int(float64(w) * scaleFactor)
-		newH = int(float64(h) * scaleFactor)
+// axisScaleFactors returns the exact per-axis scale of scaling a w x h image to newW x newH,
+// or zeros if both axes match scaleFactor exactly.
+func axisScaleFactors(w, h, newW, newH int, scaleFactor float64) (scaleX, scaleY float64) {
+	scaleX, scaleY = float64(newW)/float64(w), float64(newH)/float64(h)
+	if scaleX == scaleFactor && scaleY == scaleFactor {
+		return 0, 0
+	}
+	return scaleX, scaleY
+}
+
+// grayImage returns img if it is grayscale, or else its plain luminance, for the
+// analyses (skew, text angle) that work on grayscale images.
+func grayImage(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
 	}
-	return
+	return convertToGray(img, 255)
 }
 
 // convertToGray converts any image to *image.Gray.
@@ -144,3 +241,71 @@ func convertToGray(img image.Image, threshold uint8) *image.Gray {
 	}
 	return grayImg
 }
+
+// suppressBackgroundBand whitens, in place, every pixel whose luminance is within band
+// levels of the background luminance, estimated as the median (most of a document is
+// paper). Faint low-contrast strokes such as watermarks disappear, while text, which is
+// much darker than the paper, is kept. Returns the background luminance and the cutoff
+// from which pixels were whitened.
+func suppressBackgroundBand(gray *image.Gray, band uint8) (background, cutoff int) {
+	var hist [256]int
+	for _, v := range gray.Pix {
+		hist[v]++
+	}
+	background = histogramPercentile(hist, 0.5)
+	cutoff = background - int(band)
+	for i, v := range gray.Pix {
+		if int(v) >= cutoff {
+			gray.Pix[i] = 0xff
+		}
+	}
+	return background, cutoff
+}
+
+// equalizeHistogram applies global histogram equalization to a grayscale image,
+// spreading its luminance values over the full 0-255 range.
+func equalizeHistogram(gray *image.Gray) *image.Gray {
+	bounds := gray.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return gray
+	}
+
+	var hist [256]int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			hist[gray.GrayAt(x, y).Y]++
+		}
+	}
+
+	// Cumulative distribution, offset by the first non-empty bin
+	var cdf [256]int
+	cdfMin, sum := 0, 0
+	for i, n := range hist {
+		sum += n
+		cdf[i] = sum
+		if cdfMin == 0 && sum > 0 {
+			cdfMin = sum
+		}
+	}
+	if total == cdfMin {
+		// Single luminance value: nothing to equalize
+		return gray
+	}
+
+	var lut [256]uint8
+	for i := range lut {
+		if cdf[i] < cdfMin {
+			continue
+		}
+		lut[i] = uint8(math.Round(float64(cdf[i]-cdfMin) / float64(total-cdfMin) * 255))
+	}
+
+	equalized := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			equalized.SetGray(x-bounds.Min.X, y-bounds.Min.Y, color.Gray{Y: lut[gray.GrayAt(x, y).Y]})
+		}
+	}
+	return equalized
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestDetectTextDegenerateDimensions(t *testing.T) {
+	engine := engineFunc(func([]byte, OCRParams) ([]RecognizedBox, error) {
+		t.Error("engine called for a degenerate image")
+		return nil, nil
+	})
+	c := newTestClassifier(Options{}, engine)
+
+	for _, size := range []image.Point{{1, 1}, {1, 100}, {100, 1}, {32, 32}} {
+		// A transparent tracking pixel and its thin siblings
+		img := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+		result, err := c.DetectText(encodePNG(t, img), c.DefaultDecisionRule())
+		if err != nil {
+			t.Fatalf("%v: DetectText failed: %v", size, err)
+		}
+		if result.Status != StatusTooSmall || result.TokenCount != 0 || result.IsTextDocument {
+			t.Errorf("%v: status %q, %d tokens, text %v; want %q with no tokens",
+				size, result.Status, result.TokenCount, result.IsTextDocument, StatusTooSmall)
+		}
+		if math.IsNaN(result.WeightedConfidence) || math.IsNaN(result.MeanConfidence) || math.IsNaN(result.ScaleFactor) {
+			t.Errorf("%v: NaN in result %+v", size, result)
+		}
+	}
+}
+
+func TestPreprocessImageZeroDimension(t *testing.T) {
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 0, 0), image.Rect(0, 0, 0, 100), image.Rect(0, 0, 100, 0)} {
+		if preprocessed, _, _, _ := preprocessImage(image.NewGray(r), PreprocessOptions{}); preprocessed != nil {
+			t.Errorf("%v: got a preprocessed image, want nil", r)
+		}
+	}
+}
+
+// watermarkedImage returns a white page with dark text lines on the left and a faint
+// watermark block, barely darker than the paper, on the right.
+func watermarkedImage(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for y := h / 8; y < h*7/8; y++ {
+		for x := w / 10; x < w*4/10; x++ {
+			if (y/(h/12))%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 20})
+			}
+		}
+		for x := w * 6 / 10; x < w*9/10; x++ {
+			img.SetGray(x, y, color.Gray{Y: 200})
+		}
+	}
+	return img
+}
+
+// watermarkEngine recognizes a word for the text lines and a watermark word when any
+// watermark stroke is left in the right part of the image.
+func watermarkEngine(imageData []byte, _ OCRParams) ([]RecognizedBox, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, err
+	}
+	gray := grayImage(img)
+	b := gray.Bounds()
+	text, watermark := false, false
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			lum := gray.Pix[gray.PixOffset(x, y)]
+			switch {
+			case x < b.Dx()/2 && lum < 100:
+				text = true
+			case x > b.Dx()*55/100 && lum < 255:
+				watermark = true
+			}
+		}
+	}
+	var boxes []RecognizedBox
+	if text {
+		boxes = append(boxes, word("invoice", 10, 10, 92), word("total", 10, 40, 90))
+	}
+	if watermark {
+		boxes = append(boxes, word("DRAFT", b.Dx()*6/10, 20, 55))
+	}
+	return boxes, nil
+}
+
+func TestBackgroundBandDropsWatermark(t *testing.T) {
+	imageData := encodePNG(t, watermarkedImage(120, 96))
+	tests := []struct {
+		name          string
+		band          uint8
+		wantWatermark bool
+	}{
+		{name: "disabled", band: 0, wantWatermark: true},
+		{name: "band below the watermark", band: 30, wantWatermark: true},
+		{name: "band covering the watermark", band: 80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClassifier(Options{SkipRotation: true, Preprocess: PreprocessOptions{BackgroundBand: tt.band}}, engineFunc(watermarkEngine))
+			result, err := c.DetectText(imageData, c.DefaultDecisionRule())
+			if err != nil {
+				t.Fatalf("DetectText failed: %v", err)
+			}
+			var words []string
+			for _, box := range result.Boxes {
+				words = append(words, box.Word)
+			}
+			if !slices.Contains(words, "invoice") || !slices.Contains(words, "total") {
+				t.Errorf("words = %v, want the text tokens retained", words)
+			}
+			if got := slices.Contains(words, "DRAFT"); got != tt.wantWatermark {
+				t.Errorf("words = %v, want watermark: %v", words, tt.wantWatermark)
+			}
+		})
+	}
+}
+
+func TestSuppressBackgroundBand(t *testing.T) {
+	gray := &image.Gray{Pix: []uint8{255, 255, 255, 250, 200, 20, 255}, Stride: 7, Rect: image.Rect(0, 0, 7, 1)}
+	background, cutoff := suppressBackgroundBand(gray, 10)
+	if background != 255 || cutoff != 245 {
+		t.Errorf("background %d, cutoff %d, want 255, 245", background, cutoff)
+	}
+	want := []uint8{255, 255, 255, 255, 200, 20, 255}
+	if !slices.Equal(gray.Pix, want) {
+		t.Errorf("pixels = %v, want %v", gray.Pix, want)
+	}
+}
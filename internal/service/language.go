@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SupportedLanguages lists Tesseract language codes shipped with the service, mapped to their names.
+var SupportedLanguages = map[string]string{
+	"eng": "English",
+	"rus": "Russian",
+}
+
+// ErrUnsupportedLanguage is returned for language codes missing from SupportedLanguages.
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// ValidateLanguage checks that every component of a Tesseract language string
+// (e.g. "eng", "eng+rus") is present in SupportedLanguages.
+func ValidateLanguage(lang string) error {
+	if lang == "" {
+		return fmt.Errorf("empty language")
+	}
+	for _, code := range strings.Split(lang, "+") {
+		if _, ok := SupportedLanguages[code]; !ok {
+			return fmt.Errorf("%w %q, supported: %s", ErrUnsupportedLanguage, code, strings.Join(SupportedLanguageCodes(), ", "))
+		}
+	}
+	return nil
+}
+
+// ErrLanguageUnavailable is returned when the language data of a requested language
+// is not installed for the OCR engine.
+var ErrLanguageUnavailable = errors.New("language unavailable")
+
+// WarningLanguageFallback is reported when an unavailable requested language was
+// replaced with the default language (see Options.LanguageFallback).
+const WarningLanguageFallback = "requested language unavailable, default language used"
+
+// LanguageLister is implemented by OCR engines that can list the languages
+// whose data is installed.
+type LanguageLister interface {
+	AvailableLanguages() ([]string, error)
+}
+
+// ResolveLanguage checks that every language in lang (a Tesseract language string or a
+// merge:/best-of: list) is in SupportedLanguages, failing with an error wrapping
+// ErrUnsupportedLanguage otherwise, and that its language data is installed. If some is
+// missing, it returns the default language and fallback set when Options.LanguageFallback
+// is enabled, or else an error wrapping ErrLanguageUnavailable that lists the available
+// languages. The auto and script modes are returned as is; installed languages are not
+// checked for engines that cannot list them.
+func (c *Classifier) ResolveLanguage(lang string) (resolved string, fallback bool, err error) {
+	if lang == AutoLanguage || lang == ScriptLanguage {
+		return lang, false, nil
+	}
+
+	langs, ok := parseMergeLanguages(lang)
+	if !ok {
+		if langs, ok = parseLanguageList(lang, BestOfLanguagePrefix); !ok {
+			langs = []string{lang}
+		}
+	}
+	if len(langs) == 0 {
+		return "", false, fmt.Errorf("%w: no languages in %q", ErrUnsupportedLanguage, lang)
+	}
+	for _, l := range langs {
+		if err := ValidateLanguage(l); err != nil {
+			return "", false, err
+		}
+	}
+
+	available := c.availableLanguages()
+	if available == nil {
+		return lang, false, nil
+	}
+	var missing []string
+	for _, l := range langs {
+		for _, code := range strings.Split(l, "+") {
+			if !available[code] {
+				missing = append(missing, code)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return lang, false, nil
+	}
+	if c.opts.LanguageFallback {
+		return c.opts.DefaultLanguage, true, nil
+	}
+
+	codes := make([]string, 0, len(available))
+	for code := range available {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return "", false, fmt.Errorf("%w: %s, available: %s", ErrLanguageUnavailable, strings.Join(missing, ", "), strings.Join(codes, ", "))
+}
+
+// availableLanguages returns the set of installed languages, listed once per classifier,
+// or nil if the engine cannot list them or the list is empty.
+func (c *Classifier) availableLanguages() map[string]bool {
+	c.languagesOnce.Do(func() {
+		lister, ok := c.engine.(LanguageLister)
+		if !ok {
+			return
+		}
+		codes, err := lister.AvailableLanguages()
+		if err != nil || len(codes) == 0 {
+			return
+		}
+		c.languages = make(map[string]bool, len(codes))
+		for _, code := range codes {
+			c.languages[code] = true
+		}
+	})
+	return c.languages
+}
+
+// InstalledLanguages splits the sorted codes of SupportedLanguages into the languages
+// whose data is installed for the OCR engine and the missing ones. For engines that
+// cannot list their languages, every supported language is reported as installed.
+func (c *Classifier) InstalledLanguages() (installed, missing []string) {
+	available := c.availableLanguages()
+	for _, code := range SupportedLanguageCodes() {
+		if available == nil || available[code] {
+			installed = append(installed, code)
+		} else {
+			missing = append(missing, code)
+		}
+	}
+	return installed, missing
+}
+
+// SupportedLanguageCodes returns the sorted codes of SupportedLanguages.
+func SupportedLanguageCodes() []string {
+	codes := make([]string, 0, len(SupportedLanguages))
+	for code := range SupportedLanguages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ParseLanguageThresholds parses per-language confidence thresholds in the
+// "lang=threshold,..." form (e.g. "eng=0.7,rus=0.6"). Languages are Tesseract
+// language strings matched exactly, so "eng+rus" can have its own threshold.
+// Thresholds must be in (0, 1]. An empty string yields an empty map.
+func ParseLanguageThresholds(s string) (map[string]float64, error) {
+	thresholds := make(map[string]float64)
+	if strings.TrimSpace(s) == "" {
+		return thresholds, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		lang, value, ok := strings.Cut(pair, "=")
+		lang = strings.TrimSpace(lang)
+		if !ok || lang == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected lang=threshold", pair)
+		}
+		if err := ValidateLanguage(lang); err != nil {
+			return nil, err
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return nil, fmt.Errorf("invalid threshold %q for %s, expected a number in (0, 1]", value, lang)
+		}
+		thresholds[lang] = threshold
+	}
+	return thresholds, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// listingEngine is an engine whose installed language data can be listed.
+type listingEngine struct {
+	engineFunc
+	languages []string
+	err       error
+}
+
+func (e listingEngine) AvailableLanguages() ([]string, error) {
+	return e.languages, e.err
+}
+
+func TestResolveLanguage(t *testing.T) {
+	// The container ships eng only: rus is supported, but its data is missing
+	engOnly := listingEngine{languages: []string{"eng", "osd"}}
+
+	tests := []struct {
+		name         string
+		engine       OCREngine
+		fallback     bool
+		lang         string
+		want         string
+		wantFallback bool
+		wantErr      error
+		wantMsg      string
+	}{
+		{name: "installed", engine: engOnly, lang: "eng", want: "eng"},
+		{name: "missing", engine: engOnly, lang: "rus", wantErr: ErrLanguageUnavailable, wantMsg: "rus, available: eng, osd"},
+		{name: "missing in combination", engine: engOnly, lang: "eng+rus", wantErr: ErrLanguageUnavailable, wantMsg: "rus, available"},
+		{name: "missing in merge list", engine: engOnly, lang: "merge:eng,rus", wantErr: ErrLanguageUnavailable},
+		{name: "missing in best-of list", engine: engOnly, lang: "best-of:rus,eng", wantErr: ErrLanguageUnavailable},
+		{name: "missing with fallback", engine: engOnly, fallback: true, lang: "rus", want: "eng", wantFallback: true},
+		{name: "unsupported", engine: engOnly, lang: "deu", wantErr: ErrUnsupportedLanguage},
+		{name: "unsupported with fallback", engine: engOnly, fallback: true, lang: "deu", wantErr: ErrUnsupportedLanguage},
+		{name: "auto", engine: engOnly, lang: AutoLanguage, want: AutoLanguage},
+		{name: "engine cannot list", engine: engineFunc(nil), lang: "rus", want: "rus"},
+		{name: "listing fails", engine: listingEngine{err: errors.New("no tessdata")}, lang: "rus", want: "rus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClassifier(Options{DefaultLanguage: "eng", LanguageFallback: tt.fallback}, tt.engine)
+			got, fallback, err := c.ResolveLanguage(tt.lang)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Errorf("error = %q, want it to contain %q", err, tt.wantMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want || fallback != tt.wantFallback {
+				t.Errorf("ResolveLanguage(%q) = %q, %v, want %q, %v", tt.lang, got, fallback, tt.want, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestInstalledLanguages(t *testing.T) {
+	c := newTestClassifier(Options{}, listingEngine{languages: []string{"eng"}})
+	installed, missing := c.InstalledLanguages()
+	if strings.Join(installed, ",") != "eng" || strings.Join(missing, ",") != "rus" {
+		t.Errorf("installed %v, missing %v, want [eng], [rus]", installed, missing)
+	}
+}
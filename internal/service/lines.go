@@ -0,0 +1,124 @@
+package service
+
+import (
+	"sort"
+	"strings"
+)
+
+// lineOverlapRatio is the minimum vertical overlap, relative to the smaller height,
+// for a word box to be assigned to a line.
+const lineOverlapRatio = 0.5
+
+// TextLine is a line of recognized text assembled from word boxes.
+type TextLine struct {
+	Text       string      `json:"text"`
+	Confidence float64     `json:"confidence"`
+	Box        BoundingBox `json:"box"`
+}
+
+// groupTextLines groups word boxes into lines by vertical overlap and returns them
+// top-to-bottom. Words in a line are ordered left-to-right and joined with spaces;
+// the line confidence is the token-weighted mean of its word confidences.
+func groupTextLines(boxes []BoundingBox) []TextLine {
+	if len(boxes) == 0 {
+		return nil
+	}
+
+	lines, lineBoxes := groupLineBoxes(boxes)
+	result := make([]TextLine, 0, len(lines))
+	for i, words := range lines {
+		texts := make([]string, len(words))
+		var weighted, tokens float64
+		for j, word := range words {
+			texts[j] = word.Word
+			n := float64(countTokens(word.Word))
+			weighted += word.Confidence * n
+			tokens += n
+		}
+
+		line := TextLine{Text: strings.Join(texts, " "), Box: lineBoxes[i]}
+		if tokens > 0 {
+			line.Confidence = weighted / tokens
+		}
+		line.Box.Word = line.Text
+		line.Box.Confidence = line.Confidence
+		line.Box.Language = ""
+		result = append(result, line)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Box.Y < result[j].Box.Y
+	})
+	return result
+}
+
+// WordLine is a line of word boxes in reading order.
+type WordLine struct {
+	Box   BoundingBox
+	Words []BoundingBox
+}
+
+// GroupLines groups word boxes into lines by vertical overlap and returns them
+// top-to-bottom, with the words of each line ordered left-to-right.
+func GroupLines(boxes []BoundingBox) []WordLine {
+	lines, lineBoxes := groupLineBoxes(boxes)
+	result := make([]WordLine, len(lines))
+	for i, words := range lines {
+		result[i] = WordLine{Box: lineBoxes[i], Words: words}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Box.Y < result[j].Box.Y
+	})
+	return result
+}
+
+// groupLineBoxes assigns boxes to lines by vertical overlap. It returns the boxes of each
+// line ordered left-to-right, and the union box of each line.
+func groupLineBoxes(boxes []BoundingBox) (lines [][]BoundingBox, lineBoxes []BoundingBox) {
+	sorted := make([]BoundingBox, len(boxes))
+	copy(sorted, boxes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Y*2+sorted[i].Height < sorted[j].Y*2+sorted[j].Height
+	})
+
+	for _, box := range sorted {
+		assigned := false
+		for i, lineBox := range lineBoxes {
+			if verticalOverlap(box, lineBox) {
+				lines[i] = append(lines[i], box)
+				lineBoxes[i] = unionBox(lineBox, box)
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			lines = append(lines, []BoundingBox{box})
+			lineBoxes = append(lineBoxes, box)
+		}
+	}
+
+	for _, words := range lines {
+		sort.SliceStable(words, func(a, b int) bool {
+			return words[a].X < words[b].X
+		})
+	}
+	return lines, lineBoxes
+}
+
+// verticalOverlap reports whether two boxes overlap vertically by at least
+// lineOverlapRatio of the smaller height.
+func verticalOverlap(a, b BoundingBox) bool {
+	top := max(a.Y, b.Y)
+	bottom := min(a.Y+a.Height, b.Y+b.Height)
+	minHeight := min(a.Height, b.Height)
+	if minHeight <= 0 {
+		return false
+	}
+	return float64(bottom-top) >= lineOverlapRatio*float64(minHeight)
+}
+
+// unionBox returns the smallest box containing both a and b.
+func unionBox(a, b BoundingBox) BoundingBox {
+	r := a.rect().Union(b.rect())
+	return BoundingBox{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
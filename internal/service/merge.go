@@ -0,0 +1,252 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// MergeLanguagePrefix marks a language parameter requesting per-language recognition
+	// with merged boxes, e.g. "merge:eng,rus".
+	MergeLanguagePrefix = "merge:"
+
+	// mergeIoUThreshold is the overlap above which boxes from different languages
+	// are considered the same text region.
+	mergeIoUThreshold = 0.5
+)
+
+// parseMergeLanguages returns the language list of a "merge:lang1,lang2" parameter
+// and true, or nil and false if language is not a merge request.
+func parseMergeLanguages(language string) ([]string, bool) {
+	return parseLanguageList(language, MergeLanguagePrefix)
+}
+
+// parseLanguageList returns the comma-separated languages following prefix in language
+// and true, or nil and false if language does not start with prefix.
+func parseLanguageList(language, prefix string) ([]string, bool) {
+	if !strings.HasPrefix(language, prefix) {
+		return nil, false
+	}
+	var langs []string
+	for _, lang := range strings.Split(strings.TrimPrefix(language, prefix), ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	return langs, true
+}
+
+// detectTextMerged finds the winning orientation using all languages combined, then
+// recognizes the oriented image with each language separately and merges their boxes,
+// keeping the higher-confidence recognition for overlapping regions.
+func (c *Classifier) detectTextMerged(imageData []byte, rule DecisionRule, langs []string) (*ClassifierResult, error) {
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("merge mode requires at least one language")
+	}
+
+	result, perLanguage, err := c.recognizeEachLanguage(imageData, rule, langs)
+	if err != nil || perLanguage == nil {
+		return result, err
+	}
+	for i, lang := range langs {
+		for j := range perLanguage[i] {
+			perLanguage[i][j].Language = lang
+			// Layouts of separate recognitions cannot be combined into blocks
+			perLanguage[i][j].layout = layoutKey{}
+		}
+	}
+
+	start := rule.Timing.start()
+	boxes := mergeLanguageBoxes(perLanguage)
+	rule.Timing.record("merge", start)
+	return c.finishLanguageResult(rule, result, boxes), nil
+}
+
+// recognizeEachLanguage finds the winning orientation using all languages combined, then
+// recognizes the oriented image with each language separately and concurrently.
+// It returns the orientation result and the boxes of each language in langs order.
+// The boxes are nil if the image is too small to be preprocessed: the orientation
+// result is final then.
+func (c *Classifier) recognizeEachLanguage(imageData []byte, rule DecisionRule, langs []string) (*ClassifierResult, [][]BoundingBox, error) {
+	orientRule := rule
+	orientRule.Language = strings.Join(langs, "+")
+	result, err := c.detectText(imageData, orientRule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if profile := c.chainProfile(result.PreprocessProfile); profile != nil {
+		// Recognize with the preprocessing that won the orientation pass
+		rule.Preprocess = profile
+	}
+	if result.ScaleScores != nil {
+		// Recognize at the scale factor that won the orientation pass
+		opts := c.preprocessOptions(rule)
+		opts.Scale = result.ScaleFactor
+		rule.Preprocess = &opts
+	}
+	start := rule.Timing.start()
+	data, err := c.orientedImageData(imageData, result.Angle, rule)
+	rule.Timing.record("oriented_image", start)
+	if err != nil {
+		return nil, nil, err
+	}
+	if data == nil {
+		return result, nil, nil
+	}
+
+	params := c.normalizeDecisionRule(rule).OCRParams
+	perLanguage := make([][]BoundingBox, len(langs))
+	errs := make([]error, len(langs))
+	var wg sync.WaitGroup
+	for i, lang := range langs {
+		wg.Add(1)
+		go func(i int, lang string) {
+			defer wg.Done()
+			start := rule.Timing.start()
+			langParams := params
+			langParams.Language = lang
+			res, err := c.detectTextSingle(data, langParams)
+			rule.Timing.record("ocr_"+lang, start)
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to detect text for language %s: %w", lang, err)
+				return
+			}
+			perLanguage[i] = res.Boxes
+		}(i, lang)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return result, perLanguage, nil
+}
+
+// finishLanguageResult replaces the boxes of the orientation result with boxes chosen
+// from the per-language recognitions and recomputes the derived fields and the decision.
+func (c *Classifier) finishLanguageResult(rule DecisionRule, result *ClassifierResult, boxes []BoundingBox) *ClassifierResult {
+	counted, noisy := c.countedBoxes(boxes)
+	if noisy {
+		addWarning(result, WarningRepeatedTokens)
+	}
+	totalTokens := 0
+	for _, box := range counted {
+		totalTokens += countTokens(box.Word)
+	}
+
+	result.Boxes = boxes
+	if result.Status == StatusRecognitionFailed && len(boxes) > 0 {
+		// The per-language passes recognized what the combined pass could not
+		result.Status = ""
+	}
+	result.Text = RecognizedText(boxes, TextFormatLines)
+	result.TextLines = nil
+	if c.opts.TextLines {
+		result.TextLines = groupTextLines(boxes)
+	}
+	result.Blocks = nil
+	if c.opts.Blocks {
+		result.Blocks = groupBlocks(boxes)
+	}
+	if c.opts.TextColor {
+		result.TextColor = ""
+		// The source image decoded and cropped without error in DetectText above
+		if img := result.source; img != nil {
+			if rule.ROI != nil {
+				img, _ = cropToROI(img, *rule.ROI)
+			}
+			img, _ = c.padSmallImage(img)
+			annotateTextColors(img, result)
+		}
+	}
+	if origin := result.roiOrigin; origin != (image.Point{}) {
+		offsetBoxes(result, origin, result.fullSize)
+	}
+	result.TokenCount = totalTokens
+	result.MeanConfidence, result.WeightedConfidence = 0, 0
+	if totalTokens > 0 {
+		result.MeanConfidence, result.WeightedConfidence = c.calculateConfidenceMetrics(counted, totalTokens)
+	}
+	result.IsTextDocument = EvaluateDecision(result.WeightedConfidence, result.TokenCount, c.normalizeDecisionRule(rule))
+	result.Timings = rule.Timing.Stages()
+	return result
+}
+
+// orientedImageData reproduces the OCR input for the given angle: the preprocessed image
+// (cropped to roi, if set, and padded if small) rotated by angle, or the raw data if the
+// image cannot be decoded.
+// Returns nil data if the image is too small to be preprocessed.
+func (c *Classifier) orientedImageData(imageData []byte, angle int, rule DecisionRule) ([]byte, error) {
+	img, err := c.decodeImage(imageData)
+	if err != nil {
+		return imageData, nil
+	}
+	if rule.ROI != nil {
+		if img, err = cropToROI(img, *rule.ROI); err != nil {
+			return nil, err
+		}
+	}
+
+	img, _ = c.padSmallImage(img)
+
+	preprocessed, _, _, _ := preprocessImage(img, c.preprocessOptions(rule))
+	if preprocessed == nil {
+		return nil, nil
+	}
+
+	var oriented image.Image = preprocessed
+	if angle != 0 {
+		oriented = rotateImage(preprocessed, angle, c.opts.RotationFill)
+	}
+	data, err := c.encodeIntermediate(oriented)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode oriented image: %w", err)
+	}
+	return data, nil
+}
+
+// mergeLanguageBoxes merges boxes recognized with different languages.
+// Overlapping boxes are resolved in favor of the higher confidence one;
+// the result is ordered top-to-bottom, left-to-right.
+func mergeLanguageBoxes(perLanguage [][]BoundingBox) []BoundingBox {
+	var all []BoundingBox
+	for _, boxes := range perLanguage {
+		all = append(all, boxes...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Confidence > all[j].Confidence
+	})
+
+	merged := make([]BoundingBox, 0, len(all))
+	for _, box := range all {
+		overlaps := false
+		for _, kept := range merged {
+			if boxIoU(box.rect(), kept.rect()) > mergeIoUThreshold {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			merged = append(merged, box)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Y != merged[j].Y {
+			return merged[i].Y < merged[j].Y
+		}
+		return merged[i].X < merged[j].X
+	})
+	return merged
+}
+
+// rect returns the box as an image.Rectangle.
+func (b BoundingBox) rect() image.Rectangle {
+	return image.Rect(b.X, b.Y, b.X+b.Width, b.Y+b.Height)
+}
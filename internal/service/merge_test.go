@@ -0,0 +1,92 @@
+package service
+
+import (
+	"image"
+	"testing"
+)
+
+func TestParseMergeLanguages(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   []string
+		wantOK bool
+	}{
+		{input: "merge:eng,rus", want: []string{"eng", "rus"}, wantOK: true},
+		{input: "merge: eng , rus,", want: []string{"eng", "rus"}, wantOK: true},
+		{input: "eng+rus"},
+		{input: "best-of:eng,rus"},
+	}
+	for _, tt := range tests {
+		got, ok := parseMergeLanguages(tt.input)
+		if ok != tt.wantOK || len(got) != len(tt.want) {
+			t.Errorf("parseMergeLanguages(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseMergeLanguages(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestMergeLanguageBoxes(t *testing.T) {
+	eng := []BoundingBox{
+		{X: 10, Y: 10, Width: 70, Height: 20, Word: "invoice", Confidence: 0.92, Language: "eng"},
+		{X: 10, Y: 50, Width: 40, Height: 20, Word: "cyet", Confidence: 0.30, Language: "eng"},
+	}
+	rus := []BoundingBox{
+		{X: 12, Y: 10, Width: 68, Height: 20, Word: "инвойс", Confidence: 0.40, Language: "rus"},
+		{X: 10, Y: 50, Width: 42, Height: 20, Word: "счёт", Confidence: 0.90, Language: "rus"},
+		{X: 10, Y: 90, Width: 50, Height: 20, Word: "итого", Confidence: 0.85, Language: "rus"},
+	}
+
+	merged := mergeLanguageBoxes([][]BoundingBox{eng, rus})
+	want := []struct{ word, lang string }{{"invoice", "eng"}, {"счёт", "rus"}, {"итого", "rus"}}
+	if len(merged) != len(want) {
+		t.Fatalf("got %d boxes %+v, want %d", len(merged), merged, len(want))
+	}
+	for i, w := range want {
+		if merged[i].Word != w.word || merged[i].Language != w.lang {
+			t.Errorf("box %d = %q (%s), want %q (%s)", i, merged[i].Word, merged[i].Language, w.word, w.lang)
+		}
+	}
+}
+
+// TestDetectTextMergedBilingualPage recognizes a page with an English and a Russian
+// line: each language reads its own line well and garbles the other one.
+func TestDetectTextMergedBilingualPage(t *testing.T) {
+	box := func(text string, y int, confidence float64) RecognizedBox {
+		return RecognizedBox{Box: image.Rect(10, y, 90, y+20), Word: text, Confidence: confidence}
+	}
+	engine := engineFunc(func(_ []byte, params OCRParams) ([]RecognizedBox, error) {
+		switch params.Language {
+		case "eng":
+			return []RecognizedBox{box("invoice", 10, 93), box("cyet", 50, 31)}, nil
+		case "rus":
+			return []RecognizedBox{box("инвойс", 10, 36), box("счёт", 50, 91)}, nil
+		default:
+			return []RecognizedBox{box("invoice", 10, 70), box("счёт", 50, 70)}, nil
+		}
+	})
+	c := newTestClassifier(Options{SkipRotation: true}, engine)
+	rule := c.DefaultDecisionRule()
+	rule.Language = MergeLanguagePrefix + "eng,rus"
+
+	result, err := c.DetectText(encodePNG(t, textImage(160, 120)), rule)
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	want := []struct{ word, lang string }{{"invoice", "eng"}, {"счёт", "rus"}}
+	if len(result.Boxes) != len(want) {
+		t.Fatalf("got %d boxes %+v, want %d", len(result.Boxes), result.Boxes, len(want))
+	}
+	for i, w := range want {
+		if result.Boxes[i].Word != w.word || result.Boxes[i].Language != w.lang {
+			t.Errorf("box %d = %q (%s), want %q (%s)", i, result.Boxes[i].Word, result.Boxes[i].Language, w.word, w.lang)
+		}
+	}
+	if result.WeightedConfidence < 0.9 {
+		t.Errorf("WeightedConfidence = %v, want the confidence of the better language per line", result.WeightedConfidence)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics records statistics of classification requests and results.
+type Metrics interface {
+	// ObserveRequest records a classify request that reached recognition: the requested
+	// language, how long recognition took and whether it failed.
+	ObserveRequest(language string, elapsed time.Duration, failed bool)
+	// ObserveResult records a completed classification.
+	ObserveResult(result *ClassifierResult)
+	// ObserveArchiveFailure records an archive job that was not stored, with the reason
+	// ArchiveDropped or ArchiveFailed.
+	ObserveArchiveFailure(reason string)
+}
+
+// NopMetrics is a Metrics that records nothing.
+type NopMetrics struct{}
+
+// ObserveRequest does nothing.
+func (NopMetrics) ObserveRequest(string, time.Duration, bool) {}
+
+// ObserveResult does nothing.
+func (NopMetrics) ObserveResult(*ClassifierResult) {}
+
+// ObserveArchiveFailure does nothing.
+func (NopMetrics) ObserveArchiveFailure(string) {}
+
+// PrometheusMetrics is a Metrics exposing its statistics through the default
+// Prometheus registry. Collectors are safe for concurrent use.
+type PrometheusMetrics struct {
+	requests   *prometheus.CounterVec
+	errors     prometheus.Counter
+	duration   prometheus.Histogram
+	angles     *prometheus.CounterVec
+	phase2     *prometheus.CounterVec
+	sweep      prometheus.Histogram
+	confidence prometheus.Histogram
+	noText     prometheus.Counter
+	archive    *prometheus.CounterVec
+}
+
+// NewPrometheusMetrics creates a PrometheusMetrics and registers its collectors
+// with the default registry. It panics if called more than once.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	m := &PrometheusMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ocr_classifier",
+			Name:      "requests_total",
+			Help:      "Number of classify requests that reached recognition, by requested language.",
+		}, []string{"language"}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "ocr_classifier",
+			Name:      "errors_total",
+			Help:      "Number of classify requests whose recognition failed.",
+		}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "ocr_classifier",
+			Name:      "ocr_duration_seconds",
+			Help:      "Time spent in recognition per classify request, including preprocessing.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+		}),
+		angles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ocr_classifier",
+			Name:      "winning_angle_total",
+			Help:      "Number of classified images by the rotation angle of the returned result.",
+		}, []string{"angle"}),
+		phase2: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ocr_classifier",
+			Name:      "phase2_total",
+			Help:      "Number of classified images by the phase 2 gate outcome; skip means the rotation search was not needed.",
+		}, []string{"gate"}),
+		sweep: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "ocr_classifier",
+			Name:      "sweep_passes",
+			Help:      "Number of rotation angles tried in phase 2 per classified image.",
+			Buckets:   []float64{0, 1, 2, 4, 8, 16, 32, 64},
+		}),
+		confidence: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "ocr_classifier",
+			Name:      "confidence",
+			Help:      "Weighted confidence of the returned result per classified image.",
+			Buckets:   prometheus.LinearBuckets(0.1, 0.1, 10),
+		}),
+		noText: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "ocr_classifier",
+			Name:      "no_text_total",
+			Help:      "Number of classified images in which no tokens were recognized.",
+		}),
+		archive: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ocr_classifier",
+			Name:      "archive_failures_total",
+			Help:      "Number of classifications not archived, by reason: dropped on a full archive queue or failed to store.",
+		}, []string{"reason"}),
+	}
+	prometheus.MustRegister(m.requests, m.errors, m.duration, m.angles, m.phase2, m.sweep, m.confidence, m.noText, m.archive)
+	return m
+}
+
+// NewMetrics returns a PrometheusMetrics if enabled, or a NopMetrics otherwise.
+func NewMetrics(enabled bool) Metrics {
+	if !enabled {
+		return NopMetrics{}
+	}
+	return NewPrometheusMetrics()
+}
+
+// ObserveRequest counts the request by language and records its recognition time.
+// Failed requests are also counted as errors.
+func (m *PrometheusMetrics) ObserveRequest(language string, elapsed time.Duration, failed bool) {
+	m.requests.WithLabelValues(language).Inc()
+	m.duration.Observe(elapsed.Seconds())
+	if failed {
+		m.errors.Inc()
+	}
+}
+
+// ObserveResult records the winning angle, the phase 2 gate, the angles tried and the
+// confidence of the result, and whether it has no tokens. Error and too-small results
+// are not classified and are not counted.
+func (m *PrometheusMetrics) ObserveResult(result *ClassifierResult) {
+	if result.Status != "" {
+		return
+	}
+	m.angles.WithLabelValues(strconv.Itoa(result.Angle)).Inc()
+	if result.Phase2Gate != "" {
+		m.phase2.WithLabelValues(result.Phase2Gate).Inc()
+	}
+	m.sweep.Observe(float64(result.SweepPasses))
+	m.confidence.Observe(result.WeightedConfidence)
+	if result.TokenCount == 0 {
+		m.noText.Inc()
+	}
+}
+
+// ObserveArchiveFailure counts the archive job that was not stored by reason.
+func (m *PrometheusMetrics) ObserveArchiveFailure(reason string) {
+	m.archive.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// mixedGridSize is the number of tiles per side the oriented page is split into
+	// when looking for regions with a different text orientation.
+	mixedGridSize = 3
+	// mixedMinInkRatio is the minimum share of ink pixels for a tile to be examined.
+	mixedMinInkRatio = 0.01
+	// mixedMaxCoverage is the maximum share of a tile's ink already covered by recognized
+	// boxes for the tile to be re-recognized.
+	mixedMaxCoverage = 0.5
+	// mixedMinAngle is the minimum deviation in degrees of the local text-line angle
+	// from the page orientation for a tile to be re-recognized.
+	mixedMinAngle = 15
+)
+
+// detectMixedOrientation looks for regions of the oriented page whose text runs at a
+// different angle (rotated stamps, sideways notes), recognizes them at their own angle
+// and merges their boxes into result. Merged boxes are tagged with their local angle
+// and mapped back to the coordinates of the oriented page.
+func (c *Classifier) detectMixedOrientation(preprocessed image.Image, result *ClassifierResult, rule DecisionRule) {
+	rule = c.normalizeDecisionRule(rule)
+
+	var page image.Image = preprocessed
+	if result.Angle != 0 {
+		page = rotateImage(preprocessed, result.Angle, c.opts.RotationFill)
+	}
+	gray := convertToGray(page, 255)
+	bounds := gray.Bounds()
+	tileW, tileH := bounds.Dx()/mixedGridSize, bounds.Dy()/mixedGridSize
+	if tileW <= minDimension || tileH <= minDimension {
+		return
+	}
+
+	added := false
+	for ty := 0; ty < mixedGridSize; ty++ {
+		for tx := 0; tx < mixedGridSize; tx++ {
+			tile := image.Rect(tx*tileW, ty*tileH, (tx+1)*tileW, (ty+1)*tileH)
+			boxes := c.recognizeMisorientedTile(gray, tile, result.Boxes, rule)
+			if len(boxes) > 0 {
+				result.Boxes = append(result.Boxes, boxes...)
+				added = true
+			}
+		}
+	}
+	if !added {
+		return
+	}
+
+	counted, noisy := c.countedBoxes(result.Boxes)
+	if noisy {
+		addWarning(result, WarningRepeatedTokens)
+	}
+	totalTokens := 0
+	for _, box := range counted {
+		totalTokens += countTokens(box.Word)
+	}
+	result.TokenCount = totalTokens
+	result.MeanConfidence, result.WeightedConfidence = 0, 0
+	if totalTokens > 0 {
+		result.MeanConfidence, result.WeightedConfidence = c.calculateConfidenceMetrics(counted, totalTokens)
+	}
+	result.Text = RecognizedText(result.Boxes, TextFormatLines)
+	if c.opts.TextLines {
+		result.TextLines = groupTextLines(result.Boxes)
+	}
+	result.IsTextDocument = EvaluateDecision(result.WeightedConfidence, result.TokenCount, rule)
+}
+
+// recognizeMisorientedTile re-recognizes a tile of the page at its own text-line angle
+// if it holds ink that is mostly not covered by existing boxes and runs at a clearly
+// different angle. Returns the new boxes in page coordinates, or nil.
+func (c *Classifier) recognizeMisorientedTile(page *image.Gray, tile image.Rectangle, existing []BoundingBox, rule DecisionRule) []BoundingBox {
+	ink, covered := 0, 0
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		for x := tile.Min.X; x < tile.Max.X; x++ {
+			if page.GrayAt(x, y).Y >= estimateDarkThreshold {
+				continue
+			}
+			ink++
+			if pointInBoxes(x, y, existing) {
+				covered++
+			}
+		}
+	}
+	if float64(ink) < mixedMinInkRatio*float64(tile.Dx()*tile.Dy()) || float64(covered) > mixedMaxCoverage*float64(ink) {
+		return nil
+	}
+
+	crop := imaging.Crop(page, tile)
+	cropGray := convertToGray(crop, 255)
+	angle, confidence := estimateTextAngle(cropGray)
+	if confidence < minAngleEstimateConfidence || math.Abs(angle) < mixedMinAngle {
+		return nil
+	}
+
+	var best *ClassifierResult
+	bestAngle := 0
+	for _, candidate := range []int{int(math.Round(angle)), int(math.Round(angle)) + 180} {
+		rotated := rotateImage(cropGray, candidate, c.opts.RotationFill)
+		data, err := c.encodeIntermediate(rotated)
+		if err != nil {
+			continue
+		}
+		res, err := c.detectTextSingle(data, rule.OCRParams)
+		if err != nil || res.TokenCount == 0 {
+			continue
+		}
+		if best == nil || res.WeightedConfidence > best.WeightedConfidence {
+			best, bestAngle = res, candidate
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	boxes := make([]BoundingBox, 0, len(best.Boxes))
+	for _, box := range best.Boxes {
+		mapped := unrotateBox(box, bestAngle, best.BoundingBoxWidth, best.BoundingBoxHeight, tile.Dx(), tile.Dy())
+		mapped.X += tile.Min.X
+		mapped.Y += tile.Min.Y
+		mapped.Angle = ((bestAngle % 360) + 360) % 360
+		boxes = append(boxes, mapped)
+	}
+	return boxes
+}
+
+// unrotateBox maps a box found in an image rotated counter-clockwise by angleDeg
+// (of size rotW x rotH) back to the unrotated image (of size origW x origH),
+// returning the axis-aligned bounding box of the mapped corners.
+func unrotateBox(box BoundingBox, angleDeg, rotW, rotH, origW, origH int) BoundingBox {
+	theta := float64(angleDeg) * math.Pi / 180.0
+	sinT, cosT := math.Sin(theta), math.Cos(theta)
+	rcx, rcy := float64(rotW)/2, float64(rotH)/2
+	ocx, ocy := float64(origW)/2, float64(origH)/2
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	corners := [4][2]float64{
+		{float64(box.X), float64(box.Y)},
+		{float64(box.X + box.Width), float64(box.Y)},
+		{float64(box.X), float64(box.Y + box.Height)},
+		{float64(box.X + box.Width), float64(box.Y + box.Height)},
+	}
+	for _, p := range corners {
+		vx, vy := p[0]-rcx, p[1]-rcy
+		x := ocx + vx*cosT - vy*sinT
+		y := ocy + vx*sinT + vy*cosT
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+
+	box.X, box.Y = int(math.Round(minX)), int(math.Round(minY))
+	box.Width, box.Height = int(math.Round(maxX-minX)), int(math.Round(maxY-minY))
+	return box
+}
+
+// pointInBoxes reports whether (x, y) lies inside any of the boxes.
+func pointInBoxes(x, y int, boxes []BoundingBox) bool {
+	p := image.Pt(x, y)
+	for _, box := range boxes {
+		if p.In(box.rect()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OCR engine modes selecting Tesseract's recognizer.
+const (
+	// OEMDefault lets Tesseract choose, which is LSTM when the language data has an LSTM model.
+	OEMDefault = "default"
+	// OEMLegacy uses the legacy pattern-matching recognizer only.
+	OEMLegacy = "legacy"
+	// OEMLSTM uses the LSTM neural network recognizer only.
+	OEMLSTM = "lstm"
+	// OEMCombined runs both recognizers and combines their results.
+	OEMCombined = "combined"
+)
+
+// oemValues maps engine mode names to Tesseract's tessedit_ocr_engine_mode values.
+var oemValues = map[string]int{
+	OEMLegacy:   0,
+	OEMLSTM:     1,
+	OEMCombined: 2,
+	OEMDefault:  3,
+}
+
+var (
+	oemConfigMu    sync.Mutex
+	oemConfigFiles = map[string]string{}
+)
+
+// ValidateOEM checks that mode is a supported engine mode name. Empty means OEMDefault.
+func ValidateOEM(mode string) error {
+	if _, ok := oemValues[mode]; !ok && mode != "" {
+		return fmt.Errorf("unsupported OCR engine mode %q, supported: %s", mode, strings.Join(oemNames(), ", "))
+	}
+	return nil
+}
+
+// oemNames returns the sorted engine mode names.
+func oemNames() []string {
+	names := make([]string, 0, len(oemValues))
+	for name := range oemValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// oemConfigFile returns the path of a Tesseract config file selecting the engine mode.
+// The engine mode can only be chosen when Tesseract is initialized, and gosseract
+// always initializes it with OEM_DEFAULT, which defers to the config file.
+// Files are created in the temp directory once per mode and reused.
+func oemConfigFile(mode string) (string, error) {
+	value, ok := oemValues[mode]
+	if !ok {
+		return "", fmt.Errorf("unsupported OCR engine mode %q", mode)
+	}
+
+	oemConfigMu.Lock()
+	defer oemConfigMu.Unlock()
+	if path, ok := oemConfigFiles[mode]; ok {
+		return path, nil
+	}
+
+	f, err := os.CreateTemp("", "ocr-classifier-oem-*.config")
+	if err != nil {
+		return "", fmt.Errorf("failed to create engine mode config: %w", err)
+	}
+	_, err = fmt.Fprintf(f, "tessedit_ocr_engine_mode %d\n", value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write engine mode config: %w", err)
+	}
+	oemConfigFiles[mode] = f.Name()
+	return f.Name(), nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Orientation strategies for phase 2 (rotation search).
+const (
+	// OrientationSweep tries every candidate angle produced by the Hough-based skew detection.
+	OrientationSweep = "sweep"
+	// OrientationEstimate estimates the text-line angle from projection profiles and only tries
+	// that angle, its 180-degree flip and a small refinement around them.
+	OrientationEstimate = "estimate"
+)
+
+const (
+	// estimateDarkThreshold is the luminance below which a pixel is treated as ink.
+	estimateDarkThreshold = 128
+	// estimateSampleStep is the pixel stride used to subsample ink pixels.
+	estimateSampleStep = 2
+	// estimateMinInkPixels is the minimum number of sampled ink pixels needed for an estimate.
+	estimateMinInkPixels = 200
+	// minAngleEstimateConfidence is the estimate confidence below which the full sweep is used.
+	minAngleEstimateConfidence = 0.25
+	// estimateRefineStep is the refinement offset in degrees tried around the estimated angle.
+	estimateRefineStep = 1
+	// deskewMaxAngle is the largest skew in degrees corrected by Deskew; larger deviations
+	// are left to the phase 2 rotation search.
+	deskewMaxAngle = 15
+)
+
+// ValidateOrientationStrategy checks that strategy is a known orientation strategy.
+func ValidateOrientationStrategy(strategy string) error {
+	switch strategy {
+	case OrientationSweep, OrientationEstimate:
+		return nil
+	default:
+		return fmt.Errorf("unsupported orientation strategy %q, supported: %s, %s", strategy, OrientationSweep, OrientationEstimate)
+	}
+}
+
+// ParseAngles parses a comma-separated list of rotation angles in degrees, e.g. "0,5,355"
+// or "-5,5". Angles are normalized to [0, 360) and duplicates dropped; an empty string
+// yields nil, leaving the candidate angles to the orientation strategy.
+func ParseAngles(s string) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	seen := make(map[int]bool)
+	angles := []int{}
+	for _, part := range strings.Split(s, ",") {
+		angle, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid angles: %q is not an integer", part)
+		}
+		angle = ((angle % 360) + 360) % 360
+		if !seen[angle] {
+			seen[angle] = true
+			angles = append(angles, angle)
+		}
+	}
+	return angles, nil
+}
+
+// detectTextWithEstimate performs phase 2 using a single estimated text-line angle.
+// It falls back to the full rotation sweep when the estimate is not confident enough.
+func (c *Classifier) detectTextWithEstimate(preprocessed image.Image, scaleFactor float64, phase1Result *ClassifierResult, rule DecisionRule, imgWidth, imgHeight int) (*ClassifierResult, error) {
+	angle, confidence := estimateTextAngle(grayImage(preprocessed))
+	if confidence < minAngleEstimateConfidence {
+		return c.detectTextWithRotations(preprocessed, scaleFactor, phase1Result, rule, imgWidth, imgHeight)
+	}
+
+	return c.tryRotationAngles(preprocessed, scaleFactor, phase1Result, rule, estimatedCandidateAngles(angle), imgWidth, imgHeight)
+}
+
+// estimateTextAngle estimates the dominant text-line angle in degrees, in the range [-90, 90).
+// For every trial angle, sampled ink pixels are projected onto the line normal and the
+// sharpness of the resulting profile (sum of squared bin counts) is measured: text lines
+// produce a strongly peaked profile when the trial angle matches their direction.
+// The returned confidence in [0, 1] measures how much the best profile stands out from the average.
+func estimateTextAngle(gray *image.Gray) (angle, confidence float64) {
+	bounds := gray.Bounds()
+	var xs, ys []float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += estimateSampleStep {
+		for x := bounds.Min.X; x < bounds.Max.X; x += estimateSampleStep {
+			if gray.GrayAt(x, y).Y < estimateDarkThreshold {
+				xs = append(xs, float64(x-bounds.Min.X))
+				ys = append(ys, float64(y-bounds.Min.Y))
+			}
+		}
+	}
+	if len(xs) < estimateMinInkPixels {
+		return 0, 0
+	}
+
+	maxDist := math.Hypot(float64(bounds.Dx()), float64(bounds.Dy()))
+	// Bins are as wide as the sampling stride, so axis-aligned projections do not alias.
+	bins := make([]int, int(2*maxDist)/estimateSampleStep+1)
+
+	bestScore, totalScore := 0.0, 0.0
+	numAngles := 0
+	for deg := -90; deg < 90; deg++ {
+		theta := float64(deg) * math.Pi / 180.0
+		sinT, cosT := math.Sin(theta), math.Cos(theta)
+
+		for i := range bins {
+			bins[i] = 0
+		}
+		for i := range xs {
+			idx := int(-xs[i]*sinT+ys[i]*cosT+maxDist) / estimateSampleStep
+			if idx >= 0 && idx < len(bins) {
+				bins[idx]++
+			}
+		}
+
+		score := 0.0
+		for _, n := range bins {
+			score += float64(n) * float64(n)
+		}
+		if score > bestScore {
+			bestScore = score
+			angle = float64(deg)
+		}
+		totalScore += score
+		numAngles++
+	}
+
+	if bestScore == 0 {
+		return 0, 0
+	}
+	meanScore := totalScore / float64(numAngles)
+	return angle, (bestScore - meanScore) / bestScore
+}
+
+// estimatedCandidateAngles returns the rotation angles to try for an estimated text-line angle:
+// the angle itself, its 180-degree flip, and a small refinement around both.
+func estimatedCandidateAngles(angleDeg float64) []int {
+	base := int(math.Round(angleDeg))
+	normalize := func(angle int) int {
+		return ((angle % 360) + 360) % 360
+	}
+
+	raw := []int{
+		base,
+		base + 180,
+		base - estimateRefineStep,
+		base + estimateRefineStep,
+		base + 180 - estimateRefineStep,
+		base + 180 + estimateRefineStep,
+	}
+
+	seen := make(map[int]bool)
+	var unique []int
+	for _, a := range raw {
+		n := normalize(a)
+		if n != 0 && !seen[n] {
+			seen[n] = true
+			unique = append(unique, n)
+		}
+	}
+	return unique
+}
+
+// clampNearUpright prunes the sweep angles when a reliable projection-profile estimate
+// puts the text within SkewClamp degrees of upright: scanning deviation angles far from
+// the estimate is pointless then, so only the cardinal angles (which catch 90/180/270
+// orientations) and the estimate plus/minus estimateRefineStep are kept.
+// Otherwise, or when SkewClamp is not set, angles are returned unchanged.
+func (c *Classifier) clampNearUpright(gray *image.Gray, angles []int) []int {
+	if c.opts.SkewClamp <= 0 {
+		return angles
+	}
+	estimate, confidence := estimateTextAngle(gray)
+	if confidence < minAngleEstimateConfidence || math.Abs(estimate) > float64(c.opts.SkewClamp) {
+		return angles
+	}
+
+	base := int(math.Round(estimate))
+	raw := []int{90, 180, 270, base - estimateRefineStep, base, base + estimateRefineStep}
+	seen := make(map[int]bool)
+	var pruned []int
+	for _, a := range raw {
+		n := ((a % 360) + 360) % 360
+		if n != 0 && !seen[n] {
+			seen[n] = true
+			pruned = append(pruned, n)
+		}
+	}
+	return pruned
+}
+
+// estimateDeskewAngle returns the rotation in degrees that straightens the text lines of
+// gray, as estimated by estimateTextAngle. It returns 0 when the estimate is not confident
+// enough or the skew exceeds deskewMaxAngle.
+func estimateDeskewAngle(gray *image.Gray) int {
+	angle, confidence := estimateTextAngle(gray)
+	if confidence < minAngleEstimateConfidence || math.Abs(angle) > deskewMaxAngle {
+		return 0
+	}
+	return ((int(math.Round(angle)) % 360) + 360) % 360
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"image"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEstimateTextAngle(t *testing.T) {
+	tests := []struct {
+		rotation int
+		// want is the rotation that straightens the text lines back
+		want int
+	}{
+		{rotation: 0, want: 0},
+		{rotation: 7, want: 353},
+		{rotation: 350, want: 10},
+		{rotation: 20, want: 340},
+	}
+	for _, tt := range tests {
+		gray := grayImage(rotateImage(textImage(400, 300), tt.rotation, RotationFillWhite))
+		angle, confidence := estimateTextAngle(gray)
+		if confidence < minAngleEstimateConfidence {
+			t.Errorf("rotation %d: confidence %v below %v", tt.rotation, confidence, minAngleEstimateConfidence)
+		}
+		if got := ((int(angle) % 360) + 360) % 360; got != tt.want {
+			t.Errorf("rotation %d: estimate %v, want %d", tt.rotation, angle, tt.want)
+		}
+	}
+}
+
+func TestEstimateTextAngleBlankImage(t *testing.T) {
+	blank := image.NewGray(image.Rect(0, 0, 400, 300))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	if _, confidence := estimateTextAngle(blank); confidence != 0 {
+		t.Errorf("confidence = %v, want 0 without ink", confidence)
+	}
+}
+
+// BenchmarkOrientation compares the phase 2 latency of the Hough-based sweep and the
+// projection-profile estimate on a skewed page. The engine never reports enough
+// confidence to stop early, so every candidate angle of the strategy is tried;
+// passes/op reports the OCR passes each strategy needs.
+func BenchmarkOrientation(b *testing.B) {
+	imageData := encodePNG(b, rotateImage(textImage(400, 300), 7, RotationFillWhite))
+	for _, strategy := range []string{OrientationSweep, OrientationEstimate} {
+		b.Run(strategy, func(b *testing.B) {
+			var calls atomic.Int64
+			engine := engineFunc(func([]byte, OCRParams) ([]RecognizedBox, error) {
+				calls.Add(1)
+				return []RecognizedBox{word("faint", 5, 5, 35)}, nil
+			})
+			c := newTestClassifier(Options{Orientation: strategy}, engine)
+			rule := c.DefaultDecisionRule()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.DetectText(imageData, rule); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(calls.Load())/float64(b.N), "passes/op")
+		})
+	}
+}
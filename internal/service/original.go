@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Coordinate spaces of the result boxes, selected with the coords query parameter.
+const (
+	// CoordsDefault keeps the boxes as returned by DetectText.
+	CoordsDefault = ""
+	// CoordsPreprocessed reports the boxes exactly as recognized by the OCR engine,
+	// in the scaled (and, for rotated results, rotated) preprocessed image.
+	CoordsPreprocessed = "preprocessed"
+)
+
+// ValidateCoords checks that space is a supported coordinate space name.
+func ValidateCoords(space string) error {
+	switch space {
+	case CoordsDefault, CoordsPreprocessed:
+		return nil
+	}
+	return fmt.Errorf("unsupported coords %q, supported: %s", space, CoordsPreprocessed)
+}
+
+// ToPreprocessedSpace moves the result boxes, text lines and blocks back to the coordinates
+// of the OCR input by undoing the ROI shift applied by DetectText. Combined with
+// ScaleFactor and Angle, they can be overlaid on the preprocessed image.
+// OriginalSpaceBoxes keeps working afterwards. Calling it again has no effect.
+func (r *ClassifierResult) ToPreprocessedSpace() {
+	if r.preprocessedSpace {
+		return
+	}
+	r.preprocessedSpace = true
+	dx, dy := r.roiOffset()
+	for i := range r.Boxes {
+		r.Boxes[i].X -= dx
+		r.Boxes[i].Y -= dy
+	}
+	for i := range r.TextLines {
+		r.TextLines[i].Box.X -= dx
+		r.TextLines[i].Box.Y -= dy
+	}
+	shiftBlocks(r.Blocks, -dx, -dy)
+}
+
+// OriginalSpaceBoxes returns copies of the result boxes mapped to pixel coordinates of
+// the original image: the rotation by Angle is inverted, the scaling is undone and,
+// for ROI requests, the boxes are shifted by the region origin.
+func (r *ClassifierResult) OriginalSpaceBoxes() []BoundingBox {
+	boxes := make([]BoundingBox, len(r.Boxes))
+	for i, box := range r.Boxes {
+		boxes[i] = r.toOriginalSpace(box)
+	}
+	return boxes
+}
+
+// OriginalSpacePage returns the recognized area in pixel coordinates of the original
+// image: the whole image, or the region of interest for ROI requests.
+func (r *ClassifierResult) OriginalSpacePage() image.Rectangle {
+	scaleX, scaleY := r.axisScales()
+	w := int(math.Round(float64(r.BoundingBoxWidth) / scaleX))
+	h := int(math.Round(float64(r.BoundingBoxHeight) / scaleY))
+	return image.Rect(0, 0, w, h).Add(r.roiOrigin)
+}
+
+// toOriginalSpace maps a result box to the original image.
+func (r *ClassifierResult) toOriginalSpace(box BoundingBox) BoundingBox {
+	// Boxes shifted by offsetBoxes refer to the full image; otherwise they refer to the
+	// recognized image, which lies at the region origin
+	w, h := r.BoundingBoxWidth, r.BoundingBoxHeight
+	offset := r.roiOrigin
+	if r.roiOrigin != (image.Point{}) && !r.preprocessedSpace {
+		w, h = r.fullScaledSize()
+		offset = image.Point{}
+	}
+	if r.Angle != 0 {
+		rotW, rotH := rotatedSize(w, h, r.Angle)
+		box = unrotateBox(box, r.Angle, rotW, rotH, w, h)
+	}
+
+	scaleX, scaleY := r.axisScales()
+	x0 := int(math.Round(float64(box.X) / scaleX))
+	y0 := int(math.Round(float64(box.Y) / scaleY))
+	x1 := int(math.Round(float64(box.X+box.Width) / scaleX))
+	y1 := int(math.Round(float64(box.Y+box.Height) / scaleY))
+	box.X, box.Y = x0+offset.X, y0+offset.Y
+	box.Width, box.Height = x1-x0, y1-y0
+	return box
+}
+
+// rotatedSize returns the dimensions of a w x h image after rotateImage by angleDeg,
+// matching the canvas size computed by imaging.Rotate.
+func rotatedSize(w, h, angleDeg int) (int, int) {
+	angleDeg = ((angleDeg % 360) + 360) % 360
+	switch angleDeg {
+	case 0, 180:
+		return w, h
+	case 90, 270:
+		return h, w
+	}
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+
+	sin, cos := math.Sincos(math.Pi * float64(angleDeg) / 180)
+	rotate := func(x, y float64) (float64, float64) {
+		return x*cos - y*sin, x*sin + y*cos
+	}
+	x1, y1 := rotate(float64(w-1), 0)
+	x2, y2 := rotate(float64(w-1), float64(h-1))
+	x3, y3 := rotate(0, float64(h-1))
+
+	minX := math.Min(x1, math.Min(x2, math.Min(x3, 0)))
+	maxX := math.Max(x1, math.Max(x2, math.Max(x3, 0)))
+	minY := math.Min(y1, math.Min(y2, math.Min(y3, 0)))
+	maxY := math.Max(y1, math.Max(y2, math.Max(y3, 0)))
+
+	newW := maxX - minX + 1
+	if newW-math.Floor(newW) > 0.1 {
+		newW++
+	}
+	newH := maxY - minY + 1
+	if newH-math.Floor(newH) > 0.1 {
+		newH++
+	}
+	return int(newW), int(newH)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestOddDimensionBoxesMapBack(t *testing.T) {
+	tests := []struct {
+		name string
+		w, h int
+		// tolerance is 1 when downscaling merges pixels, which cannot be mapped back exactly
+		tolerance int
+	}{
+		{name: "4x", w: 333, h: 211},
+		{name: "3x", w: 901, h: 777},
+		{name: "1.5x", w: 1201, h: 1001},
+		{name: "unscaled", w: 1777, h: 1333},
+		{name: "downscaled", w: 2501, h: 1501, tolerance: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newW, newH, scaleFactor := calculateScaleDimensions(tt.w, tt.h, tt.w*tt.h, DefaultScaleTiers())
+			result := &ClassifierResult{ScaleFactor: scaleFactor, BoundingBoxWidth: newW, BoundingBoxHeight: newH}
+			result.ScaleFactorX, result.ScaleFactorY = axisScaleFactors(tt.w, tt.h, newW, newH, scaleFactor)
+			scaleX, scaleY := result.axisScales()
+			if got := float64(newW) / scaleX; math.Abs(got-float64(tt.w)) > 1e-9 {
+				t.Fatalf("width %d maps back to %v, want %d", newW, got, tt.w)
+			}
+
+			// Boxes drawn around original pixels, including the last row and column
+			for _, orig := range []BoundingBox{
+				{X: 0, Y: 0, Width: 7, Height: 5},
+				{X: tt.w / 3, Y: tt.h / 2, Width: 41, Height: 13},
+				{X: tt.w - 9, Y: tt.h - 11, Width: 9, Height: 11},
+			} {
+				x0 := int(math.Round(float64(orig.X) * scaleX))
+				y0 := int(math.Round(float64(orig.Y) * scaleY))
+				x1 := int(math.Round(float64(orig.X+orig.Width) * scaleX))
+				y1 := int(math.Round(float64(orig.Y+orig.Height) * scaleY))
+				result.Boxes = []BoundingBox{{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}}
+
+				got := result.OriginalSpaceBoxes()[0]
+				if abs(got.X-orig.X) > tt.tolerance || abs(got.Y-orig.Y) > tt.tolerance ||
+					abs(got.X+got.Width-orig.X-orig.Width) > tt.tolerance || abs(got.Y+got.Height-orig.Y-orig.Height) > tt.tolerance {
+					t.Errorf("box %+v maps back to %+v (scale %v x %v)", orig, got, scaleX, scaleY)
+				}
+			}
+		})
+	}
+}
+
+// darkBounds returns the bounds of the pixels darker than mid-gray.
+func darkBounds(img image.Image) image.Rectangle {
+	var r image.Rectangle
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if g := color.GrayModel.Convert(img.At(x, y)).(color.Gray); g.Y < 128 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestRotatedBoxesRoundTrip(t *testing.T) {
+	const w, h = 121, 83
+	orig := BoundingBox{X: 23, Y: 17, Width: 30, Height: 12}
+	src := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range src.Pix {
+		src.Pix[i] = 255
+	}
+	for y := orig.Y; y < orig.Y+orig.Height; y++ {
+		for x := orig.X; x < orig.X+orig.Width; x++ {
+			src.SetGray(x, y, color.Gray{})
+		}
+	}
+
+	tests := []struct {
+		angle int
+		// exact is set for cardinal angles, whose rotation only moves whole pixels
+		exact bool
+	}{
+		{angle: 90, exact: true},
+		{angle: 180, exact: true},
+		{angle: 270, exact: true},
+		{angle: 5},
+		{angle: 45},
+		{angle: 137},
+		{angle: 355},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.angle), func(t *testing.T) {
+			rotated := rotateImage(src, tt.angle, RotationFillWhite)
+			if rw, rh := rotatedSize(w, h, tt.angle); rotated.Bounds().Dx() != rw || rotated.Bounds().Dy() != rh {
+				t.Fatalf("rotated to %v, rotatedSize = %dx%d", rotated.Bounds().Size(), rw, rh)
+			}
+			found := darkBounds(rotated)
+			result := &ClassifierResult{
+				Boxes:             []BoundingBox{{X: found.Min.X, Y: found.Min.Y, Width: found.Dx(), Height: found.Dy()}},
+				Angle:             tt.angle,
+				ScaleFactor:       1,
+				BoundingBoxWidth:  w,
+				BoundingBoxHeight: h,
+			}
+			got := result.OriginalSpaceBoxes()[0]
+
+			if tt.exact {
+				if got.X != orig.X || got.Y != orig.Y || got.Width != orig.Width || got.Height != orig.Height {
+					t.Errorf("box maps back to %+v, want %+v", got, orig)
+				}
+				return
+			}
+			// The axis-aligned box of a rotated box is larger, but it stays centered on
+			// the original box and contains it
+			gotCX, gotCY := float64(got.X)+float64(got.Width)/2, float64(got.Y)+float64(got.Height)/2
+			wantCX, wantCY := float64(orig.X)+float64(orig.Width)/2, float64(orig.Y)+float64(orig.Height)/2
+			if math.Abs(gotCX-wantCX) > 1.5 || math.Abs(gotCY-wantCY) > 1.5 {
+				t.Errorf("box %+v is centered at (%.1f,%.1f), want (%.1f,%.1f)", got, gotCX, gotCY, wantCX, wantCY)
+			}
+			if !orig.rect().Inset(1).In(got.rect()) {
+				t.Errorf("box %+v does not contain %+v", got, orig)
+			}
+		})
+	}
+}
+
+func TestUnrotateBoxInvertsCardinalRotation(t *testing.T) {
+	const w, h = 200, 120
+	box := BoundingBox{X: 10, Y: 30, Width: 50, Height: 20}
+	tests := []struct {
+		angle int
+		// rotated is box after rotating the w x h image counter-clockwise by angle
+		rotated BoundingBox
+	}{
+		{angle: 90, rotated: BoundingBox{X: 30, Y: w - 60, Width: 20, Height: 50}},
+		{angle: 180, rotated: BoundingBox{X: w - 60, Y: h - 50, Width: 50, Height: 20}},
+		{angle: 270, rotated: BoundingBox{X: h - 50, Y: 10, Width: 20, Height: 50}},
+	}
+	for _, tt := range tests {
+		rotW, rotH := rotatedSize(w, h, tt.angle)
+		if got := unrotateBox(tt.rotated, tt.angle, rotW, rotH, w, h); got != box {
+			t.Errorf("angle %d: unrotateBox = %+v, want %+v", tt.angle, got, box)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DefaultOSDMinConfidence is the OSD orientation confidence below which the rotation
+// search is run instead (see Options.OSDMinConfidence).
+const DefaultOSDMinConfidence = 2.0
+
+// Orientation sources, reported in ClassifierResult.OrientationSource when OSD is enabled.
+const (
+	// OrientationSourceOSD means the angle was taken from orientation and script detection.
+	OrientationSourceOSD = "osd"
+	// OrientationSourceSweep means OSD was unavailable or not confident and the angle
+	// comes from the rotation search.
+	OrientationSourceSweep = "sweep"
+)
+
+// OrientationDetector is implemented by OCR engines that can detect the page orientation
+// in a single pass, without recognizing the text.
+type OrientationDetector interface {
+	// DetectOrientation returns the counter-clockwise rotation (0, 90, 180 or 270 degrees)
+	// that makes the encoded image upright, and the engine's confidence in it.
+	DetectOrientation(imageData []byte, params OCRParams) (angle int, confidence float64, err error)
+}
+
+// OSDChecker is implemented by orientation detectors that depend on resources which may
+// be missing, such as an external program. CheckOSD reports why detection cannot run.
+type OSDChecker interface {
+	CheckOSD() error
+}
+
+// tesseractCommand is the Tesseract command line program used for OSD.
+const tesseractCommand = "tesseract"
+
+// CheckOSD checks that the tesseract program is installed and lists the osd language data.
+func (TesseractEngine) CheckOSD() error {
+	path, err := exec.LookPath(tesseractCommand)
+	if err != nil {
+		return fmt.Errorf("tesseract program not found: %w", err)
+	}
+	// Older versions print the list to stderr
+	out, err := exec.Command(path, "--list-langs").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to list tesseract languages: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "osd" {
+			return nil
+		}
+	}
+	return fmt.Errorf("osd.traineddata is not installed")
+}
+
+// CheckOSD checks once, at startup, that the engine can detect orientations if OSD is
+// enabled. If it cannot, OSD is disabled, so requests go straight to the rotation search
+// instead of failing OSD each time, and the reason is returned.
+func (c *Classifier) CheckOSD() error {
+	if !c.opts.OSD {
+		return nil
+	}
+	checker, ok := c.engine.(OSDChecker)
+	if !ok {
+		return nil
+	}
+	if err := checker.CheckOSD(); err != nil {
+		c.opts.OSD = false
+		return err
+	}
+	return nil
+}
+
+// DetectOrientation runs Tesseract orientation and script detection (page segmentation
+// mode 0), which needs the osd language data. gosseract does not expose the OSD results,
+// so the tesseract program is run on the image instead.
+func (TesseractEngine) DetectOrientation(imageData []byte, params OCRParams) (int, float64, error) {
+	ctx := params.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd := exec.CommandContext(ctx, tesseractCommand, "stdin", "stdout", "--psm", "0")
+	cmd.Stdin = bytes.NewReader(imageData)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to detect orientation: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseOSDOutput(out)
+}
+
+// parseOSDOutput reads the orientation and its confidence from the output of
+// tesseract --psm 0. "Orientation in degrees" is the clockwise rotation of the page,
+// which is undone by rotating it counter-clockwise by the same angle.
+func parseOSDOutput(out []byte) (angle int, confidence float64, err error) {
+	var haveAngle, haveConfidence bool
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(name) {
+		case "Orientation in degrees":
+			if angle, err = strconv.Atoi(value); err != nil {
+				return 0, 0, fmt.Errorf("invalid OSD orientation %q", value)
+			}
+			haveAngle = true
+		case "Orientation confidence":
+			if confidence, err = strconv.ParseFloat(value, 64); err != nil {
+				return 0, 0, fmt.Errorf("invalid OSD confidence %q", value)
+			}
+			haveConfidence = true
+		}
+	}
+	if !haveAngle || !haveConfidence {
+		return 0, 0, fmt.Errorf("no orientation in OSD output")
+	}
+	return ((angle % 360) + 360) % 360, confidence, nil
+}
+
+// detectWithOSD asks the engine for the page orientation and, if it is confident enough,
+// recognizes the image at that single angle. ok is false when the engine cannot detect
+// the orientation, is not confident or the pass fails: the rotation search is run then.
+// Detection and pass failures are logged.
+func (c *Classifier) detectWithOSD(preprocessed image.Image, scaleFactor float64, upright *ClassifierResult, rule DecisionRule, imgWidth, imgHeight int) (result *ClassifierResult, ok bool, err error) {
+	detector, isDetector := c.engine.(OrientationDetector)
+	if !isDetector {
+		return nil, false, nil
+	}
+
+	start := rule.Timing.start()
+	data, err := c.encodeIntermediate(preprocessed)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode preprocessed image: %w", err)
+	}
+	angle, confidence, err := detector.DetectOrientation(data, rule.OCRParams)
+	rule.Timing.record("osd", start)
+	minConfidence := c.opts.OSDMinConfidence
+	if minConfidence <= 0 {
+		minConfidence = DefaultOSDMinConfidence
+	}
+	if err != nil {
+		// The program was found by CheckOSD, so a failure is unexpected
+		log.Printf("OSD failed, falling back to the rotation search: %v", err)
+		return nil, false, nil
+	}
+	if confidence < minConfidence {
+		return nil, false, nil
+	}
+
+	if angle == 0 {
+		upright.IsTextDocument = EvaluateDecision(upright.WeightedConfidence, upright.TokenCount, rule)
+		upright.OrientationSource = OrientationSourceOSD
+		return upright, true, nil
+	}
+	result, _, err = c.trySingleRotation(preprocessed, scaleFactor, rule, angle, imgWidth, imgHeight)
+	if err != nil {
+		log.Printf("OCR at the OSD angle %d failed, falling back to the rotation search: %v", angle, err)
+		return nil, false, nil
+	}
+	result.SweepPasses = 1
+	result.OrientationSource = OrientationSourceOSD
+	return result, true, nil
+}
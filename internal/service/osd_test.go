@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestParseOSDOutput(t *testing.T) {
+	tests := []struct {
+		name           string
+		out            string
+		wantAngle      int
+		wantConfidence float64
+		wantErr        bool
+	}{
+		{
+			name:      "rotated page",
+			out:       "Page number: 0\nOrientation in degrees: 270\nRotate: 90\nOrientation confidence: 7.45\nScript: Latin\n",
+			wantAngle: 270, wantConfidence: 7.45,
+		},
+		{name: "upright page", out: "Orientation in degrees: 0\nOrientation confidence: 1.2\n", wantConfidence: 1.2},
+		{name: "missing confidence", out: "Orientation in degrees: 90\n", wantErr: true},
+		{name: "invalid angle", out: "Orientation in degrees: x\nOrientation confidence: 3\n", wantErr: true},
+		{name: "no output", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			angle, confidence, err := parseOSDOutput([]byte(tt.out))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parsed %d, %v, want an error", angle, confidence)
+				}
+				return
+			}
+			if err != nil || angle != tt.wantAngle || confidence != tt.wantConfidence {
+				t.Errorf("parseOSDOutput = %d, %v, %v; want %d, %v", angle, confidence, err, tt.wantAngle, tt.wantConfidence)
+			}
+		})
+	}
+}
+
+// osdEngine recognizes with a fixed word and detects the orientation with detect.
+type osdEngine struct {
+	engineFunc
+	detect func() (int, float64, error)
+}
+
+func (e osdEngine) DetectOrientation([]byte, OCRParams) (int, float64, error) {
+	return e.detect()
+}
+
+func TestDetectTextOSDFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		detect     func() (int, float64, error)
+		wantSource string
+		wantLog    bool
+	}{
+		{name: "confident", detect: func() (int, float64, error) { return 0, 5, nil }, wantSource: OrientationSourceOSD},
+		{name: "not confident", detect: func() (int, float64, error) { return 90, 0.5, nil }, wantSource: OrientationSourceSweep},
+		{
+			name: "tesseract missing",
+			detect: func() (int, float64, error) {
+				return 0, 0, errors.New(`exec: "tesseract": executable file not found in $PATH`)
+			},
+			wantSource: OrientationSourceSweep,
+			wantLog:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			defer log.SetOutput(log.Writer())
+			log.SetOutput(&logs)
+
+			// Too few tokens to skip phase 2
+			engine := osdEngine{engineFunc: staticEngine(word("faint", 5, 5, 35)), detect: tt.detect}
+			c := newTestClassifier(Options{OSD: true, Angles: []int{90, 180, 270}}, engine)
+
+			result, err := c.DetectText(encodePNG(t, textImage(48, 40)), c.DefaultDecisionRule())
+			if err != nil {
+				t.Fatalf("DetectText failed: %v", err)
+			}
+			if result.OrientationSource != tt.wantSource {
+				t.Errorf("orientation source %q, want %q", result.OrientationSource, tt.wantSource)
+			}
+			if got := strings.Contains(logs.String(), "OSD failed"); got != tt.wantLog {
+				t.Errorf("OSD failure logged: %v, want %v (log %q)", got, tt.wantLog, logs.String())
+			}
+		})
+	}
+}
+
+// checkedOSDEngine is an osdEngine whose startup check fails with err.
+type checkedOSDEngine struct {
+	osdEngine
+	err error
+}
+
+func (e checkedOSDEngine) CheckOSD() error { return e.err }
+
+func TestCheckOSD(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkErr   error
+		wantSource string
+	}{
+		{name: "available", wantSource: OrientationSourceOSD},
+		{name: "missing data", checkErr: errors.New("osd.traineddata is not installed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var detected bool
+			detect := func() (int, float64, error) {
+				detected = true
+				return 0, 5, nil
+			}
+			engine := checkedOSDEngine{osdEngine{engineFunc: staticEngine(word("faint", 5, 5, 35)), detect: detect}, tt.checkErr}
+			c := newTestClassifier(Options{OSD: true, Angles: []int{90, 180, 270}}, engine)
+
+			if err := c.CheckOSD(); err != tt.checkErr {
+				t.Fatalf("CheckOSD = %v, want %v", err, tt.checkErr)
+			}
+			result, err := c.DetectText(encodePNG(t, textImage(48, 40)), c.DefaultDecisionRule())
+			if err != nil {
+				t.Fatalf("DetectText failed: %v", err)
+			}
+			if detected != (tt.checkErr == nil) {
+				t.Errorf("OSD run: %v, want %v", detected, tt.checkErr == nil)
+			}
+			if result.OrientationSource != tt.wantSource {
+				t.Errorf("orientation source %q, want %q", result.OrientationSource, tt.wantSource)
+			}
+		})
+	}
+}
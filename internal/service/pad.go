@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+// ValidatePadSmallImages checks the minimum size small images are padded to:
+// 0 (padding disabled) or a size above the minimum dimension of preprocessing.
+func ValidatePadSmallImages(size int) error {
+	if size != 0 && size <= minDimension {
+		return fmt.Errorf("padding target %d must be 0 or greater than %d", size, minDimension)
+	}
+	return nil
+}
+
+// padSmallImage centers an image with a dimension at or below the minimum dimension
+// on a canvas of the background color, at least PadSmallImages pixels on each side,
+// so it is recognized instead of rejected as too small. It returns the image and
+// the position of its origin on the canvas; images that need no padding (or with
+// padding disabled) are returned as is with a zero offset.
+func (c *Classifier) padSmallImage(img image.Image) (image.Image, image.Point) {
+	size := c.opts.PadSmallImages
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if size <= minDimension || w == 0 || h == 0 || (w > minDimension && h > minDimension) {
+		return img, image.Point{}
+	}
+
+	padW, padH := max(w, size), max(h, size)
+	pad := image.Pt((padW-w)/2, (padH-h)/2)
+	canvas := imaging.New(padW, padH, borderColor(img))
+	return imaging.Paste(canvas, img, pad), pad
+}
+
+// borderColor returns the average color of the outermost pixels of img,
+// taken as its background color.
+func borderColor(img image.Image) color.NRGBA {
+	var r, g, bl, n uint64
+	forEachBorderPixel(img, func(c color.NRGBA) {
+		r += uint64(c.R)
+		g += uint64(c.G)
+		bl += uint64(c.B)
+		n++
+	})
+	return color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 255}
+}
+
+// forEachBorderPixel calls fn with the color of each outermost pixel of img, visiting
+// every pixel once: the top and bottom rows, then the left and right columns between them.
+func forEachBorderPixel(img image.Image, fn func(c color.NRGBA)) {
+	b := img.Bounds()
+	add := func(x, y int) {
+		fn(color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA))
+	}
+	for x := b.Min.X; x < b.Max.X; x++ {
+		add(x, b.Min.Y)
+		if b.Dy() > 1 {
+			add(x, b.Max.Y-1)
+		}
+	}
+	for y := b.Min.Y + 1; y < b.Max.Y-1; y++ {
+		add(b.Min.X, y)
+		if b.Dx() > 1 {
+			add(b.Max.X-1, y)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPadSmallImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		w, h     int
+		wantSize image.Point
+		wantPad  image.Point
+	}{
+		{name: "disabled", size: 0, w: 120, h: 24, wantSize: image.Pt(120, 24)},
+		{name: "short crop", size: 64, w: 120, h: 24, wantSize: image.Pt(120, 64), wantPad: image.Pt(0, 20)},
+		{name: "narrow crop", size: 64, w: 20, h: 100, wantSize: image.Pt(64, 100), wantPad: image.Pt(22, 0)},
+		{name: "tiny crop", size: 64, w: 10, h: 10, wantSize: image.Pt(64, 64), wantPad: image.Pt(27, 27)},
+		{name: "large enough", size: 64, w: 40, h: 40, wantSize: image.Pt(40, 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClassifier(Options{PadSmallImages: tt.size}, nil)
+			padded, pad := c.padSmallImage(image.NewGray(image.Rect(0, 0, tt.w, tt.h)))
+			if padded.Bounds().Size() != tt.wantSize || pad != tt.wantPad {
+				t.Errorf("padded to %v at %v, want %v at %v", padded.Bounds().Size(), pad, tt.wantSize, tt.wantPad)
+			}
+		})
+	}
+}
+
+func TestBorderColor(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.NRGBA{R: 240, G: 230, B: 200, A: 255})
+		}
+	}
+	// Ink inside the border does not change the background color
+	img.Set(5, 3, color.NRGBA{A: 255})
+	if got, want := borderColor(img), (color.NRGBA{R: 240, G: 230, B: 200, A: 255}); got != want {
+		t.Errorf("borderColor = %v, want %v", got, want)
+	}
+}
+
+func TestForEachBorderPixel(t *testing.T) {
+	tests := []struct {
+		w, h int
+		want int
+	}{
+		{w: 10, h: 6, want: 28},
+		{w: 1, h: 1, want: 1},
+		{w: 7, h: 1, want: 7},
+		{w: 1, h: 5, want: 5},
+		{w: 2, h: 2, want: 4},
+	}
+	for _, tt := range tests {
+		// Every pixel carries its own position, so that repeated visits are detected
+		img := image.NewNRGBA(image.Rect(3, 2, 3+tt.w, 2+tt.h))
+		for y := 0; y < tt.h; y++ {
+			for x := 0; x < tt.w; x++ {
+				img.Set(3+x, 2+y, color.NRGBA{R: uint8(x), G: uint8(y), A: 255})
+			}
+		}
+		seen := map[color.NRGBA]bool{}
+		forEachBorderPixel(img, func(c color.NRGBA) {
+			if seen[c] {
+				t.Errorf("%dx%d: pixel (%d,%d) visited twice", tt.w, tt.h, c.R, c.G)
+			}
+			if int(c.R) != 0 && int(c.R) != tt.w-1 && int(c.G) != 0 && int(c.G) != tt.h-1 {
+				t.Errorf("%dx%d: inner pixel (%d,%d) visited", tt.w, tt.h, c.R, c.G)
+			}
+			seen[c] = true
+		})
+		if len(seen) != tt.want {
+			t.Errorf("%dx%d: visited %d pixels, want %d", tt.w, tt.h, len(seen), tt.want)
+		}
+	}
+}
+
+// inkEngine recognizes one word around the dark pixels of the image, wherever they are.
+func inkEngine(imageData []byte, _ OCRParams) ([]RecognizedBox, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, err
+	}
+	ink := darkBounds(img)
+	if ink.Empty() {
+		return nil, nil
+	}
+	return []RecognizedBox{{Box: ink, Word: "total", Confidence: 90}}, nil
+}
+
+func TestDetectTextPadsSingleWordCrop(t *testing.T) {
+	// A 24 px tall crop with one word
+	crop := image.NewGray(image.Rect(0, 0, 120, 24))
+	for i := range crop.Pix {
+		crop.Pix[i] = 250
+	}
+	wordRect := image.Rect(20, 6, 90, 18)
+	for y := wordRect.Min.Y; y < wordRect.Max.Y; y++ {
+		for x := wordRect.Min.X; x < wordRect.Max.X; x++ {
+			crop.SetGray(x, y, color.Gray{Y: 10})
+		}
+	}
+	imageData := encodePNG(t, crop)
+
+	c := newTestClassifier(Options{SkipRotation: true}, engineFunc(inkEngine))
+	result, err := c.DetectText(imageData, c.DefaultDecisionRule())
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	if result.Status != StatusTooSmall {
+		t.Errorf("without padding: status %q, want %q", result.Status, StatusTooSmall)
+	}
+
+	c = newTestClassifier(Options{SkipRotation: true, PadSmallImages: 64}, engineFunc(inkEngine))
+	result, err = c.DetectText(imageData, c.DefaultDecisionRule())
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	if result.TokenCount != len("total") {
+		t.Fatalf("with padding: TokenCount = %d, want %d", result.TokenCount, len("total"))
+	}
+	// The box is in the coordinates of the crop, not of the padded canvas
+	got := result.OriginalSpaceBoxes()[0]
+	if abs(got.X-wordRect.Min.X) > 1 || abs(got.Y-wordRect.Min.Y) > 1 ||
+		abs(got.Width-wordRect.Dx()) > 2 || abs(got.Height-wordRect.Dy()) > 2 {
+		t.Errorf("box %+v, want %v", got, wordRect)
+	}
+}
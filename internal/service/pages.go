@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+)
+
+// pageSplitters maps image.Decode format names to functions returning the number of pages
+// in a document of that format and a function building page i as an image of that format.
+var pageSplitters = map[string]func(data []byte) (pages int, page func(i int) []byte, err error){}
+
+// RegisterPageSplitter sets the function splitting multi-page documents of the given
+// image.Decode format into pages, which are built on demand so that only one is held in
+// memory at a time. Like RegisterImageFormat, it is intended for init functions.
+func RegisterPageSplitter(format string, split func(data []byte) (pages int, page func(i int) []byte, err error)) {
+	pageSplitters[format] = split
+}
+
+// pageSet is a document split into pages, each built on demand as encoded image data.
+type pageSet struct {
+	count int
+	page  func(i int) ([]byte, error)
+	// close releases the document, if set.
+	close func()
+}
+
+// singlePage returns the pageSet of a single image.
+func singlePage(imageData []byte) *pageSet {
+	return &pageSet{count: 1, page: func(int) ([]byte, error) { return imageData, nil }}
+}
+
+// documentPages splits imageData into pages: PDF documents are rendered at PDFDPI when
+// PDF support is built in, other formats are split by their page splitter. Images whose
+// format has no page splitter, or that cannot be decoded, have one page. Like a failed
+// frame count, a failed split is not fatal: the first page is processed.
+func (c *Classifier) documentPages(imageData []byte) (*pageSet, error) {
+	if isPDF(imageData) && openPDF != nil {
+		return openPDF(imageData, c.pdfDPI())
+	}
+	_, format, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return singlePage(imageData), nil
+	}
+	split, ok := pageSplitters[format]
+	if !ok {
+		return singlePage(imageData), nil
+	}
+	count, page, err := split(imageData)
+	if err != nil {
+		return singlePage(imageData), nil
+	}
+	return &pageSet{count: count, page: func(i int) ([]byte, error) { return page(i), nil }}, nil
+}
+
+// DetectTextPages runs DetectText on every page of a multi-page document (a TIFF, or a
+// PDF when built with the pdf tag) and returns the results in page order, with Page set
+// to the 1-based page number and the content hash computed over the whole document.
+// A single-page image yields the DetectText result alone, without a page number; a
+// single-page PDF is numbered. It fails on the first failed page.
+func (c *Classifier) DetectTextPages(imageData []byte, rule DecisionRule) ([]*ClassifierResult, error) {
+	pages, err := c.documentPages(imageData)
+	if err != nil {
+		return nil, err
+	}
+	if pages.close != nil {
+		defer pages.close()
+	}
+	if pages.count == 1 && !isPDF(imageData) {
+		result, err := c.DetectText(imageData, rule)
+		if err != nil {
+			return nil, err
+		}
+		return []*ClassifierResult{result}, nil
+	}
+
+	var hash string
+	if c.opts.ContentHash != "" {
+		hash = contentHash(imageData, c.opts.ContentHash)
+	}
+	results := make([]*ClassifierResult, pages.count)
+	for i := range results {
+		if err := rule.canceled(); err != nil {
+			return nil, err
+		}
+		data, err := pages.page(i)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		pageRule := rule
+		if rule.Timing != nil {
+			// Each page reports its own stages
+			pageRule.Timing = NewTiming()
+		}
+		result, err := c.DetectText(data, pageRule)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		result.Page = i + 1
+		if hash != "" {
+			result.ContentHash = hash
+		}
+		results[i] = result
+	}
+	return results, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// DefaultPDFDPI is the resolution PDF pages are rendered at when none is configured.
+const DefaultPDFDPI = 300
+
+// maxPDFDPI bounds the rendering resolution: an A4 page at 600 DPI is already a 35 MP image.
+const maxPDFDPI = 600
+
+var (
+	// ErrEncryptedPDF is returned for password-protected PDF documents.
+	ErrEncryptedPDF = errors.New("PDF document is password-protected")
+	// ErrInvalidPDF is returned for PDF documents that cannot be opened or rendered.
+	ErrInvalidPDF = errors.New("invalid PDF document")
+)
+
+// openPDF renders the pages of a PDF document at the given resolution. It is set by the
+// PDF renderer built with the pdf tag; without it PDF documents are not accepted.
+var openPDF func(data []byte, dpi float64) (*pageSet, error)
+
+// ValidatePDFDPI checks that dpi is a usable page rendering resolution (0 means the default).
+func ValidatePDFDPI(dpi float64) error {
+	if dpi < 0 || dpi > maxPDFDPI {
+		return fmt.Errorf("PDF DPI must be between 0 and %d, got %g", maxPDFDPI, dpi)
+	}
+	return nil
+}
+
+// isPDF reports whether data starts with the PDF header.
+func isPDF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-"))
+}
+
+// pdfDPI returns the configured PDF rendering resolution, or DefaultPDFDPI.
+func (c *Classifier) pdfDPI() float64 {
+	if c.opts.PDFDPI <= 0 {
+		return DefaultPDFDPI
+	}
+	return c.opts.PDFDPI
+}
@@ -0,0 +1,55 @@
+//go:build pdf
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+func init() {
+	RegisterImageFormat("application/pdf", "pdf")
+	openPDF = openFitzPDF
+}
+
+// openFitzPDF opens a PDF document with MuPDF (requires cgo; the library is bundled with
+// go-fitz, use the musl tag on Alpine). Pages are rendered one at a time and encoded as
+// uncompressed PNG for DetectText. Pages without any content render as blank images.
+func openFitzPDF(data []byte, dpi float64) (*pageSet, error) {
+	doc, err := fitz.NewFromMemory(data)
+	if err != nil {
+		// The MuPDF context is allocated even when the document fails to open
+		if doc != nil {
+			doc.Close()
+		}
+		if errors.Is(err, fitz.ErrNeedsPassword) {
+			return nil, ErrEncryptedPDF
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	count := doc.NumPage()
+	if count == 0 {
+		doc.Close()
+		return nil, fmt.Errorf("%w: no pages", ErrInvalidPDF)
+	}
+
+	page := func(i int) ([]byte, error) {
+		img, err := doc.ImageDPI(i, dpi)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to render page: %v", ErrInvalidPDF, err)
+		}
+		if img.Bounds().Empty() {
+			// A page with an empty media box: hand DetectText a blank pixel rather than
+			// an image PNG cannot encode, so the page is reported as too small
+			blank := image.NewGray(image.Rect(0, 0, 1, 1))
+			blank.SetGray(0, 0, color.Gray{Y: 0xff})
+			return encodeImage(blank, "png-raw")
+		}
+		return encodeImage(img, "png-raw")
+	}
+	return &pageSet{count: count, page: page, close: func() { doc.Close() }}, nil
+}
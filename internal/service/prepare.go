@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"image"
+)
+
+// preparedImage is the decoded input of DetectText: the decoded image, cropped to the
+// ROI and padded, and optionally its preprocessed form.
+type preparedImage struct {
+	// source is the decoded image, img the region recognized and input img after
+	// small-image padding by pad.
+	source, img, input image.Image
+	pad                image.Point
+	// err is the decoding or cropping error.
+	err error
+	// preprocessed is set by PrepareImage.
+	preprocessed *preprocessedImage
+}
+
+// preprocessedImage is the output of preprocessImageStages for opts.
+type preprocessedImage struct {
+	opts PreprocessOptions
+	// img is nil if the image is too small to process.
+	img           image.Image
+	scaleFactor   float64
+	width, height int
+	threshold     *ThresholdInfo
+}
+
+// prepareInput decodes imageData and crops it to the ROI of rule.
+func (c *Classifier) prepareInput(imageData []byte, rule DecisionRule) *preparedImage {
+	start := rule.Timing.start()
+	img, err := c.decodeImage(imageData)
+	rule.Timing.record("decode", start)
+	if err != nil {
+		return &preparedImage{err: err}
+	}
+	p := &preparedImage{source: img, img: img}
+	if rule.ROI != nil {
+		if p.img, err = cropToROI(img, *rule.ROI); err != nil {
+			return &preparedImage{err: err}
+		}
+	}
+	p.input, p.pad = c.padSmallImage(p.img)
+	return p
+}
+
+// preprocessInput runs the preprocessing stages on img.
+func preprocessInput(img image.Image, opts PreprocessOptions, timing *Timing) *preprocessedImage {
+	start := timing.start()
+	preprocessed, scaleFactor, w, h, threshold := preprocessImageStages(img, opts, nil)
+	timing.record("preprocess", start)
+	return &preprocessedImage{opts: opts, img: preprocessed, scaleFactor: scaleFactor, width: w, height: h, threshold: threshold}
+}
+
+// PrepareImage runs the CPU-bound stages of DetectText ahead of recognition: it
+// decodes imageData, crops it to the ROI and preprocesses it as the first pass of rule
+// would. The returned rule carries the prepared image: DetectText and DetectTextPages
+// called with it and the same imageData skip those stages, so that a batch prepares
+// the next images while the OCR clients are busy. Multi-page formats and PDF documents
+// are returned unprepared, their pages are prepared as they are recognized.
+func (c *Classifier) PrepareImage(imageData []byte, rule DecisionRule) DecisionRule {
+	if isPDF(imageData) {
+		return rule
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(imageData)); err == nil {
+		if _, ok := pageSplitters[format]; ok {
+			return rule
+		}
+	}
+
+	p := c.prepareInput(imageData, rule)
+	if p.err == nil {
+		// The chain starts with its first profile unless the request sets its own
+		optsRule := rule
+		if rule.Preprocess == nil && len(c.opts.PreprocessChain) > 0 {
+			optsRule.Preprocess = &c.opts.PreprocessChain[0].Options
+		}
+		p.preprocessed = preprocessInput(p.input, c.preprocessOptions(optsRule), rule.Timing)
+	}
+	rule.prepared = p
+	return rule
+}
+
+// preprocessedWith returns the prepared preprocessed image if it was produced with opts,
+// or nil. It is nil-safe, like Timing.
+func (p *preparedImage) preprocessedWith(opts PreprocessOptions) *preprocessedImage {
+	if p == nil || p.preprocessed == nil || p.preprocessed.opts != opts {
+		return nil
+	}
+	return p.preprocessed
+}
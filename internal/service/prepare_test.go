@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"sync"
+	"testing"
+)
+
+// recordingEngine recognizes the same word in every image and records the images.
+type recordingEngine struct {
+	mu     sync.Mutex
+	images [][]byte
+}
+
+func (e *recordingEngine) Recognize(imageData []byte, _ OCRParams) ([]RecognizedBox, error) {
+	e.mu.Lock()
+	e.images = append(e.images, imageData)
+	e.mu.Unlock()
+	return []RecognizedBox{word("invoice", 4, 4, 90)}, nil
+}
+
+func TestPrepareImageMatchesDetectText(t *testing.T) {
+	chain, err := ParsePreprocessChain(PresetScan + "," + PresetPhoto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roi := image.Rect(8, 6, 40, 30)
+
+	tests := []struct {
+		name string
+		opts Options
+		rule func(*DecisionRule)
+		// wantPreprocessed is set when PrepareImage preprocesses the image
+		wantPreprocessed bool
+	}{
+		{name: "default", wantPreprocessed: true},
+		{name: "rotation sweep", opts: Options{Angles: []int{90, 180, 270}}, wantPreprocessed: true},
+		{name: "roi", rule: func(r *DecisionRule) { r.ROI = &roi }, wantPreprocessed: true},
+		{name: "request preprocessing", rule: func(r *DecisionRule) { r.Preprocess = &PreprocessOptions{Off: true} }, wantPreprocessed: true},
+		{name: "chain", opts: Options{PreprocessChain: chain}, wantPreprocessed: true},
+		{name: "invalid roi", rule: func(r *DecisionRule) { r.ROI = &image.Rectangle{Max: image.Pt(100, 10)} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageData := encodePNG(t, textImage(48, 40))
+			detect := func(prepare bool) (*ClassifierResult, [][]byte, error) {
+				engine := &recordingEngine{}
+				opts := tt.opts
+				opts.SkipRotation = len(opts.Angles) == 0
+				c := newTestClassifier(opts, engine)
+				rule := c.DefaultDecisionRule()
+				if tt.rule != nil {
+					tt.rule(&rule)
+				}
+				if prepare {
+					rule = c.PrepareImage(imageData, rule)
+					if got := rule.prepared != nil && rule.prepared.preprocessed != nil; got != tt.wantPreprocessed {
+						t.Fatalf("preprocessed = %v, want %v", got, tt.wantPreprocessed)
+					}
+				}
+				result, err := c.DetectText(imageData, rule)
+				return result, engine.images, err
+			}
+
+			want, wantImages, wantErr := detect(false)
+			got, gotImages, gotErr := detect(true)
+			if (gotErr == nil) != (wantErr == nil) {
+				t.Fatalf("error = %v, want %v", gotErr, wantErr)
+			}
+			if wantErr != nil {
+				return
+			}
+			if len(gotImages) != len(wantImages) {
+				t.Fatalf("engine called %d times, want %d", len(gotImages), len(wantImages))
+			}
+			for i := range wantImages {
+				if !bytes.Equal(gotImages[i], wantImages[i]) {
+					t.Errorf("pass %d recognized a different image", i)
+				}
+			}
+			if got.TokenCount != want.TokenCount || got.Angle != want.Angle || got.ScaleFactor != want.ScaleFactor ||
+				got.PreprocessProfile != want.PreprocessProfile || len(got.Boxes) != len(want.Boxes) {
+				t.Fatalf("prepared result %+v, want %+v", got, want)
+			}
+			for i := range want.Boxes {
+				if got.Boxes[i] != want.Boxes[i] {
+					t.Errorf("box %d = %+v, want %+v", i, got.Boxes[i], want.Boxes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareImageSkipsDocuments(t *testing.T) {
+	c := newTestClassifier(Options{}, &recordingEngine{})
+	if rule := c.PrepareImage([]byte("%PDF-1.7\n"), c.DefaultDecisionRule()); rule.prepared != nil {
+		t.Errorf("PDF document was prepared")
+	}
+}
+
+func TestPreprocessedWith(t *testing.T) {
+	opts := PreprocessOptions{Equalize: true}
+	prepared := &preparedImage{preprocessed: &preprocessedImage{opts: opts}}
+
+	if prepared.preprocessedWith(opts) == nil {
+		t.Errorf("image preprocessed with the same parameters not reused")
+	}
+	if prepared.preprocessedWith(PreprocessOptions{}) != nil {
+		t.Errorf("image preprocessed with other parameters reused")
+	}
+	var unprepared *preparedImage
+	if unprepared.preprocessedWith(opts) != nil {
+		t.Errorf("nil prepared image returned a preprocessed one")
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Preprocessing preset names.
+const (
+	// PresetClean suits screenshots and born-digital images: no noise reduction,
+	// so thin glyph strokes are kept intact.
+	PresetClean = "clean"
+	// PresetScan suits flatbed scans: median blur against speckle noise. This is the
+	// pipeline used before presets were introduced.
+	PresetScan = "scan"
+	// PresetPhoto suits camera photos: median blur, histogram equalization against
+	// uneven lighting and a lower white threshold to wash out the shaded background.
+	PresetPhoto = "photo"
+	// PresetOff disables preprocessing: OCR and the rotation search run on the decoded
+	// image at its original scale and colors.
+	PresetOff = "off"
+)
+
+// DefaultPreprocessPreset is the preset used when none is configured.
+const DefaultPreprocessPreset = PresetScan
+
+// preprocessPresets maps preset names to their preprocessing parameters.
+var preprocessPresets = map[string]PreprocessOptions{
+	PresetClean: {NoMedianBlur: true},
+	PresetScan:  {},
+	PresetPhoto: {Equalize: true, WhiteThreshold: 192},
+	PresetOff:   {Off: true},
+}
+
+// PreprocessPreset returns the preprocessing parameters of the named preset.
+func PreprocessPreset(name string) (PreprocessOptions, error) {
+	opts, ok := preprocessPresets[name]
+	if !ok {
+		return PreprocessOptions{}, fmt.Errorf("unsupported preprocess preset %q, expected one of: %s",
+			name, strings.Join(PreprocessPresetNames(), ", "))
+	}
+	return opts, nil
+}
+
+// ValidatePreprocessPreset checks that name refers to a defined preset.
+func ValidatePreprocessPreset(name string) error {
+	_, err := PreprocessPreset(name)
+	return err
+}
+
+// PreprocessPresetNames returns the names of all defined presets, sorted.
+func PreprocessPresetNames() []string {
+	names := make([]string, 0, len(preprocessPresets))
+	for name := range preprocessPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
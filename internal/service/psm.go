@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/otiai10/gosseract/v2"
+)
+
+// Page segmentation modes selecting how Tesseract splits the image into text, named
+// after Tesseract's PSM values (given in parentheses).
+const (
+	// PSMAuto segments the page fully automatically, without orientation detection (3).
+	PSMAuto = "auto"
+	// PSMSingleColumn assumes a single column of text of variable sizes (4).
+	PSMSingleColumn = "single_column"
+	// PSMSingleBlock assumes a single uniform block of text (6).
+	PSMSingleBlock = "single_block"
+	// PSMSingleLine treats the image as a single text line, e.g. a label or a plate (7).
+	PSMSingleLine = "single_line"
+	// PSMSingleWord treats the image as a single word (8).
+	PSMSingleWord = "single_word"
+	// PSMSingleChar treats the image as a single character (10).
+	PSMSingleChar = "single_char"
+	// PSMSparseText finds as much text as possible in no particular order (11).
+	PSMSparseText = "sparse_text"
+	// PSMRawLine treats the image as a single text line, bypassing Tesseract-specific hacks (13).
+	PSMRawLine = "raw_line"
+)
+
+// psmValues maps page segmentation mode names to gosseract values.
+var psmValues = map[string]gosseract.PageSegMode{
+	PSMAuto:         gosseract.PSM_AUTO,
+	PSMSingleColumn: gosseract.PSM_SINGLE_COLUMN,
+	PSMSingleBlock:  gosseract.PSM_SINGLE_BLOCK,
+	PSMSingleLine:   gosseract.PSM_SINGLE_LINE,
+	PSMSingleWord:   gosseract.PSM_SINGLE_WORD,
+	PSMSingleChar:   gosseract.PSM_SINGLE_CHAR,
+	PSMSparseText:   gosseract.PSM_SPARSE_TEXT,
+	PSMRawLine:      gosseract.PSM_RAW_LINE,
+}
+
+// ValidatePSM checks that mode is a supported page segmentation mode name. Empty means
+// Tesseract's default.
+func ValidatePSM(mode string) error {
+	if _, ok := psmValues[mode]; !ok && mode != "" {
+		return fmt.Errorf("unsupported page segmentation mode %q, supported: %s", mode, strings.Join(psmNames(), ", "))
+	}
+	return nil
+}
+
+// psmNames returns the sorted page segmentation mode names.
+func psmNames() []string {
+	names := make([]string, 0, len(psmValues))
+	for name := range psmValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
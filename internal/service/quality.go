@@ -0,0 +1,136 @@
+package service
+
+import (
+	"image"
+	"math"
+)
+
+// Quality warnings reported in ClassifierResult.QualityWarnings.
+const (
+	WarningLowDPI         = "image below recommended DPI"
+	WarningJPEGBlockiness = "heavily JPEG-compressed (blockiness detected)"
+	WarningLowContrast    = "very low contrast"
+)
+
+const (
+	// assumedPageWidthInches is the page width (A4 portrait) used to estimate DPI from pixels.
+	assumedPageWidthInches = 8.27
+	// minRecommendedDPI is the estimated resolution below which WarningLowDPI is reported.
+	minRecommendedDPI = 150
+	// jpegBlockSize is the JPEG DCT block size.
+	jpegBlockSize = 8
+	// maxBlockinessRatio is the ratio of luminance jumps across block boundaries to jumps
+	// inside blocks above which WarningJPEGBlockiness is reported.
+	maxBlockinessRatio = 1.5
+	// minContrastRange is the difference between the mean background and mean ink
+	// luminance below which WarningLowContrast is reported.
+	minContrastRange = 64
+	// qualitySampleStep is the row stride used when sampling the image.
+	qualitySampleStep = 4
+)
+
+// assessImageQuality returns warnings about conditions that typically degrade OCR:
+// low estimated resolution, JPEG block artifacts (checked only for JPEG input, since
+// other formats have no block grid) and low contrast between ink and background.
+// The checks are cheap heuristics computed on sampled rows of the original image.
+func assessImageQuality(img image.Image, jpeg bool) []string {
+	var warnings []string
+
+	bounds := img.Bounds()
+	shortSide := min(bounds.Dx(), bounds.Dy())
+	if float64(shortSide)/assumedPageWidthInches < minRecommendedDPI {
+		warnings = append(warnings, WarningLowDPI)
+	}
+
+	var hist [256]int
+	var boundaryDiff, innerDiff float64
+	var boundaryCount, innerCount int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += qualitySampleStep {
+		prev := -1
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			lum := luminance(img, x, y)
+			hist[lum]++
+			if jpeg && prev >= 0 {
+				diff := math.Abs(float64(lum - prev))
+				if (x-bounds.Min.X)%jpegBlockSize == 0 {
+					boundaryDiff += diff
+					boundaryCount++
+				} else {
+					innerDiff += diff
+					innerCount++
+				}
+			}
+			prev = lum
+		}
+	}
+
+	if boundaryCount > 0 && innerCount > 0 {
+		boundaryMean := boundaryDiff / float64(boundaryCount)
+		innerMean := innerDiff / float64(innerCount)
+		if boundaryMean > maxBlockinessRatio*innerMean {
+			warnings = append(warnings, WarningJPEGBlockiness)
+		}
+	}
+
+	if ink, background := otsuClassMeans(hist); background-ink < minContrastRange {
+		warnings = append(warnings, WarningLowContrast)
+	}
+
+	return warnings
+}
+
+// luminance returns the 8-bit luminance of the pixel at (x, y).
+func luminance(img image.Image, x, y int) int {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return int((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+}
+
+// otsuClassMeans splits the luminance histogram at the Otsu threshold, which maximizes
+// the variance between the two classes, and returns the mean luminance of the dark
+// (ink) and light (background) class. Unlike a percentile range of the whole image,
+// this measures the contrast of the ink even when it covers a tiny part of a sparse
+// page. An image of a single luminance has equal means.
+func otsuClassMeans(hist [256]int) (ink, background float64) {
+	var total, sum float64
+	for value, n := range hist {
+		total += float64(n)
+		sum += float64(value * n)
+	}
+	if total == 0 {
+		return 0, 0
+	}
+
+	var darkCount, darkSum, bestVariance float64
+	ink, background = sum/total, sum/total
+	for value, n := range hist[:255] {
+		darkCount += float64(n)
+		darkSum += float64(value * n)
+		lightCount := total - darkCount
+		if darkCount == 0 || lightCount == 0 {
+			continue
+		}
+		darkMean, lightMean := darkSum/darkCount, (sum-darkSum)/lightCount
+		if variance := darkCount * lightCount * (lightMean - darkMean) * (lightMean - darkMean); variance > bestVariance {
+			bestVariance = variance
+			ink, background = darkMean, lightMean
+		}
+	}
+	return ink, background
+}
+
+// histogramPercentile returns the luminance value below which fraction p of the samples lie.
+func histogramPercentile(hist [256]int, p float64) int {
+	total := 0
+	for _, n := range hist {
+		total += n
+	}
+	target := int(math.Ceil(p * float64(total)))
+	cum := 0
+	for value, n := range hist {
+		cum += n
+		if cum >= target {
+			return value
+		}
+	}
+	return 255
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+)
+
+// pageImage returns a w x h page of the paper luminance with a rectangle of ink.
+func pageImage(w, h int, paper, ink uint8, inkRect image.Rectangle) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = paper
+	}
+	for y := inkRect.Min.Y; y < inkRect.Max.Y; y++ {
+		for x := inkRect.Min.X; x < inkRect.Max.X; x++ {
+			img.SetGray(x, y, color.Gray{Y: ink})
+		}
+	}
+	return img
+}
+
+// blockyImage returns an image of flat 8x8 blocks of alternating luminance, the
+// artifact of heavy JPEG compression.
+func blockyImage(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Pix[img.PixOffset(x, y)] = uint8(120 + 60*((x/8+y/8)%2))
+		}
+	}
+	return img
+}
+
+func TestAssessImageQuality(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		jpeg bool
+		want []string
+		not  []string
+	}{
+		{
+			name: "sparse page",
+			img:  pageImage(200, 160, 250, 15, image.Rect(90, 70, 110, 78)),
+			not:  []string{WarningLowContrast},
+		},
+		{
+			name: "dense page",
+			img:  textImage(200, 160),
+			not:  []string{WarningLowContrast},
+		},
+		{
+			name: "faint ink",
+			img:  pageImage(200, 160, 200, 170, image.Rect(20, 20, 180, 140)),
+			want: []string{WarningLowContrast},
+		},
+		{
+			name: "blank page",
+			img:  pageImage(200, 160, 255, 255, image.Rectangle{}),
+			want: []string{WarningLowContrast},
+		},
+		{name: "blocky JPEG", img: blockyImage(200, 160), jpeg: true, want: []string{WarningJPEGBlockiness}},
+		{name: "blocky PNG", img: blockyImage(200, 160), not: []string{WarningJPEGBlockiness}},
+		{name: "small image", img: textImage(200, 160), want: []string{WarningLowDPI}},
+		{name: "A4 at 200 DPI", img: textImage(1654, 2339), not: []string{WarningLowDPI}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := assessImageQuality(tt.img, tt.jpeg)
+			for _, w := range tt.want {
+				if !slices.Contains(warnings, w) {
+					t.Errorf("warnings = %v, want %q", warnings, w)
+				}
+			}
+			for _, w := range tt.not {
+				if slices.Contains(warnings, w) {
+					t.Errorf("warnings = %v, want no %q", warnings, w)
+				}
+			}
+		})
+	}
+}
+
+func TestOtsuClassMeans(t *testing.T) {
+	var hist [256]int
+	hist[20] = 10
+	hist[240] = 900
+	hist[250] = 90
+	ink, background := otsuClassMeans(hist)
+	if wantBackground := (240*900 + 250*90) / 990.0; ink != 20 || math.Abs(background-wantBackground) > 1e-9 {
+		t.Errorf("means = %v, %v, want 20, %v", ink, background, wantBackground)
+	}
+
+	var flat [256]int
+	flat[128] = 50
+	if ink, background := otsuClassMeans(flat); ink != background {
+		t.Errorf("single level: means = %v, %v, want equal", ink, background)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidRegion is returned when an output filter region is malformed.
+var ErrInvalidRegion = errors.New("invalid region")
+
+// regionEpsilon tolerates rounding in region bounds such as "0.7,0,0.3,1".
+const regionEpsilon = 1e-9
+
+// Region is a rectangle in coordinates normalized to the recognized image: (0, 0) is
+// its top-left and (1, 1) its bottom-right corner, in the orientation of the result.
+type Region struct {
+	X, Y, Width, Height float64
+}
+
+// ParseRegion parses a normalized region in the "x,y,w,h" form (e.g. "0,0,1,0.2" for the
+// top 20% of the image). The region must lie within [0, 1] on both axes.
+func ParseRegion(s string) (Region, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return Region{}, fmt.Errorf("%w: expected x,y,w,h", ErrInvalidRegion)
+	}
+
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return Region{}, fmt.Errorf("%w: %q is not a number", ErrInvalidRegion, part)
+		}
+		values[i] = v
+	}
+
+	region := Region{X: values[0], Y: values[1], Width: values[2], Height: values[3]}
+	if region.X < 0 || region.Y < 0 || region.Width <= 0 || region.Height <= 0 ||
+		region.X+region.Width > 1+regionEpsilon || region.Y+region.Height > 1+regionEpsilon {
+		return Region{}, fmt.Errorf("%w: must lie within 0..1 and have a positive size", ErrInvalidRegion)
+	}
+	return region, nil
+}
+
+// contains reports whether the normalized point (x, y) lies within the region.
+func (g Region) contains(x, y float64) bool {
+	return x >= g.X && x <= g.X+g.Width && y >= g.Y && y <= g.Y+g.Height
+}
+
+// FilterRegion keeps only the boxes (with their OriginalBoxes) and text lines whose centers
+// fall within region, relative to the recognized image (the ROI, if set) in the orientation
+// of the result, and rebuilds Text from the kept boxes. Aggregates (confidences, token
+// count, decision) still describe the whole image. Results without a known image size
+// are left unchanged.
+func (r *ClassifierResult) FilterRegion(region Region) {
+	frameW, frameH := rotatedSize(r.BoundingBoxWidth, r.BoundingBoxHeight, r.Angle)
+	if frameW <= 0 || frameH <= 0 {
+		return
+	}
+	dx, dy := 0, 0
+	if !r.preprocessedSpace {
+		// Boxes were shifted by offsetBoxes to full-image coordinates
+		dx, dy = r.roiOffset()
+	}
+	inside := func(box BoundingBox) bool {
+		x := (float64(box.X-dx) + float64(box.Width)/2) / float64(frameW)
+		y := (float64(box.Y-dy) + float64(box.Height)/2) / float64(frameH)
+		return region.contains(x, y)
+	}
+
+	boxes := make([]BoundingBox, 0, len(r.Boxes))
+	var originalBoxes []BoundingBox
+	if r.OriginalBoxes != nil {
+		originalBoxes = make([]BoundingBox, 0, len(r.OriginalBoxes))
+	}
+	for i, box := range r.Boxes {
+		if inside(box) {
+			boxes = append(boxes, box)
+			if originalBoxes != nil {
+				originalBoxes = append(originalBoxes, r.OriginalBoxes[i])
+			}
+		}
+	}
+	r.Boxes = boxes
+	r.OriginalBoxes = originalBoxes
+	r.Text = RecognizedText(boxes, TextFormatLines)
+
+	if r.TextLines != nil {
+		lines := make([]TextLine, 0, len(r.TextLines))
+		for _, line := range r.TextLines {
+			if inside(line.Box) {
+				lines = append(lines, line)
+			}
+		}
+		r.TextLines = lines
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestParseRegion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Region
+		wantErr bool
+	}{
+		{input: "0,0,1,0.2", want: Region{X: 0, Y: 0, Width: 1, Height: 0.2}},
+		{input: " 0.5, 0.5 ,0.5,0.5", want: Region{X: 0.5, Y: 0.5, Width: 0.5, Height: 0.5}},
+		{input: "0,0,1", wantErr: true},
+		{input: "0,0,x,1", wantErr: true},
+		{input: "-0.1,0,1,1", wantErr: true},
+		{input: "0,0,0,1", wantErr: true},
+		{input: "0.5,0,0.6,1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRegion(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidRegion) {
+				t.Errorf("ParseRegion(%q) error = %v, want ErrInvalidRegion", tt.input, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRegion(%q) = %+v, %v, want %+v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestFilterRegion(t *testing.T) {
+	// A 200x100 page with a header word in the top 20% and two body words below
+	header := BoundingBox{X: 10, Y: 5, Width: 60, Height: 10, Word: "invoice"}
+	body := []BoundingBox{
+		{X: 10, Y: 30, Width: 60, Height: 10, Word: "item"},
+		{X: 10, Y: 80, Width: 60, Height: 10, Word: "total"},
+	}
+	top := Region{X: 0, Y: 0, Width: 1, Height: 0.2}
+
+	tests := []struct {
+		name  string
+		angle int
+		boxes []BoundingBox
+		want  []string
+	}{
+		{name: "upright", boxes: append([]BoundingBox{header}, body...), want: []string{"invoice"}},
+		// At 90 degrees the recognized frame is 100x200, so the top 20% reaches y=40
+		{name: "rotated", angle: 90, boxes: append([]BoundingBox{header}, body...), want: []string{"invoice", "item"}},
+		{name: "nothing inside", boxes: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &ClassifierResult{
+				Boxes:              tt.boxes,
+				OriginalBoxes:      append([]BoundingBox(nil), tt.boxes...),
+				Angle:              tt.angle,
+				BoundingBoxWidth:   200,
+				BoundingBoxHeight:  100,
+				WeightedConfidence: 0.8,
+				TokenCount:         16,
+			}
+			result.FilterRegion(top)
+
+			if len(result.Boxes) != len(tt.want) || len(result.OriginalBoxes) != len(tt.want) {
+				t.Fatalf("kept %d boxes and %d original boxes, want %d", len(result.Boxes), len(result.OriginalBoxes), len(tt.want))
+			}
+			for i, w := range tt.want {
+				if result.Boxes[i].Word != w || result.OriginalBoxes[i].Word != w {
+					t.Errorf("box %d = %q, want %q", i, result.Boxes[i].Word, w)
+				}
+			}
+			// The aggregates still describe the whole image
+			if result.WeightedConfidence != 0.8 || result.TokenCount != 16 {
+				t.Errorf("aggregates changed to %v, %d", result.WeightedConfidence, result.TokenCount)
+			}
+		})
+	}
+}
+
+func TestFilterRegionWithinROI(t *testing.T) {
+	// The region is relative to the ROI, while the boxes were shifted to the full image
+	c := newTestClassifier(Options{SkipRotation: true}, staticEngine(word("invoice", 4, 2, 95), word("total", 4, 60, 95)))
+	roi := image.Rect(40, 40, 120, 100)
+	rule := c.DefaultDecisionRule()
+	rule.ROI = &roi
+
+	result, err := c.DetectText(encodePNG(t, textImage(200, 160)), rule)
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	result.FilterRegion(Region{X: 0, Y: 0, Width: 1, Height: 0.2})
+	if len(result.Boxes) != 1 || result.Boxes[0].Word != "invoice" {
+		t.Errorf("boxes = %+v, want only invoice", result.Boxes)
+	}
+}
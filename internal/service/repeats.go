@@ -0,0 +1,78 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+)
+
+// WarningRepeatedTokens is reported when repeated identical words were discounted from
+// the aggregates as likely background-pattern noise.
+const WarningRepeatedTokens = "repeated identical words discounted as noise"
+
+// countedBoxes returns the boxes that count towards the token count and confidence
+// aggregates. With RepeatThreshold set, low-variation words (see lowVariationWord)
+// occurring at least RepeatThreshold times are left out as OCR noise from textured
+// backgrounds; the boxes themselves stay in the result. The second value reports
+// whether any box was discounted.
+func (c *Classifier) countedBoxes(boxes []BoundingBox) ([]BoundingBox, bool) {
+	threshold := c.opts.RepeatThreshold
+	if threshold <= 0 || len(boxes) < threshold {
+		return boxes, false
+	}
+
+	counts := make(map[string]int)
+	for _, box := range boxes {
+		if word := strings.ToLower(box.Word); lowVariationWord(word) {
+			counts[word]++
+		}
+	}
+	noisy := false
+	for _, n := range counts {
+		if n >= threshold {
+			noisy = true
+			break
+		}
+	}
+	if !noisy {
+		return boxes, false
+	}
+
+	counted := make([]BoundingBox, 0, len(boxes))
+	for _, box := range boxes {
+		if counts[strings.ToLower(box.Word)] < threshold {
+			counted = append(counted, box)
+		}
+	}
+	return counted, true
+}
+
+// lowVariationWord reports whether word looks like pattern noise rather than text:
+// a character repeated ("ii", "---", "ооо"), or no letters or digits at all ("|", "-.").
+// Single letters and short words are real text too often ("и", "a", "to") to be
+// treated as noise.
+func lowVariationWord(word string) bool {
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return false
+	}
+	repeated, alphanumeric := len(runes) > 1, false
+	for _, r := range runes {
+		if r != runes[0] {
+			repeated = false
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			alphanumeric = true
+		}
+	}
+	return repeated || !alphanumeric
+}
+
+// addWarning appends warning to the result warnings unless it is already there.
+func addWarning(result *ClassifierResult, warning string) {
+	for _, w := range result.Warnings {
+		if w == warning {
+			return
+		}
+	}
+	result.Warnings = append(result.Warnings, warning)
+}
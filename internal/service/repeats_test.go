@@ -0,0 +1,88 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestLowVariationWord(t *testing.T) {
+	tests := []struct {
+		word string
+		want bool
+	}{
+		{word: "ii", want: true},
+		{word: "---", want: true},
+		{word: "ооо", want: true},
+		{word: "|", want: true},
+		{word: "-.", want: true},
+		{word: "и"},
+		{word: "a"},
+		{word: "to"},
+		{word: "invoice"},
+		{word: "11.5"},
+		{word: ""},
+	}
+	for _, tt := range tests {
+		if got := lowVariationWord(tt.word); got != tt.want {
+			t.Errorf("lowVariationWord(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+// texturedImage returns a page with a fine diagonal hatching over its lower half, the
+// kind of background pattern Tesseract reads as rows of short repeated "words".
+func texturedImage(w, h int) *image.Gray {
+	img := textImage(w, h)
+	for y := h / 2; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x+y)%4 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 90})
+			}
+		}
+	}
+	return img
+}
+
+func TestRepeatedTokensDiscounted(t *testing.T) {
+	// The text above the pattern, then a dozen "ii" read from the hatching
+	boxes := []RecognizedBox{word("invoice", 10, 5, 92), word("total", 10, 30, 90)}
+	for i := 0; i < 12; i++ {
+		boxes = append(boxes, word("ii", 10+25*(i%4), 70+22*(i/4), 45))
+	}
+	imageData := encodePNG(t, texturedImage(80, 72))
+
+	tests := []struct {
+		name        string
+		threshold   int
+		wantTokens  int
+		wantWarning bool
+	}{
+		{name: "disabled", threshold: 0, wantTokens: 7 + 5 + 12*2},
+		{name: "repeats above threshold", threshold: 5, wantTokens: 7 + 5, wantWarning: true},
+		{name: "repeats below threshold", threshold: 20, wantTokens: 7 + 5 + 12*2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClassifier(Options{RepeatThreshold: tt.threshold, SkipRotation: true}, staticEngine(boxes...))
+			result, err := c.DetectText(imageData, c.DefaultDecisionRule())
+			if err != nil {
+				t.Fatalf("DetectText failed: %v", err)
+			}
+			if result.TokenCount != tt.wantTokens {
+				t.Errorf("TokenCount = %d, want %d", result.TokenCount, tt.wantTokens)
+			}
+			if got := slices.Contains(result.Warnings, WarningRepeatedTokens); got != tt.wantWarning {
+				t.Errorf("warnings = %v, want %q: %v", result.Warnings, WarningRepeatedTokens, tt.wantWarning)
+			}
+			// Discounted boxes stay in the result
+			if len(result.Boxes) != len(boxes) {
+				t.Errorf("got %d boxes, want %d", len(result.Boxes), len(boxes))
+			}
+			if tt.wantWarning && result.WeightedConfidence < 0.9 {
+				t.Errorf("WeightedConfidence = %v, want the noise left out", result.WeightedConfidence)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// ErrInvalidROI is returned when a region of interest is malformed or lies outside the image.
+var ErrInvalidROI = errors.New("invalid region of interest")
+
+// ParseROI parses a region of interest in the "x,y,w,h" form.
+func ParseROI(s string) (image.Rectangle, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("%w: expected x,y,w,h", ErrInvalidROI)
+	}
+
+	var values [4]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("%w: %q is not an integer", ErrInvalidROI, part)
+		}
+		values[i] = v
+	}
+
+	x, y, w, h := values[0], values[1], values[2], values[3]
+	if x < 0 || y < 0 || w <= 0 || h <= 0 {
+		return image.Rectangle{}, fmt.Errorf("%w: origin must be non-negative and size positive", ErrInvalidROI)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// cropToROI crops img to roi, given relative to the image origin.
+// Returns ErrInvalidROI if roi does not lie within the image bounds.
+func cropToROI(img image.Image, roi image.Rectangle) (image.Image, error) {
+	bounds := img.Bounds()
+	abs := roi.Add(bounds.Min)
+	if !abs.In(bounds) {
+		return nil, fmt.Errorf("%w: %dx%d at (%d,%d) exceeds image size %dx%d",
+			ErrInvalidROI, roi.Dx(), roi.Dy(), roi.Min.X, roi.Min.Y, bounds.Dx(), bounds.Dy())
+	}
+	return imaging.Crop(img, abs), nil
+}
+
+// offsetBoxes shifts the result boxes from the recognized image to the full image of
+// the given size (in original pixels), in which the recognized image lies at origin (the
+// ROI origin less any small-image padding). The boxes keep the scale and orientation of
+// the result: they refer to the full image scaled by the scale factors and rotated by
+// Angle. The origin and size are recorded for OriginalSpaceBoxes.
+func offsetBoxes(result *ClassifierResult, origin, size image.Point) {
+	result.roiOrigin = origin
+	result.fullSize = size
+	dx, dy := result.roiOffset()
+	for i := range result.Boxes {
+		result.Boxes[i].X += dx
+		result.Boxes[i].Y += dy
+	}
+	for i := range result.TextLines {
+		result.TextLines[i].Box.X += dx
+		result.TextLines[i].Box.Y += dy
+	}
+	shiftBlocks(result.Blocks, dx, dy)
+}
+
+// roiOffset returns the shift of the boxes from the recognized image to the full image,
+// both scaled and rotated by Angle. Rotation moves every point of the recognized image
+// by the same amount as its center, so the shift is that of the center.
+func (r *ClassifierResult) roiOffset() (int, int) {
+	scaleX, scaleY := r.axisScales()
+	ox, oy := float64(r.roiOrigin.X)*scaleX, float64(r.roiOrigin.Y)*scaleY
+	if r.Angle == 0 || r.fullSize == (image.Point{}) {
+		return int(math.Round(ox)), int(math.Round(oy))
+	}
+
+	w, h := r.BoundingBoxWidth, r.BoundingBoxHeight
+	fullW, fullH := r.fullScaledSize()
+	rotW, rotH := rotatedSize(w, h, r.Angle)
+	fullRotW, fullRotH := rotatedSize(fullW, fullH, r.Angle)
+	// The center of the recognized image relative to the center of the full image,
+	// rotated counter-clockwise like the image (the inverse of unrotateBox)
+	vx := ox + float64(w)/2 - float64(fullW)/2
+	vy := oy + float64(h)/2 - float64(fullH)/2
+	sinT, cosT := math.Sincos(float64(r.Angle) * math.Pi / 180)
+	x := float64(fullRotW)/2 + vx*cosT + vy*sinT
+	y := float64(fullRotH)/2 - vx*sinT + vy*cosT
+	return int(math.Round(x - float64(rotW)/2)), int(math.Round(y - float64(rotH)/2))
+}
+
+// fullScaledSize returns the size of the full image in the scale of the result.
+func (r *ClassifierResult) fullScaledSize() (int, int) {
+	scaleX, scaleY := r.axisScales()
+	return int(math.Round(float64(r.fullSize.X) * scaleX)), int(math.Round(float64(r.fullSize.Y) * scaleY))
+}
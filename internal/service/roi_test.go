@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestParseROI(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    image.Rectangle
+		wantErr bool
+	}{
+		{name: "valid", input: "10,20,30,40", want: image.Rect(10, 20, 40, 60)},
+		{name: "spaces", input: " 1, 2, 3, 4", want: image.Rect(1, 2, 4, 6)},
+		{name: "too few values", input: "1,2,3", wantErr: true},
+		{name: "not an integer", input: "1,2,x,4", wantErr: true},
+		{name: "negative origin", input: "-1,0,10,10", wantErr: true},
+		{name: "zero size", input: "0,0,0,10", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseROI(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidROI) {
+					t.Fatalf("error = %v, want ErrInvalidROI", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseROI(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCropToROIOutOfBounds(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 50))
+	if _, err := cropToROI(img, image.Rect(80, 0, 120, 10)); !errors.Is(err, ErrInvalidROI) {
+		t.Errorf("error = %v, want ErrInvalidROI", err)
+	}
+}
+
+// roiResult returns a result with one box, recognized in a 100x80 region scaled by 2
+// and rotated by angle.
+func roiResult(angle int, box BoundingBox) *ClassifierResult {
+	const scale = 2
+	w, h := 100, 80
+	return &ClassifierResult{
+		Boxes:             []BoundingBox{box},
+		Angle:             angle,
+		ScaleFactor:       scale,
+		BoundingBoxWidth:  w * scale,
+		BoundingBoxHeight: h * scale,
+	}
+}
+
+func TestOffsetBoxesCardinalAngles(t *testing.T) {
+	// Region 100x80 at (40,30) of a 300x200 image, scaled by 2: in the scaled space the
+	// region is 200x160 at (80,60) of a 600x400 image
+	origin, size := image.Pt(40, 30), image.Pt(300, 200)
+	const w, h, fullW, fullH, ox, oy = 200, 160, 600, 400, 80, 60
+
+	tests := []struct {
+		angle          int
+		wantDX, wantDY int
+	}{
+		{angle: 0, wantDX: ox, wantDY: oy},
+		{angle: 90, wantDX: oy, wantDY: fullW - w - ox},
+		{angle: 180, wantDX: fullW - w - ox, wantDY: fullH - h - oy},
+		{angle: 270, wantDX: fullH - h - oy, wantDY: ox},
+	}
+	for _, tt := range tests {
+		box := BoundingBox{X: 10, Y: 20, Width: 30, Height: 8}
+		result := roiResult(tt.angle, box)
+		offsetBoxes(result, origin, size)
+
+		got := result.Boxes[0]
+		if got.X != box.X+tt.wantDX || got.Y != box.Y+tt.wantDY || got.Width != box.Width || got.Height != box.Height {
+			t.Errorf("angle %d: box = %+v, want shift by (%d,%d)", tt.angle, got, tt.wantDX, tt.wantDY)
+		}
+	}
+}
+
+func TestOffsetBoxesKeepsOriginalSpace(t *testing.T) {
+	origin, size := image.Pt(40, 30), image.Pt(300, 200)
+	for _, angle := range []int{0, 90, 180, 270, 7, 353, 45} {
+		box := BoundingBox{X: 60, Y: 50, Width: 40, Height: 12}
+
+		// The same box before the shift, in the recognized image at the region origin
+		unshifted := roiResult(angle, box)
+		unshifted.roiOrigin = origin
+		unshifted.preprocessedSpace = true
+		want := unshifted.OriginalSpaceBoxes()[0]
+
+		shifted := roiResult(angle, box)
+		offsetBoxes(shifted, origin, size)
+		got := shifted.OriginalSpaceBoxes()[0]
+
+		if abs(got.X-want.X) > 1 || abs(got.Y-want.Y) > 1 || abs(got.Width-want.Width) > 1 || abs(got.Height-want.Height) > 1 {
+			t.Errorf("angle %d: original box = %+v, want %+v", angle, got, want)
+		}
+
+		// Undoing the shift restores the boxes of the recognized image
+		shifted.ToPreprocessedSpace()
+		if shifted.Boxes[0] != box {
+			t.Errorf("angle %d: preprocessed box = %+v, want %+v", angle, shifted.Boxes[0], box)
+		}
+	}
+}
+
+func TestDetectTextROIBoxesInFullImage(t *testing.T) {
+	// The engine finds a word at the same place of whatever it is given
+	c := newTestClassifier(Options{SkipRotation: true}, staticEngine(word("invoice", 8, 6, 95)))
+	roi := image.Rect(50, 40, 150, 100)
+	rule := c.DefaultDecisionRule()
+	rule.ROI = &roi
+
+	result, err := c.DetectText(encodePNG(t, textImage(240, 160)), rule)
+	if err != nil {
+		t.Fatalf("DetectText failed: %v", err)
+	}
+	if len(result.Boxes) != 1 {
+		t.Fatalf("got %d boxes, want 1", len(result.Boxes))
+	}
+
+	scaleX, scaleY := result.axisScales()
+	box := result.Boxes[0]
+	wantX := 8 + int(float64(roi.Min.X)*scaleX+0.5)
+	wantY := 6 + int(float64(roi.Min.Y)*scaleY+0.5)
+	if box.X != wantX || box.Y != wantY {
+		t.Errorf("box at (%d,%d), want (%d,%d)", box.X, box.Y, wantX, wantY)
+	}
+
+	original := result.OriginalSpaceBoxes()[0]
+	if original.X < roi.Min.X || original.Y < roi.Min.Y || !image.Pt(original.X, original.Y).In(roi) {
+		t.Errorf("original box %+v lies outside the ROI %v", original, roi)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
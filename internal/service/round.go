@@ -0,0 +1,86 @@
+package service
+
+import "math"
+
+// RoundConfidences returns a copy of result with aggregate, box, original box, line, block and number confidences
+// rounded to the given number of decimals, for output. The original result keeps full precision.
+// A negative precision returns result unchanged.
+func RoundConfidences(result *ClassifierResult, precision int) *ClassifierResult {
+	if result == nil || precision < 0 {
+		return result
+	}
+
+	rounded := *result
+	rounded.MeanConfidence = roundTo(result.MeanConfidence, precision)
+	rounded.WeightedConfidence = roundTo(result.WeightedConfidence, precision)
+	rounded.UprightConfidence = roundTo(result.UprightConfidence, precision)
+
+	if result.Boxes != nil {
+		rounded.Boxes = make([]BoundingBox, len(result.Boxes))
+		for i, box := range result.Boxes {
+			box.Confidence = roundTo(box.Confidence, precision)
+			rounded.Boxes[i] = box
+		}
+	}
+	if result.OriginalBoxes != nil {
+		rounded.OriginalBoxes = make([]BoundingBox, len(result.OriginalBoxes))
+		for i, box := range result.OriginalBoxes {
+			box.Confidence = roundTo(box.Confidence, precision)
+			rounded.OriginalBoxes[i] = box
+		}
+	}
+	if result.TextLines != nil {
+		rounded.TextLines = make([]TextLine, len(result.TextLines))
+		for i, line := range result.TextLines {
+			line.Confidence = roundTo(line.Confidence, precision)
+			line.Box.Confidence = roundTo(line.Box.Confidence, precision)
+			rounded.TextLines[i] = line
+		}
+	}
+	if result.Blocks != nil {
+		rounded.Blocks = roundBlocks(result.Blocks, precision)
+	}
+	if result.Numbers != nil {
+		rounded.Numbers = make([]NumberCandidate, len(result.Numbers))
+		for i, number := range result.Numbers {
+			number.Confidence = roundTo(number.Confidence, precision)
+			number.Box.Confidence = roundTo(number.Box.Confidence, precision)
+			rounded.Numbers[i] = number
+		}
+	}
+	return &rounded
+}
+
+// roundBlocks returns a deep copy of blocks with all box confidences rounded.
+func roundBlocks(blocks []TextBlock, precision int) []TextBlock {
+	rounded := make([]TextBlock, len(blocks))
+	for i, block := range blocks {
+		block.Box.Confidence = roundTo(block.Box.Confidence, precision)
+		paragraphs := make([]TextParagraph, len(block.Paragraphs))
+		for j, paragraph := range block.Paragraphs {
+			paragraph.Box.Confidence = roundTo(paragraph.Box.Confidence, precision)
+			lines := make([]BlockLine, len(paragraph.Lines))
+			for k, line := range paragraph.Lines {
+				line.Box.Confidence = roundTo(line.Box.Confidence, precision)
+				words := make([]BoundingBox, len(line.Words))
+				for w, word := range line.Words {
+					word.Confidence = roundTo(word.Confidence, precision)
+					words[w] = word
+				}
+				line.Words = words
+				lines[k] = line
+			}
+			paragraph.Lines = lines
+			paragraphs[j] = paragraph
+		}
+		block.Paragraphs = paragraphs
+		rounded[i] = block
+	}
+	return rounded
+}
+
+// roundTo rounds value to the given number of decimals.
+func roundTo(value float64, precision int) float64 {
+	scale := math.Pow(10, float64(precision))
+	return math.Round(value*scale) / scale
+}
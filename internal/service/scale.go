@@ -0,0 +1,80 @@
+package service
+
+import (
+	"image"
+	"strconv"
+)
+
+// multiScaleStep is the ratio between the dynamic scale factor and the extra scale
+// factors tried by Options.MultiScale.
+const multiScaleStep = 1.5
+
+// maxMultiScalePixels caps the size of the images recognized at an extra scale factor,
+// so the higher factor of an already enlarged image does not exhaust memory.
+const maxMultiScalePixels = 2 * threeMegapixels
+
+// ScaleScore is the outcome of recognition at one scale factor.
+type ScaleScore struct {
+	ScaleFactor        float64 `json:"scale_factor"`
+	WeightedConfidence float64 `json:"weighted_confidence"`
+	TokenCount         int     `json:"token_count"`
+}
+
+// tryScales recognizes img again at the winning angle of result, preprocessed with one
+// scale factor multiScaleStep times lower and one higher than result's. It returns the
+// result with the highest weighted confidence, result itself on ties, along with the
+// preprocessed image of the winner if another scale won. Failed passes are skipped.
+// The returned result lists the scores of every scale factor tried.
+func (c *Classifier) tryScales(img image.Image, result *ClassifierResult, rule DecisionRule) (*ClassifierResult, image.Image) {
+	bounds := img.Bounds()
+	pixels := float64(bounds.Dx() * bounds.Dy())
+	scores := []ScaleScore{scaleScore(result)}
+	best := result
+	// The passes are timed as a whole, not per rotation stage
+	scaleRule := rule
+	scaleRule.Timing = nil
+	var bestImage image.Image
+	for _, factor := range []float64{result.ScaleFactor / multiScaleStep, result.ScaleFactor * multiScaleStep} {
+		if pixels*factor*factor > maxMultiScalePixels {
+			continue
+		}
+		start := rule.Timing.start()
+		opts := c.preprocessOptions(rule)
+		opts.Scale = factor
+		preprocessed, scaleFactor, imgWidth, imgHeight, threshold := preprocessImageStages(img, opts, nil)
+		if preprocessed == nil {
+			continue
+		}
+		res, _, err := c.trySingleRotation(preprocessed, scaleFactor, scaleRule, result.Angle, imgWidth, imgHeight)
+		rule.Timing.record("scale_"+strconv.FormatFloat(factor, 'g', 4, 64), start)
+		if err != nil {
+			continue
+		}
+		if c.opts.ReportThreshold {
+			res.Threshold = threshold
+		}
+		scores = append(scores, scaleScore(res))
+		if res.WeightedConfidence > best.WeightedConfidence {
+			best, bestImage = res, preprocessed
+		}
+	}
+
+	if best != result {
+		best.RawAngle = result.RawAngle
+		best.SweepPasses = result.SweepPasses
+		best.Phase2Gate = result.Phase2Gate
+		best.OrientationSource = result.OrientationSource
+		best.Warnings = result.Warnings
+	}
+	best.ScaleScores = scores
+	return best, bestImage
+}
+
+// scaleScore returns the ScaleScore of result.
+func scaleScore(result *ClassifierResult) ScaleScore {
+	return ScaleScore{
+		ScaleFactor:        result.ScaleFactor,
+		WeightedConfidence: result.WeightedConfidence,
+		TokenCount:         result.TokenCount,
+	}
+}
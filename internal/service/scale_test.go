@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+)
+
+// smallFontEngine stands in for Tesseract on small print: recognition improves as the
+// image it is given is enlarged, up to a 650 px width.
+func smallFontEngine(imageData []byte, _ OCRParams) ([]RecognizedBox, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, err
+	}
+	confidence := math.Min(95, 30+float64(cfg.Width)/10)
+	return []RecognizedBox{word("invoice", 40, 40, confidence), word("total", 40, 100, confidence)}, nil
+}
+
+func TestMultiScale(t *testing.T) {
+	imageData := encodePNG(t, textImage(100, 75))
+
+	tests := []struct {
+		multiScale bool
+		wantScores int
+	}{
+		{multiScale: false},
+		{multiScale: true, wantScores: 3},
+	}
+	for _, tt := range tests {
+		c := newTestClassifier(Options{MultiScale: tt.multiScale, SkipRotation: true}, engineFunc(smallFontEngine))
+		result, err := c.DetectText(imageData, c.DefaultDecisionRule())
+		if err != nil {
+			t.Fatalf("MultiScale %v: DetectText failed: %v", tt.multiScale, err)
+		}
+		if len(result.ScaleScores) != tt.wantScores {
+			t.Fatalf("MultiScale %v: %d scale scores, want %d", tt.multiScale, len(result.ScaleScores), tt.wantScores)
+		}
+		if !tt.multiScale {
+			continue
+		}
+
+		// The dynamic factor comes first, then the lower and the higher one
+		dynamic := result.ScaleScores[0].ScaleFactor
+		if lower := result.ScaleScores[1].ScaleFactor; math.Abs(lower-dynamic/multiScaleStep) > 1e-9 {
+			t.Errorf("lower scale factor %v, want %v", lower, dynamic/multiScaleStep)
+		}
+		higher := result.ScaleScores[2]
+		if result.ScaleFactor != higher.ScaleFactor || result.WeightedConfidence != higher.WeightedConfidence {
+			t.Errorf("winner at scale %v with %v, want the higher scale %v with %v",
+				result.ScaleFactor, result.WeightedConfidence, higher.ScaleFactor, higher.WeightedConfidence)
+		}
+		if higher.WeightedConfidence <= result.ScaleScores[0].WeightedConfidence {
+			t.Errorf("higher scale scored %v, not above the dynamic %v", higher.WeightedConfidence, result.ScaleScores[0].WeightedConfidence)
+		}
+		// Boxes of the winner map back with its scale factor
+		if got := result.OriginalSpaceBoxes()[0]; got.X != int(math.Round(40/result.ScaleFactor)) {
+			t.Errorf("original box %+v, want X = 40 / %v", got, result.ScaleFactor)
+		}
+	}
+}
+
+// BenchmarkMultiScale measures the cost of the extra scale passes on a small-font page,
+// reporting the weighted confidence reached with and without them.
+func BenchmarkMultiScale(b *testing.B) {
+	imageData := encodePNG(b, textImage(100, 75))
+	for _, multiScale := range []bool{false, true} {
+		name := "single"
+		if multiScale {
+			name = "multi"
+		}
+		b.Run(name, func(b *testing.B) {
+			c := newTestClassifier(Options{MultiScale: multiScale, SkipRotation: true}, engineFunc(smallFontEngine))
+			rule := c.DefaultDecisionRule()
+			var confidence float64
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result, err := c.DetectText(imageData, rule)
+				if err != nil {
+					b.Fatal(err)
+				}
+				confidence = result.WeightedConfidence
+			}
+			b.ReportMetric(confidence, "confidence")
+		})
+	}
+}
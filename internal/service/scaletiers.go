@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ScaleTier scales images with fewer than MaxPixels pixels by Factor.
+type ScaleTier struct {
+	MaxPixels int
+	Factor    float64
+}
+
+// ScaleTiers is the size-based scale curve of preprocessing. An image is scaled by the
+// Factor of the first tier it is below; an image past every tier is not scaled up to
+// MaxPixels inclusive, and larger images are scaled down to MaxPixels.
+type ScaleTiers struct {
+	// Tiers are ordered by ascending MaxPixels.
+	Tiers []ScaleTier
+	// MaxPixels is the largest image size recognized without downscaling.
+	MaxPixels int
+}
+
+// DefaultScaleTiers returns the default scale curve: 4x below 0.5 MP, 3x below 1 MP,
+// 1.5x below 2 MP, unscaled up to 3 MP and scaled down to 3 MP above.
+func DefaultScaleTiers() ScaleTiers {
+	return ScaleTiers{
+		Tiers: []ScaleTier{
+			{MaxPixels: halfMegapixel, Factor: 4},
+			{MaxPixels: oneMegapixel, Factor: 3},
+			{MaxPixels: twoMegapixels, Factor: 1.5},
+		},
+		MaxPixels: threeMegapixels,
+	}
+}
+
+// ParseScaleTiers parses a comma-separated list of "megapixels:factor" tiers, e.g.
+// "0.5:4,1:3,2:1.5", and the size in megapixels (1 MP = 1,048,576 pixels) above which
+// images are scaled down. An empty list or zero size yields the default one.
+func ParseScaleTiers(tiers string, maxMegapixels float64) (ScaleTiers, error) {
+	defaults := DefaultScaleTiers()
+	result := ScaleTiers{Tiers: defaults.Tiers, MaxPixels: defaults.MaxPixels}
+	if maxMegapixels < 0 {
+		return ScaleTiers{}, fmt.Errorf("invalid scale tiers: max megapixels %g is negative", maxMegapixels)
+	}
+	if maxMegapixels > 0 {
+		result.MaxPixels = megapixelsToPixels(maxMegapixels)
+	}
+
+	if strings.TrimSpace(tiers) != "" {
+		result.Tiers = nil
+		for _, part := range strings.Split(tiers, ",") {
+			size, factor, ok := strings.Cut(strings.TrimSpace(part), ":")
+			if !ok {
+				return ScaleTiers{}, fmt.Errorf("invalid scale tiers: %q is not megapixels:factor", part)
+			}
+			mp, err := strconv.ParseFloat(size, 64)
+			if err != nil || mp <= 0 {
+				return ScaleTiers{}, fmt.Errorf("invalid scale tiers: %q is not a positive number of megapixels", size)
+			}
+			f, err := strconv.ParseFloat(factor, 64)
+			if err != nil || f <= 0 {
+				return ScaleTiers{}, fmt.Errorf("invalid scale tiers: %q is not a positive scale factor", factor)
+			}
+			tier := ScaleTier{MaxPixels: megapixelsToPixels(mp), Factor: f}
+			if n := len(result.Tiers); n > 0 && tier.MaxPixels <= result.Tiers[n-1].MaxPixels {
+				return ScaleTiers{}, fmt.Errorf("invalid scale tiers: sizes must be ascending, %q is not", part)
+			}
+			result.Tiers = append(result.Tiers, tier)
+		}
+	}
+
+	if n := len(result.Tiers); n > 0 && result.Tiers[n-1].MaxPixels > result.MaxPixels {
+		return ScaleTiers{}, fmt.Errorf("invalid scale tiers: tier size %d exceeds max pixels %d", result.Tiers[n-1].MaxPixels, result.MaxPixels)
+	}
+	return result, nil
+}
+
+// megapixelsToPixels converts a size in megapixels to pixels.
+func megapixelsToPixels(mp float64) int {
+	return int(math.Round(mp * oneMegapixel))
+}
+
+// dimensions returns the target dimensions and scale factor of a w x h image of the
+// given number of pixels; ScaleTiers without MaxPixels is treated as DefaultScaleTiers.
+func (t ScaleTiers) dimensions(w, h, pixels int) (newW, newH int, scaleFactor float64) {
+	if t.MaxPixels == 0 {
+		t = DefaultScaleTiers()
+	}
+
+	scaleFactor = 1.0
+	tiered := false
+	for _, tier := range t.Tiers {
+		if pixels < tier.MaxPixels {
+			scaleFactor, tiered = tier.Factor, true
+			break
+		}
+	}
+	if !tiered && pixels > t.MaxPixels {
+		// Past every tier and above the maximum: scale down to MaxPixels
+		scaleFactor = math.Sqrt(float64(t.MaxPixels) / float64(pixels))
+	}
+	if scaleFactor == 1.0 {
+		return w, h, scaleFactor
+	}
+	// Fractional dimensions are rounded to the nearest pixel, halves up
+	newW = max(int(math.Round(float64(w)*scaleFactor)), 1)
+	newH = max(int(math.Round(float64(h)*scaleFactor)), 1)
+	return newW, newH, scaleFactor
+}
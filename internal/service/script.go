@@ -0,0 +1,90 @@
+package service
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// ScriptLanguage is the language parameter value that detects the language from the
+// scripts of a first recognition pass instead of running every language.
+const ScriptLanguage = "script"
+
+// DefaultScriptDominance is the share of letters a script must reach to select its language.
+const DefaultScriptDominance = 0.6
+
+// scriptLanguages maps scripts to the language recognized for text dominated by them.
+var scriptLanguages = []struct {
+	script   *unicode.RangeTable
+	language string
+}{
+	{unicode.Cyrillic, "rus"},
+	{unicode.Latin, "eng"},
+}
+
+// detectTextScript recognizes the image with the default language, which must be able to
+// output all scripts of scriptLanguages (e.g. "eng+rus"), and counts the letters of each
+// script in the result. If one script holds at least ScriptDominance of them and its
+// language differs from the default one, the image is recognized again with that language.
+// Otherwise the first result is kept. The result reports the language used.
+func (c *Classifier) detectTextScript(imageData []byte, rule DecisionRule) (*ClassifierResult, error) {
+	probeRule := rule
+	probeRule.Language = c.opts.DefaultLanguage
+	result, err := c.detectText(imageData, probeRule)
+	if err != nil {
+		return nil, err
+	}
+	result.Language = probeRule.Language
+
+	lang := dominantScriptLanguage(result.Boxes, c.scriptDominance())
+	if lang == "" || lang == probeRule.Language {
+		return result, nil
+	}
+
+	langRule := rule
+	langRule.Language = lang
+	if rule.Timing != nil {
+		// The timings of the probe pass are replaced by those of the final pass
+		langRule.Timing = NewTiming()
+	}
+	final, err := c.detectText(imageData, langRule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect text for language %s: %w", lang, err)
+	}
+	final.Language = lang
+	return final, nil
+}
+
+// dominantScriptLanguage returns the language of the script holding at least dominance
+// of the letters in boxes, or an empty string if no script dominates.
+func dominantScriptLanguage(boxes []BoundingBox, dominance float64) string {
+	counts := make([]int, len(scriptLanguages))
+	total := 0
+	for _, box := range boxes {
+		for _, r := range box.Word {
+			for i, sl := range scriptLanguages {
+				if unicode.Is(sl.script, r) {
+					counts[i]++
+					total++
+					break
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	for i, count := range counts {
+		if float64(count) >= dominance*float64(total) {
+			return scriptLanguages[i].language
+		}
+	}
+	return ""
+}
+
+// scriptDominance returns the configured script dominance threshold, or the default one.
+func (c *Classifier) scriptDominance() float64 {
+	if c.opts.ScriptDominance <= 0 || c.opts.ScriptDominance > 1 {
+		return DefaultScriptDominance
+	}
+	return c.opts.ScriptDominance
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResultSink archives classified images together with their results.
+type ResultSink interface {
+	// Store persists the image and its classification result.
+	Store(imageData []byte, result *ClassifierResult) error
+}
+
+// NopSink is a ResultSink that discards everything.
+type NopSink struct{}
+
+// Store does nothing.
+func (NopSink) Store([]byte, *ClassifierResult) error { return nil }
+
+// FileSink is a ResultSink writing each image and its JSON result to a directory,
+// partitioned by date: <dir>/YYYY/MM/DD/<time>-<seq>.<ext> and .json next to it.
+type FileSink struct {
+	dir string
+	seq atomic.Uint64
+}
+
+// NewFileSink creates a FileSink rooted at dir.
+func NewFileSink(dir string) *FileSink {
+	return &FileSink{dir: dir}
+}
+
+// NewResultSink returns a FileSink rooted at dir, or a NopSink if dir is empty.
+func NewResultSink(dir string) ResultSink {
+	if dir == "" {
+		return NopSink{}
+	}
+	return NewFileSink(dir)
+}
+
+// Store writes the image and the result to the current date's partition.
+func (s *FileSink) Store(imageData []byte, result *ClassifierResult) error {
+	now := time.Now().UTC()
+	partition := filepath.Join(s.dir, now.Format("2006"), now.Format("01"), now.Format("02"))
+	if err := os.MkdirAll(partition, 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	ext := "bin"
+	if _, format, err := image.DecodeConfig(bytes.NewReader(imageData)); err == nil {
+		ext = format
+	}
+	base := filepath.Join(partition, fmt.Sprintf("%s-%06d", now.Format("150405.000000000"), s.seq.Add(1)))
+
+	if err := os.WriteFile(base+"."+ext, imageData, 0o644); err != nil {
+		return fmt.Errorf("failed to archive image: %w", err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result for archiving: %w", err)
+	}
+	if err := os.WriteFile(base+".json", data, 0o644); err != nil {
+		return fmt.Errorf("failed to archive result: %w", err)
+	}
+	return nil
+}
+
+// Archive failure reasons reported to Metrics.ObserveArchiveFailure.
+const (
+	// ArchiveDropped is an archive job dropped because the queue was full.
+	ArchiveDropped = "dropped"
+	// ArchiveFailed is an archive job whose Store failed.
+	ArchiveFailed = "failed"
+)
+
+// archiveJob is an image and its result waiting in a QueuedSink.
+type archiveJob struct {
+	imageData []byte
+	result    *ClassifierResult
+}
+
+// QueuedSink archives in the background: Enqueue hands the image to a single worker
+// storing it in the wrapped sink through a queue of bounded size, so that a slow sink
+// cannot pile up goroutines. Jobs arriving while the queue is full are dropped.
+// Drops and store failures are reported to the metrics; failures are also logged.
+type QueuedSink struct {
+	sink    ResultSink
+	metrics Metrics
+	queue   chan archiveJob
+	done    sync.WaitGroup
+}
+
+// NewQueuedSink creates a QueuedSink storing into sink with a queue of size jobs
+// and starts its worker.
+func NewQueuedSink(sink ResultSink, size int, metrics Metrics) *QueuedSink {
+	s := &QueuedSink{sink: sink, metrics: metrics, queue: make(chan archiveJob, size)}
+	s.done.Add(1)
+	go s.run()
+	return s
+}
+
+// Enqueue queues the image and its result for archiving without blocking.
+// It reports false if the queue was full and the job was dropped.
+func (s *QueuedSink) Enqueue(imageData []byte, result *ClassifierResult) bool {
+	select {
+	case s.queue <- archiveJob{imageData: imageData, result: result}:
+		return true
+	default:
+		s.metrics.ObserveArchiveFailure(ArchiveDropped)
+		return false
+	}
+}
+
+// Close stops accepting jobs and waits until the queued ones are stored.
+// Enqueue must not be called after Close.
+func (s *QueuedSink) Close() {
+	close(s.queue)
+	s.done.Wait()
+}
+
+// run stores the queued jobs until the queue is closed.
+func (s *QueuedSink) run() {
+	defer s.done.Done()
+	for job := range s.queue {
+		if err := s.sink.Store(job.imageData, job.result); err != nil {
+			s.metrics.ObserveArchiveFailure(ArchiveFailed)
+			log.Printf("failed to archive classification: %v", err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// sinkFunc is a ResultSink backed by a function.
+type sinkFunc func(imageData []byte, result *ClassifierResult) error
+
+func (f sinkFunc) Store(imageData []byte, result *ClassifierResult) error {
+	return f(imageData, result)
+}
+
+// archiveMetrics records the archive failures, ignoring the other observations.
+type archiveMetrics struct {
+	NopMetrics
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+func (m *archiveMetrics) ObserveArchiveFailure(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures == nil {
+		m.failures = map[string]int{}
+	}
+	m.failures[reason]++
+}
+
+func TestQueuedSinkDropsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 4)
+	var stored int
+	sink := sinkFunc(func([]byte, *ClassifierResult) error {
+		started <- struct{}{}
+		<-release
+		stored++
+		return nil
+	})
+	metrics := &archiveMetrics{}
+	s := NewQueuedSink(sink, 2, metrics)
+
+	// The worker blocks on the first job, two more fill the queue
+	if !s.Enqueue(nil, &ClassifierResult{}) {
+		t.Fatal("first job dropped")
+	}
+	<-started
+	for i := 0; i < 2; i++ {
+		if !s.Enqueue(nil, &ClassifierResult{}) {
+			t.Fatalf("job %d dropped with room in the queue", i+2)
+		}
+	}
+	if s.Enqueue(nil, &ClassifierResult{}) {
+		t.Error("job accepted by a full queue")
+	}
+
+	close(release)
+	s.Close()
+
+	if stored != 3 {
+		t.Errorf("stored %d jobs, want the 3 queued", stored)
+	}
+	if metrics.failures[ArchiveDropped] != 1 || metrics.failures[ArchiveFailed] != 0 {
+		t.Errorf("failures = %v, want 1 dropped", metrics.failures)
+	}
+}
+
+func TestQueuedSinkCountsStoreFailures(t *testing.T) {
+	sink := sinkFunc(func([]byte, *ClassifierResult) error {
+		return errors.New("failed to archive image: disk full")
+	})
+	metrics := &archiveMetrics{}
+	s := NewQueuedSink(sink, 4, metrics)
+	for i := 0; i < 3; i++ {
+		s.Enqueue(nil, &ClassifierResult{})
+	}
+	s.Close()
+
+	if metrics.failures[ArchiveFailed] != 3 {
+		t.Errorf("failures = %v, want 3 failed", metrics.failures)
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import "image"
+
+// snapToCardinal replaces a phase 2 result whose angle lies within SnapTolerance degrees
+// of a cardinal orientation (0/90/180/270) with the result at that cardinal angle,
+// provided one more OCR pass confirms the snapped result is not worse.
+// The original angle remains available in RawAngle.
+func (c *Classifier) snapToCardinal(preprocessed image.Image, scaleFactor float64, result *ClassifierResult, rule DecisionRule, imgWidth, imgHeight int) *ClassifierResult {
+	if c.opts.SnapTolerance <= 0 {
+		return result
+	}
+
+	snapped, ok := nearestCardinal(result.Angle, c.opts.SnapTolerance)
+	if !ok || snapped == result.Angle {
+		return result
+	}
+
+	snappedResult, _, _ := c.trySingleRotation(preprocessed, scaleFactor, rule, snapped, imgWidth, imgHeight)
+	if snappedResult == nil || snappedResult.WeightedConfidence < result.WeightedConfidence {
+		return result
+	}
+
+	snappedResult.RawAngle = result.Angle
+	snappedResult.IsTextDocument = EvaluateDecision(snappedResult.WeightedConfidence, snappedResult.TokenCount, rule)
+	return snappedResult
+}
+
+// nearestCardinal returns the cardinal angle closest to angle and true if it is within
+// tolerance degrees, or (angle, false) otherwise.
+func nearestCardinal(angle, tolerance int) (int, bool) {
+	angle = ((angle % 360) + 360) % 360
+	offset := angle % 90
+	cardinal := angle - offset
+	if offset > 45 {
+		offset = 90 - offset
+		cardinal = (cardinal + 90) % 360
+	}
+	if offset > tolerance {
+		return angle, false
+	}
+	return cardinal, true
+}
+
+// cardinalDistance returns the distance in degrees from angle to the nearest cardinal angle.
+func cardinalDistance(angle int) int {
+	offset := ((angle % 90) + 90) % 90
+	if offset > 45 {
+		offset = 90 - offset
+	}
+	return offset
+}
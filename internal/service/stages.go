@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"image"
+)
+
+// PreprocessStage is an intermediate image of the preprocessing pipeline.
+type PreprocessStage struct {
+	// Name is the stage: input, scaled, blurred, grayscale, equalized, thresholded
+	// or background_band.
+	Name string
+	// PNG is the PNG-encoded image after the stage.
+	PNG []byte
+}
+
+// PreprocessStages decodes the image, crops it to the rule's ROI and pads it if small,
+// like DetectText, and returns the input and the image after each preprocessing stage,
+// in pipeline order. Stages disabled by the preprocessing options are omitted.
+// It is a debugging aid and runs no OCR.
+func (c *Classifier) PreprocessStages(imageData []byte, rule DecisionRule) ([]PreprocessStage, error) {
+	rule = c.normalizeDecisionRule(rule)
+	img, err := c.decodeImage(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if rule.ROI != nil {
+		if img, err = cropToROI(img, *rule.ROI); err != nil {
+			return nil, err
+		}
+	}
+	img, _ = c.padSmallImage(img)
+
+	var stages []PreprocessStage
+	record := func(stage string, img image.Image) {
+		if err != nil {
+			return
+		}
+		var data []byte
+		if data, err = encodeImage(img, "png"); err != nil {
+			err = fmt.Errorf("failed to encode %s stage: %w", stage, err)
+			return
+		}
+		stages = append(stages, PreprocessStage{Name: stage, PNG: data})
+	}
+
+	record("input", img)
+	preprocessed, _, _, _, _ := preprocessImageStages(img, c.preprocessOptions(rule), record)
+	if err != nil {
+		return nil, err
+	}
+	if preprocessed == nil {
+		return nil, ErrImageTooSmall
+	}
+	return stages, nil
+}
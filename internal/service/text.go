@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Text formats of ClassifierResult.Text, selected with the text_format parameter.
+const (
+	// TextFormatLines joins the words of each line with spaces and the lines with newlines.
+	TextFormatLines = "lines"
+	// TextFormatFlat joins all words with single spaces into one line, e.g. for search indexing.
+	TextFormatFlat = "flat"
+)
+
+// ValidateTextFormat checks that format is a supported text format name.
+func ValidateTextFormat(format string) error {
+	switch format {
+	case TextFormatLines, TextFormatFlat:
+		return nil
+	}
+	return fmt.Errorf("unsupported text format %q, supported: %s, %s", format, TextFormatLines, TextFormatFlat)
+}
+
+// RecognizedText returns the words of boxes in reading order (lines top-to-bottom,
+// words left-to-right, see GroupLines) in the given text format. Whitespace within
+// words is collapsed to single spaces.
+func RecognizedText(boxes []BoundingBox, format string) string {
+	lines := GroupLines(boxes)
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		words := make([]string, 0, len(line.Words))
+		for _, word := range line.Words {
+			if w := strings.Join(strings.Fields(word.Word), " "); w != "" {
+				words = append(words, w)
+			}
+		}
+		if len(words) > 0 {
+			texts = append(texts, strings.Join(words, " "))
+		}
+	}
+
+	separator := "\n"
+	if format == TextFormatFlat {
+		separator = " "
+	}
+	return strings.Join(texts, separator)
+}
@@ -0,0 +1,23 @@
+package service
+
+// Thresholding methods reported in ThresholdInfo.
+const (
+	// ThresholdMethodFixed whitens pixels from the preset's white threshold.
+	ThresholdMethodFixed = "fixed"
+	// ThresholdMethodBackgroundBand also whitens pixels within the background band
+	// below the background luminance estimated from the image.
+	ThresholdMethodBackgroundBand = "background_band"
+)
+
+// ThresholdInfo describes the thresholding step of preprocessing, which turns light
+// pixels white. A threshold close to 0 or a background far from white signals a dark
+// or low-contrast image.
+type ThresholdInfo struct {
+	// Method is ThresholdMethodFixed or ThresholdMethodBackgroundBand.
+	Method string `json:"method"`
+	// Threshold is the luminance (0-255) from which pixels were turned white.
+	Threshold int `json:"threshold"`
+	// BackgroundLevel is the median luminance taken as the background, set only
+	// with ThresholdMethodBackgroundBand.
+	BackgroundLevel int `json:"background_level,omitempty"`
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+
+	_ "golang.org/x/image/tiff"
+)
+
+func init() {
+	RegisterImageFormat("image/tiff", "tiff")
+	RegisterPageSplitter("tiff", splitTIFFPages)
+}
+
+// errInvalidTIFF is returned for TIFF data whose header or directory chain is malformed.
+var errInvalidTIFF = errors.New("invalid TIFF structure")
+
+// splitTIFFPages returns the number of image file directories (pages) in data and a
+// function building the TIFF file of page i. The tiff decoder only reads the first
+// directory, so a page is a copy of data whose header points to that page's directory;
+// all offsets in a TIFF are absolute, so the page's tags and strips stay valid.
+func splitTIFFPages(data []byte) (int, func(i int) []byte, error) {
+	if len(data) < 8 {
+		return 0, nil, errInvalidTIFF
+	}
+	var order binary.ByteOrder
+	switch string(data[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0, nil, errInvalidTIFF
+	}
+
+	var offsets []uint32
+	seen := make(map[uint32]bool)
+	for offset := order.Uint32(data[4:8]); offset != 0; {
+		if seen[offset] || uint64(offset)+2 > uint64(len(data)) {
+			return 0, nil, errInvalidTIFF
+		}
+		seen[offset] = true
+		offsets = append(offsets, offset)
+		// A directory is a 2-byte entry count, 12-byte entries and the next directory offset
+		next := uint64(offset) + 2 + 12*uint64(order.Uint16(data[offset:]))
+		if next+4 > uint64(len(data)) {
+			return 0, nil, errInvalidTIFF
+		}
+		offset = order.Uint32(data[next:])
+	}
+	if len(offsets) <= 1 {
+		return 1, func(int) []byte { return data }, nil
+	}
+
+	page := func(i int) []byte {
+		page := append([]byte(nil), data...)
+		order.PutUint32(page[4:8], offsets[i])
+		return page
+	}
+	return len(offsets), page, nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StageTiming is the duration of one pipeline stage.
+type StageTiming struct {
+	Stage        string  `json:"stage"`
+	Milliseconds float64 `json:"ms"`
+}
+
+// Timing records pipeline stage durations of one request. It is safe for concurrent use.
+// All methods are no-ops on a nil *Timing, so disabled profiling costs nothing.
+type Timing struct {
+	mu     sync.Mutex
+	stages []StageTiming
+}
+
+// NewTiming creates an empty Timing recorder.
+func NewTiming() *Timing {
+	return &Timing{}
+}
+
+// start returns the start time of a stage, or the zero time if t is nil.
+func (t *Timing) start() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// record adds the duration of stage since start.
+func (t *Timing) record(stage string, start time.Time) {
+	if t == nil {
+		return
+	}
+	elapsed := float64(time.Since(start).Microseconds()) / 1000
+	t.mu.Lock()
+	t.stages = append(t.stages, StageTiming{Stage: stage, Milliseconds: elapsed})
+	t.mu.Unlock()
+}
+
+// recordAngle adds the duration of a per-angle stage since start, named "<stage>_<angle>".
+// The name is only built when recording is enabled.
+func (t *Timing) recordAngle(stage string, angle int, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.record(stage+"_"+strconv.Itoa(angle), start)
+}
+
+// Stages returns a copy of the recorded stages in recording order, or nil if t is nil.
+func (t *Timing) Stages() []StageTiming {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]StageTiming(nil), t.stages...)
+}
@@ -1,6 +1,12 @@
 package service
 
-import "unicode"
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
 
 // UnitSymbols defines Unicode range table for unit symbols used in token counting.
 var UnitSymbols = &unicode.RangeTable{
@@ -108,3 +114,44 @@ func countTokens(s string) int {
 
 	return count
 }
+
+// sanitizeWords drops invalid UTF-8 byte sequences the OCR engine occasionally emits,
+// so recognized words always serialize as clean UTF-8 and count tokens correctly.
+func sanitizeWords(boxes []RecognizedBox) {
+	for i := range boxes {
+		if !utf8.ValidString(boxes[i].Word) {
+			boxes[i].Word = strings.ToValidUTF8(boxes[i].Word, "")
+		}
+	}
+}
+
+// normalizeWords makes recognized words stable for exact matching: any whitespace
+// (tabs, non-breaking spaces) becomes a single ASCII space, other control characters
+// are removed, surrounding spaces are trimmed and the word is converted to NFC, so
+// e.g. "и" followed by a combining breve becomes a single "й".
+func normalizeWords(boxes []RecognizedBox) {
+	for i := range boxes {
+		boxes[i].Word = normalizeWord(boxes[i].Word)
+	}
+}
+
+// normalizeWord normalizes a single word, see normalizeWords.
+func normalizeWord(word string) string {
+	var b strings.Builder
+	b.Grow(len(word))
+	space := false
+	for _, r := range word {
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+		case unicode.IsControl(r):
+		default:
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(b.String())
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeWords(t *testing.T) {
+	tests := []struct {
+		name string
+		word string
+		want string
+	}{
+		{name: "valid", word: "счёт", want: "счёт"},
+		{name: "invalid byte dropped", word: "in\xffvoice", want: "invoice"},
+		{name: "truncated rune dropped", word: "сч\xd1", want: "сч"},
+		{name: "broken sequence", word: "\xc3\x28\xa0", want: "("},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes := []RecognizedBox{{Word: tt.word}}
+			sanitizeWords(boxes)
+			if boxes[0].Word != tt.want {
+				t.Errorf("sanitized %q to %q, want %q", tt.word, boxes[0].Word, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectTextSingleInvalidUTF8(t *testing.T) {
+	c := newTestClassifier(Options{}, staticEngine(word("in\xffvoice", 10, 10, 90), word("\xfe\xfe", 10, 40, 90)))
+
+	result, err := c.detectTextSingle(encodePNG(t, textImage(120, 80)), OCRParams{})
+	if err != nil {
+		t.Fatalf("detectTextSingle failed: %v", err)
+	}
+	if result.TokenCount != len("invoice") {
+		t.Errorf("TokenCount = %d, want %d", result.TokenCount, len("invoice"))
+	}
+	for _, box := range result.Boxes {
+		if !utf8.ValidString(box.Word) {
+			t.Errorf("word %q is not valid UTF-8", box.Word)
+		}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to marshal result: %v", err)
+	}
+	if !utf8.Valid(data) || !json.Valid(data) {
+		t.Errorf("result does not serialize to clean UTF-8 JSON: %q", data)
+	}
+}
+
+func TestNormalizeWord(t *testing.T) {
+	tests := []struct {
+		name string
+		word string
+		want string
+	}{
+		{name: "plain", word: "invoice", want: "invoice"},
+		{name: "non-breaking space", word: "10\u00a0000", want: "10 000"},
+		{name: "tabs and surrounding spaces", word: "\t total\u00a0\t", want: "total"},
+		{name: "inner whitespace collapsed", word: "a\t \u00a0b", want: "a b"},
+		{name: "control characters removed", word: "in\x00vo\x1bice", want: "invoice"},
+		{name: "combining breve composed", word: "\u0438\u0306", want: "\u0439"},
+		{name: "combining acute composed", word: "e\u0301", want: "\u00e9"},
+		{name: "only whitespace", word: "  \t", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeWord(tt.word); got != tt.want {
+				t.Errorf("normalizeWord(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectTextSingleNormalizeWords(t *testing.T) {
+	engine := staticEngine(word("\u00a0счёт\t", 10, 10, 90), word("\u0438\u0306од", 10, 40, 90))
+	imageData := encodePNG(t, textImage(120, 80))
+
+	tests := []struct {
+		normalize  bool
+		want       []string
+		wantTokens int
+	}{
+		// The combining breve is not a letter, so "и" and the breve count as one token
+		{normalize: false, want: []string{"\u00a0счёт\t", "\u0438\u0306од"}, wantTokens: 4 + 3},
+		{normalize: true, want: []string{"счёт", "\u0439од"}, wantTokens: 4 + 3},
+	}
+	for _, tt := range tests {
+		c := newTestClassifier(Options{NormalizeWords: tt.normalize}, engine)
+		result, err := c.detectTextSingle(imageData, OCRParams{})
+		if err != nil {
+			t.Fatalf("detectTextSingle failed: %v", err)
+		}
+		for i, box := range result.Boxes {
+			if box.Word != tt.want[i] {
+				t.Errorf("NormalizeWords %v: word %q, want %q", tt.normalize, box.Word, tt.want[i])
+			}
+		}
+		if result.TokenCount != tt.wantTokens {
+			t.Errorf("NormalizeWords %v: TokenCount = %d, want %d", tt.normalize, result.TokenCount, tt.wantTokens)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+
+	"golang.org/x/image/webp"
+)
+
+func init() {
+	RegisterImageFormat("image/webp", "webp")
+	RegisterFrameCounter("webp", countWebPFrames)
+	RegisterFirstFrameDecoder("webp", decodeWebPFirstFrame)
+}
+
+// errInvalidWebP is returned for WebP data whose RIFF structure is malformed.
+var errInvalidWebP = errors.New("invalid WebP container")
+
+// webpAlphaFlag is the VP8X flag of images with an alpha channel.
+const webpAlphaFlag = 1 << 4
+
+// webpChunks returns the chunks of a RIFF WebP file in order.
+func webpChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errInvalidWebP
+	}
+	return parseWebPChunks(data[12:])
+}
+
+// webpChunk is one RIFF chunk: a four-character ID and its payload.
+type webpChunk struct {
+	id      string
+	payload []byte
+}
+
+// parseWebPChunks splits data into RIFF chunks, skipping the padding byte after
+// odd-sized payloads.
+func parseWebPChunks(data []byte) ([]webpChunk, error) {
+	var chunks []webpChunk
+	for len(data) >= 8 {
+		size := binary.LittleEndian.Uint32(data[4:8])
+		if uint64(size) > uint64(len(data)-8) {
+			return nil, errInvalidWebP
+		}
+		chunks = append(chunks, webpChunk{id: string(data[:4]), payload: data[8 : 8+size]})
+		data = data[8+size:]
+		if size%2 == 1 && len(data) > 0 {
+			data = data[1:]
+		}
+	}
+	return chunks, nil
+}
+
+// countWebPFrames returns the number of animation frames (ANMF chunks) in a WebP file,
+// 1 for still images.
+func countWebPFrames(data []byte) (int, error) {
+	chunks, err := webpChunks(data)
+	if err != nil {
+		return 0, err
+	}
+	frames := 0
+	for _, chunk := range chunks {
+		if chunk.id == "ANMF" {
+			frames++
+		}
+	}
+	return max(frames, 1), nil
+}
+
+// decodeWebPFirstFrame decodes the first frame of an animated WebP file by wrapping
+// its bitstream chunks into a still WebP file. Importing the webp package registers its
+// decoder with the image package, which handles lossy, lossless and extended (VP8X)
+// still images, but not animations. The frame offset within the canvas is ignored:
+// the first frame normally covers the whole canvas.
+func decodeWebPFirstFrame(data []byte) (image.Image, error) {
+	chunks, err := webpChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		// The frame header holds X, Y, width-1, height-1 and duration (3 bytes each)
+		// and a flags byte, followed by the ALPH, VP8 or VP8L chunks of the frame
+		if chunk.id != "ANMF" || len(chunk.payload) < 16 {
+			continue
+		}
+		frame, err := parseWebPChunks(chunk.payload[16:])
+		if err != nil {
+			return nil, err
+		}
+		return webp.Decode(bytes.NewReader(stillWebP(chunk.payload[6:12], frame)))
+	}
+	return nil, errInvalidWebP
+}
+
+// stillWebP builds a still WebP file from the chunks of an animation frame of the given
+// size (width-1 and height-1, 3 bytes each). A VP8X header is added when the frame has
+// an alpha chunk, as the decoder requires.
+func stillWebP(size []byte, frame []webpChunk) []byte {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	for _, chunk := range frame {
+		if chunk.id == "ALPH" {
+			header := make([]byte, 10)
+			header[0] = webpAlphaFlag
+			copy(header[4:], size)
+			writeWebPChunk(&body, "VP8X", header)
+			break
+		}
+	}
+	for _, chunk := range frame {
+		writeWebPChunk(&body, chunk.id, chunk.payload)
+	}
+
+	var out bytes.Buffer
+	writeWebPChunk(&out, "RIFF", body.Bytes())
+	return out.Bytes()
+}
+
+// writeWebPChunk writes a RIFF chunk with its padding byte.
+func writeWebPChunk(buf *bytes.Buffer, id string, payload []byte) {
+	buf.WriteString(id)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(payload)))
+	buf.Write(payload)
+	if len(payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}